@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -27,37 +28,65 @@ import (
 	"github.com/google/go-github/v44/github"
 	"github.com/hhhapz/doc"
 	"github.com/hhhapz/doc/godocs"
-	gopiston "github.com/milindmadhukar/go-piston"
 
 	"github.com/disgoorg/disgo-butler/db"
+	"github.com/disgoorg/disgo-butler/godoc"
 	"github.com/disgoorg/disgo-butler/mod_mail"
 )
 
 func New(logger log.Logger, version string, config Config) *Butler {
+	executor, err := NewExecutor(config.Eval)
+	if err != nil {
+		logger.Fatalf("Failed to setup eval backends: %s", err)
+	}
 	return &Butler{
-		PistonClient: gopiston.CreateDefaultClient(),
-		Config:       config,
-		Logger:       logger,
-		Webhooks:     map[string]webhook.Client{},
-		Paginator:    paginator.New(),
-		Version:      version,
+		Executor:    executor,
+		Config:      config,
+		Logger:      logger,
+		webhooks:    map[string]webhook.Client{},
+		Paginator:   paginator.New(),
+		DocsUsage:   NewDocsUsage(),
+		DocsStates:  NewDocsStates(),
+		EvalQueue:   NewEvalQueue(config.Eval.Queue),
+		EvalWatches: NewEvalWatches(time.Duration(config.Eval.RerunWindow) * time.Second),
+		Version:     version,
 	}
 }
 
 type Butler struct {
 	Client       bot.Client
 	OAuth2       oauth2.Client
-	PistonClient *gopiston.Client
+	Executor     Executor
 	Logger       log.Logger
 	Mux          *http.ServeMux
 	GitHubClient *github.Client
 	Paginator    *paginator.Manager
-	DocClient    *doc.CachedSearcher
+	DocClient    *DocsCache
+	DocsUsage    *DocsUsage
+	DocsStates   *DocsStates
+	EvalQueue    *EvalQueue
+	EvalWatches  *EvalWatches
+	ModuleSource *godoc.Searcher
 	ModMail      *mod_mail.ModMail
 	DB           db.DB
 	Config       Config
-	Webhooks     map[string]webhook.Client
 	Version      string
+
+	webhooksMu sync.Mutex
+	webhooks   map[string]webhook.Client
+}
+
+// Webhook returns the client of the webhook release announcements of the repository are sent with. Clients are
+// created once per repository and recreated if the webhook in the config changed.
+func (b *Butler) Webhook(fullName string, cfg GithubReleaseConfig) webhook.Client {
+	b.webhooksMu.Lock()
+	defer b.webhooksMu.Unlock()
+	client, ok := b.webhooks[fullName]
+	if !ok || client.ID() != cfg.WebhookID || client.Token() != cfg.WebhookToken {
+		client = webhook.New(cfg.WebhookID, cfg.WebhookToken)
+		b.webhooks[fullName] = client
+	}
+	return client
 }
 
 func (b *Butler) SetupRoutes(router chi.Router) {
@@ -93,19 +122,43 @@ func (b *Butler) SetupBot(r handler.Router) {
 	b.OAuth2 = oauth2.New(b.Client.ApplicationID(), b.Config.Secret)
 
 	b.GitHubClient = github.NewClient(b.Client.Rest().HTTPClient())
-	b.DocClient = doc.WithCache(doc.New(b.Client.Rest().HTTPClient(), godocs.Parser))
+	b.ModuleSource = b.newModuleSource()
+	b.DocClient = NewDocsCache(b.newDocSearcher())
 
 	go func() {
 		b.Logger.Info("Loading go modules aliases...")
 		for _, module := range b.Config.Docs.Aliases {
 			b.Logger.Infof("Loading alias %s...", module)
-			if _, err = b.DocClient.Search(context.TODO(), module); err != nil {
+			pkg, err := b.DocClient.Search(context.TODO(), module)
+			if err != nil {
 				b.Logger.Errorf("Failed to load module alias %s: %s", module, err)
+				continue
 			}
+			b.LoadSubpackages(context.TODO(), pkg)
 		}
 	}()
 }
 
+func (b *Butler) newModuleSource() *godoc.Searcher {
+	opts := []godoc.ConfigOpt{godoc.WithLocalModules(b.Config.Docs.LocalModules)}
+	if b.Config.Docs.GoProxy != "" {
+		opts = append(opts, godoc.WithProxy(b.Config.Docs.GoProxy))
+	}
+	if b.Config.Docs.GoRoot != "" {
+		opts = append(opts, godoc.WithGoRoot(b.Config.Docs.GoRoot))
+	}
+	return godoc.New(b.Client.Rest().HTTPClient(), opts...)
+}
+
+func (b *Butler) newDocSearcher() doc.Searcher {
+	switch b.Config.Docs.Backend {
+	case DocsBackendGoProxy:
+		return b.ModuleSource
+	default:
+		return doc.New(b.Client.Rest().HTTPClient(), godocs.Parser)
+	}
+}
+
 func (b *Butler) SyncCommands(commands []discord.ApplicationCommandCreate, guildIDs ...snowflake.ID) {
 	if len(guildIDs) == 0 {
 		if _, err := b.Client.Rest().SetGlobalCommands(b.Client.ApplicationID(), commands); err != nil {
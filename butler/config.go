@@ -53,6 +53,20 @@ func SaveConfig(config Config) error {
 	return err
 }
 
+const (
+	// DocsBackendGoDocs scrapes the documentation from godocs.io.
+	DocsBackendGoDocs DocsBackend = ""
+	// DocsBackendGoProxy builds the documentation from module sources downloaded from a GOPROXY or local directories.
+	DocsBackendGoProxy DocsBackend = "goproxy"
+)
+
+const (
+	// EvalBackendPiston runs code on a piston instance.
+	EvalBackendPiston EvalBackend = ""
+	// EvalBackendSandbox runs code in a sandbox on the bot host. It is only supported on linux.
+	EvalBackendSandbox EvalBackend = "sandbox"
+)
+
 type (
 	Config struct {
 		DevMode  bool         `json:"dev_mode"`
@@ -63,6 +77,7 @@ type (
 		BaseURL  string       `json:"base_url"`
 
 		Docs                DocsConfig                     `json:"docs"`
+		Eval                EvalConfig                     `json:"eval"`
 		Database            db.Config                      `json:"database"`
 		GithubWebhookSecret string                         `json:"github_webhook_secret"`
 		GithubReleases      map[string]GithubReleaseConfig `json:"github_releases"`
@@ -72,13 +87,79 @@ type (
 	}
 
 	DocsConfig struct {
-		Aliases map[string]string `json:"aliases"`
+		Aliases      map[string]string `json:"aliases"`
+		Backend      DocsBackend       `json:"backend"`
+		GoProxy      string            `json:"go_proxy"`
+		GoRoot       string            `json:"go_root"`
+		LocalModules map[string]string `json:"local_modules"`
+	}
+
+	DocsBackend string
+
+	// EvalConfig configures the backends which run evals and their limits.
+	EvalConfig struct {
+		EvalLimits
+		// Backends are the named backends which run evals. Without backends the public piston instance is used.
+		Backends map[string]EvalBackendConfig `json:"backends"`
+		// Languages maps languages to the names of the backends which are tried in order. The "*" entry applies to
+		// all other languages. Languages without an entry try all backends.
+		Languages map[string][]string `json:"languages"`
+		// GoVet runs go vet on go evals and reports its diagnostics. It requires the go tool on the bot host.
+		GoVet bool `json:"go_vet"`
+		// RuntimesRefresh is how often the runtimes of the backends are refreshed in seconds. Defaults to an hour.
+		RuntimesRefresh int `json:"runtimes_refresh"`
+		// Queue limits how many evals run at the same time and how many evals users can run.
+		Queue EvalQueueConfig `json:"queue"`
+		// RerunWindow is how long evals are rerun when their message is edited in seconds. Zero uses the default of
+		// 10 minutes and a negative value disables it.
+		RerunWindow int `json:"rerun_window"`
 	}
 
+	// EvalLimits are the limits of an execution. Zero values use the defaults of the backend.
+	EvalLimits struct {
+		// CompileTimeout is the maximum duration of the compile stage in milliseconds.
+		CompileTimeout int `json:"compile_timeout"`
+		// RunTimeout is the maximum duration of the run stage in milliseconds.
+		RunTimeout int `json:"run_timeout"`
+		// CompileMemoryLimit is the maximum memory of the compile stage in bytes.
+		CompileMemoryLimit int `json:"compile_memory_limit"`
+		// RunMemoryLimit is the maximum memory of the run stage in bytes.
+		RunMemoryLimit int `json:"run_memory_limit"`
+	}
+
+	// EvalQueueConfig configures the eval queue. Zero values use the defaults.
+	EvalQueueConfig struct {
+		// Workers is the number of evals which run at the same time. Defaults to 2.
+		Workers int `json:"workers"`
+		// MaxQueuedPerUser is the number of evals a user can have waiting. Defaults to 3.
+		MaxQueuedPerUser int `json:"max_queued_per_user"`
+		// UserQuota and ChannelQuota are the number of evals a user or channel can run per quota window. They
+		// default to 10 and 30, negative values disable them.
+		UserQuota    int `json:"user_quota"`
+		ChannelQuota int `json:"channel_quota"`
+		// QuotaWindow is the window of the quotas in seconds. Defaults to a minute.
+		QuotaWindow int `json:"quota_window"`
+	}
+
+	EvalBackendConfig struct {
+		Type EvalBackend `json:"type"`
+		// URL and APIKey configure a self-hosted piston instance.
+		URL    string `json:"url"`
+		APIKey string `json:"api_key"`
+		// Runtimes are the languages the local sandbox can run.
+		Runtimes []SandboxRuntime `json:"runtimes"`
+		// Sandbox configures the filesystem and limits of the local sandbox.
+		Sandbox SandboxOptions `json:"sandbox"`
+	}
+
+	EvalBackend string
+
 	GithubReleaseConfig struct {
 		WebhookID    snowflake.ID `json:"webhook_id"`
 		WebhookToken string       `json:"webhook_token"`
 		PingRole     snowflake.ID `json:"ping_role"`
+		// Module is the go module of the repository. If set, release announcements include a summary of its API changes.
+		Module string `json:"module"`
 	}
 
 	InteractionsConfig struct {
@@ -2,6 +2,7 @@ package butler
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/disgoorg/disgo/discord"
@@ -9,183 +10,306 @@ import (
 )
 
 const (
-	embedDescriptionFormat = "```go\n%s```\n\n%s\n%s"
-	embedTitleFormat       = "%s: %s"
-	embedColor             = 0x5865f2
-	embedPackageURLFormat  = "https://pkg.go.dev/%s"
-	embedURLFormat         = embedPackageURLFormat + "#%s"
-	exampleFormat          = "\n%s:\n```go\n%s\n```\n```%s```\n"
-	PkgInfo                = "<pkg_info>"
+	embedTitleFormat      = "%s: %s"
+	embedColor            = 0x5865f2
+	embedPackageURLFormat = "https://pkg.go.dev/%s"
+	embedURLFormat        = embedPackageURLFormat + "#%s"
+	refFormat             = "• [`%s`](" + embedURLFormat + ")\n"
+	PkgInfo               = "<pkg_info>"
+	docsMethodsPerPage    = 10
+	// embedDescriptionLimit is the maximum length of an embed description.
+	embedDescriptionLimit = 4096
+	// collapsedLimit is the maximum length of collapsed comments and examples.
+	collapsedLimit = 1024
+	// maxDocsRefs is the maximum number of refs listed per section, so the sections of a type fit into the embed.
+	maxDocsRefs = 10
 )
 
-func GetDocsEmbed(pkg doc.Package, query string, expandSignature bool, expandComment bool, expandMethods bool, expandExamples bool) (discord.Embed, discord.SelectMenuComponent) {
+// DocsEmbed renders the docs message for the state using the cached packages to find related symbols.
+func (b *Butler) DocsEmbed(pkg doc.Package, state *DocsState) (discord.Embed, []discord.ContainerComponent) {
+	return GetDocsEmbed(pkg, b.ModulePackages(pkg.URL, state.Version), b.RelatedSymbols(pkg, state.Version, state.Query), state)
+}
+
+func GetDocsEmbed(pkg doc.Package, packages []doc.Package, related []DocsRef, state *DocsState) (discord.Embed, []discord.ContainerComponent) {
 	var (
-		embed         discord.Embed
-		moreSignature bool
-		moreMethods   bool
-		moreComment   bool
-		moreExamples  bool
+		embed       discord.Embed
+		expandable  DocsSection
+		methodPages int
 	)
 
-	if query == "" || query == PkgInfo {
-		embed, moreComment, moreExamples = EmbedFromPackage(pkg, expandComment, expandExamples)
+	if state.Query == "" || state.Query == PkgInfo {
+		embed, expandable = EmbedFromPackage(pkg, *state)
 	} else {
-		values := strings.Split(query, ".")
+		values := strings.Split(state.Query, ".")
 		for i := range values {
 			values[i] = strings.ToLower(values[i])
 		}
 		if t, ok := pkg.Types[values[0]]; ok {
 			if len(values) > 1 {
 				if m, ok := t.Methods[values[1]]; ok {
-					embed, moreSignature, moreComment = EmbedFromMethod(pkg, m, expandSignature, expandComment, expandExamples)
+					embed, expandable = EmbedFromMethod(pkg, m, *state)
 				}
 			} else {
-				embed, moreSignature, moreComment = EmbedFromType(pkg, t, expandSignature, expandComment, expandMethods, expandExamples)
-				moreMethods = len(t.Methods) > 0 && !expandMethods
+				methodPages = (len(t.Methods) + docsMethodsPerPage - 1) / docsMethodsPerPage
+				if state.Page >= methodPages {
+					state.Page = methodPages - 1
+				}
+				if state.Page < 0 {
+					state.Page = 0
+				}
+				embed, expandable = EmbedFromType(pkg, t, *state)
+				expandable |= DocsSectionUsages
+				if IsInterface(t) {
+					expandable |= DocsSectionImplementations
+				}
+				if state.Expands(DocsSectionImplementations) {
+					embed.Description += FormatRefs("Implementations", FindImplementations(pkg, t, packages))
+				}
+				if state.Expands(DocsSectionUsages) {
+					returnedBy, acceptedBy := FindUsages(pkg, t, packages)
+					embed.Description += FormatRefs("Returned by", returnedBy) + FormatRefs("Accepted by", acceptedBy)
+				}
 			}
 		} else if f, ok := pkg.Functions[values[0]]; ok {
-			embed, moreSignature, moreComment = EmbedFromFunc(pkg, f, expandSignature, expandComment, expandExamples)
+			embed, expandable = EmbedFromFunc(pkg, f, *state)
 		}
 	}
-	if len(embed.Description) > 4096 {
-		embed.Description = embed.Description[:4095] + "…"
+	if len(embed.Description) > embedDescriptionLimit {
+		description := truncateString(embed.Description, embedDescriptionLimit-len("\n…"))
+		if i := strings.LastIndex(description, "\n"); i != -1 {
+			description = description[:i]
+		}
+		embed.Description = description + "\n…"
 	}
 
-	var options []discord.StringSelectMenuOption
-	if moreSignature {
-		options = append(options, discord.NewStringSelectMenuOption("expand signature", "expand:signature").WithEmoji(discord.ComponentEmoji{Name: "🔼"}))
+	var footer []string
+	if state.Alias != "" {
+		footer = append(footer, "Alias: "+state.Alias)
+	}
+	if state.Expands(DocsSectionMethods) && methodPages > 1 {
+		footer = append(footer, fmt.Sprintf("Methods page %d/%d", state.Page+1, methodPages))
 	}
-	if expandSignature {
-		options = append(options, discord.NewStringSelectMenuOption("collapse signature", "collapse:signature").WithEmoji(discord.ComponentEmoji{Name: "🔽"}))
+	if len(footer) > 0 {
+		embed.Footer = &discord.EmbedFooter{Text: strings.Join(footer, " • ")}
 	}
 
-	if moreMethods {
-		options = append(options, discord.NewStringSelectMenuOption("expand methods", "expand:methods").WithEmoji(discord.ComponentEmoji{Name: "🔼"}))
+	var options []discord.StringSelectMenuOption
+	for _, s := range docsSections {
+		if state.Expands(s.section) {
+			options = append(options, discord.NewStringSelectMenuOption("collapse "+s.label, "collapse:"+s.name).WithEmoji(discord.ComponentEmoji{Name: "🔽"}))
+		} else if expandable&s.section != 0 {
+			options = append(options, discord.NewStringSelectMenuOption("expand "+s.label, "expand:"+s.name).WithEmoji(discord.ComponentEmoji{Name: "🔼"}))
+		}
 	}
-	if expandMethods {
-		options = append(options, discord.NewStringSelectMenuOption("collapse methods", "collapse:methods").WithEmoji(discord.ComponentEmoji{Name: "🔽"}))
+	if IsRunnable(pkg) {
+		for i, example := range DocsExamples(pkg, state.Query) {
+			if i == maxRunnableExamples {
+				break
+			}
+			options = append(options, discord.NewStringSelectMenuOption(truncateString("run "+strings.ToLower(example.Name), 100), fmt.Sprintf("run:%d", i)).WithEmoji(discord.ComponentEmoji{Name: "▶"}))
+		}
 	}
+	options = append(options, discord.NewStringSelectMenuOption("delete", "delete").WithEmoji(discord.ComponentEmoji{Name: "❌"}))
 
-	if moreComment {
-		options = append(options, discord.NewStringSelectMenuOption("expand comment", "expand:comment").WithEmoji(discord.ComponentEmoji{Name: "🔼"}))
+	components := []discord.ContainerComponent{
+		discord.NewActionRow(discord.NewStringSelectMenu("docs_action", "action", options...)),
+	}
+	var relatedOptions []discord.StringSelectMenuOption
+	for _, ref := range related {
+		value := ref.Package + "#" + ref.Symbol
+		if len(value) > 100 || len(relatedOptions) == 25 {
+			continue
+		}
+		relatedOptions = append(relatedOptions, discord.NewStringSelectMenuOption(ref.String(), value).WithDescription(ref.Package))
 	}
-	if expandComment {
-		options = append(options, discord.NewStringSelectMenuOption("collapse comment", "collapse:comment").WithEmoji(discord.ComponentEmoji{Name: "🔽"}))
+	if len(relatedOptions) > 0 {
+		components = append(components, discord.NewActionRow(discord.NewStringSelectMenu("docs_related", "related symbols", relatedOptions...)))
 	}
 
-	if moreExamples {
-		options = append(options, discord.NewStringSelectMenuOption("expand examples", "expand:examples").WithEmoji(discord.ComponentEmoji{Name: "🔼"}))
+	var buttons []discord.InteractiveComponent
+	if len(state.History) > 0 {
+		buttons = append(buttons, discord.NewSecondaryButton("back", "docs_back").WithEmoji(discord.ComponentEmoji{Name: "↩"}))
 	}
-	if expandExamples {
-		options = append(options, discord.NewStringSelectMenuOption("collapse examples", "collapse:examples").WithEmoji(discord.ComponentEmoji{Name: "🔽"}))
+	if state.Expands(DocsSectionMethods) && methodPages > 1 {
+		buttons = append(buttons,
+			discord.NewSecondaryButton("◀", "docs_page/previous").WithDisabled(state.Page == 0),
+			discord.NewSecondaryButton("▶", "docs_page/next").WithDisabled(state.Page >= methodPages-1),
+		)
 	}
-
-	options = append(options, discord.NewStringSelectMenuOption("delete", "delete").WithEmoji(discord.ComponentEmoji{Name: "❌"}))
-
-	return embed, discord.NewStringSelectMenu("docs_action", "action", options...)
+	if len(buttons) > 0 {
+		components = append(components, discord.NewActionRow(buttons...))
+	}
+	return embed, components
 }
 
-func EmbedFromPackage(pkg doc.Package, expandComment bool, expandExamples bool) (discord.Embed, bool, bool) {
-	var (
-		moreComment  bool
-		moreExamples bool
-	)
+func EmbedFromPackage(pkg doc.Package, state DocsState) (discord.Embed, DocsSection) {
+	var expandable DocsSection
 
-	description := pkg.Overview.Markdown()
-	if !expandComment && len(description) > 1024 {
-		description = description[:1023] + "…"
-		moreComment = true
+	commentLimit := embedDescriptionLimit / 2
+	if !state.Expands(DocsSectionComment) {
+		commentLimit = collapsedLimit
+	}
+	description, more := JoinBlocks(RenderComment(pkg, pkg.Overview), commentLimit)
+	if more {
+		expandable |= DocsSectionComment
 	}
 
-	var examples string
-	for _, e := range pkg.Examples {
-		examples += fmt.Sprintf(exampleFormat, e.Name, e.Code, e.Output)
+	examplesLimit := embedDescriptionLimit - len(description) - 2
+	if !state.Expands(DocsSectionExamples) && examplesLimit > collapsedLimit {
+		examplesLimit = collapsedLimit
+	}
+	examples, more := JoinBlocks(exampleBlocks(pkg.Examples), examplesLimit)
+	if more {
+		expandable |= DocsSectionExamples
 	}
-	if !expandExamples && len(examples) > 1024 {
-		examples = examples[:1023] + "…"
-		moreExamples = true
+	if examples != "" {
+		description += "\n\n" + examples
 	}
 
 	return discord.Embed{
-		Title:       pkg.URL,
-		URL:         fmt.Sprintf(embedPackageURLFormat, pkg.URL),
-		Description: description + "\n" + examples,
+		Title:       docsTitle(pkg, state, ""),
+		URL:         docsURL(pkg, state, ""),
+		Description: description,
 		Color:       embedColor,
-	}, moreComment, moreExamples
+	}, expandable
 }
 
-func EmbedFromMethod(pkg doc.Package, m doc.Method, expandSignature bool, expandComment bool, expandExamples bool) (discord.Embed, bool, bool) {
-	description, moreSignature, moreComment := FormatDescription(m.Signature, m.Comment, m.Examples, expandSignature, expandComment, expandExamples)
+func EmbedFromMethod(pkg doc.Package, m doc.Method, state DocsState) (discord.Embed, DocsSection) {
+	description, expandable := FormatDescription(pkg, m.Signature, m.Comment, m.Examples, state)
 	return discord.Embed{
-		Title:       fmt.Sprintf(embedTitleFormat, pkg.URL, m.For+"."+m.Name),
-		URL:         fmt.Sprintf(embedURLFormat, pkg.URL, m.For+"."+m.Name),
+		Title:       docsTitle(pkg, state, m.For+"."+m.Name),
+		URL:         docsURL(pkg, state, m.For+"."+m.Name),
 		Description: description,
 		Color:       embedColor,
-	}, moreSignature, moreComment
+	}, expandable
 }
 
-func EmbedFromFunc(pkg doc.Package, f doc.Function, expandSignature bool, expandComment bool, expandExamples bool) (discord.Embed, bool, bool) {
-	description, moreSignature, moreComment := FormatDescription(f.Signature, f.Comment, f.Examples, expandSignature, expandComment, expandExamples)
+func EmbedFromFunc(pkg doc.Package, f doc.Function, state DocsState) (discord.Embed, DocsSection) {
+	description, expandable := FormatDescription(pkg, f.Signature, f.Comment, f.Examples, state)
 	return discord.Embed{
-		Title:       fmt.Sprintf(embedTitleFormat, pkg.URL, f.Name),
-		URL:         fmt.Sprintf(embedURLFormat, pkg.URL, f.Name),
+		Title:       docsTitle(pkg, state, f.Name),
+		URL:         docsURL(pkg, state, f.Name),
 		Description: description,
 		Color:       embedColor,
-	}, moreSignature, moreComment
+	}, expandable
 }
 
-func EmbedFromType(pkg doc.Package, t doc.Type, expandSignature bool, expandComment bool, expandMethods bool, expandExamples bool) (discord.Embed, bool, bool) {
-	description, moreSignature, moreComment := FormatDescription(t.Signature, t.Comment, t.Examples, expandSignature, expandComment, expandExamples)
-	if expandMethods {
-		methods := "```go\n"
-		for _, m := range t.Methods {
-			methods += m.Signature + "\n\n"
+func EmbedFromType(pkg doc.Package, t doc.Type, state DocsState) (discord.Embed, DocsSection) {
+	description, expandable := FormatDescription(pkg, t.Signature, t.Comment, t.Examples, state)
+	if len(t.Methods) > 0 {
+		expandable |= DocsSectionMethods
+	}
+	if state.Expands(DocsSectionMethods) && len(t.Methods) > 0 {
+		names := make([]string, 0, len(t.Methods))
+		for name := range t.Methods {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		start := state.Page * docsMethodsPerPage
+		end := start + docsMethodsPerPage
+		if end > len(names) {
+			end = len(names)
 		}
-		description += methods + "\n```"
-		if len(description) > 4096 {
-			description = description[:4082] + "…\n```"
+		signatures := make([]string, 0, end-start)
+		for _, name := range names[start:end] {
+			signatures = append(signatures, t.Methods[name].Signature)
+		}
+		if methods := truncateBlock(codeBlock("go", strings.Join(signatures, "\n\n")), embedDescriptionLimit-len(description)-2); methods != "" {
+			description += "\n\n" + methods
 		}
 	}
 	return discord.Embed{
-		Title:       fmt.Sprintf(embedTitleFormat, pkg.URL, t.Name),
-		URL:         fmt.Sprintf(embedURLFormat, pkg.URL, t.Name),
+		Title:       docsTitle(pkg, state, t.Name),
+		URL:         docsURL(pkg, state, t.Name),
 		Description: description,
 		Color:       embedColor,
-	}, moreSignature, moreComment
+	}, expandable
 }
 
-func FormatDescription(signature string, comment doc.Comment, examples []doc.Example, expandSignature bool, expandComment bool, expandExamples bool) (string, bool, bool) {
-	var (
-		moreSignature bool
-		moreComment   bool
-	)
+// docsTitle returns the title of a docs embed, which is also used to restore the state of expired messages.
+func docsTitle(pkg doc.Package, state DocsState, symbol string) string {
+	title := pkg.URL
+	if state.Version != "" {
+		title += "@" + state.Version
+	}
+	if symbol == "" {
+		return title
+	}
+	return fmt.Sprintf(embedTitleFormat, title, symbol)
+}
+
+func docsURL(pkg doc.Package, state DocsState, symbol string) string {
+	pkgPath := pkg.URL
+	if state.Version != "" {
+		pkgPath += "@" + state.Version
+	}
+	if symbol == "" {
+		return fmt.Sprintf(embedPackageURLFormat, pkgPath)
+	}
+	return fmt.Sprintf(embedURLFormat, pkgPath, symbol)
+}
+
+// FormatDescription formats the signature, comment and examples of a symbol. It returns which of those
+// sections can be expanded.
+func FormatDescription(pkg doc.Package, signature string, comment doc.Comment, examples []doc.Example, state DocsState) (string, DocsSection) {
+	var expandable DocsSection
 	lines := strings.Split(signature, "\n")
-	if !expandSignature && len(lines) > 6 {
-		moreSignature = true
+	if !state.Expands(DocsSectionSignature) && len(lines) > 6 {
+		expandable |= DocsSectionSignature
 		signature = lines[0] + "\n…"
-	} else if len(signature) > 4096 {
-		signature = signature[:4082] + "…\n```"
 	}
+	description := truncateBlock(codeBlock("go", signature), embedDescriptionLimit-collapsedLimit)
+
+	commentLimit := embedDescriptionLimit - len(description) - 2
+	if !state.Expands(DocsSectionComment) && commentLimit > collapsedLimit {
+		commentLimit = collapsedLimit
+	}
+	blocks := RenderComment(pkg, comment)
+	if len(blocks) == 0 {
+		blocks = []string{"No comments found."}
+	}
+	markdown, more := JoinBlocks(blocks, commentLimit)
+	if more {
+		expandable |= DocsSectionComment
+	}
+	description += "\n\n" + markdown
 
-	markdown := comment.Markdown()
-	if markdown == "" {
-		markdown = "No comments found."
+	if len(examples) > 0 {
+		expandable |= DocsSectionExamples
 	}
-	lines = strings.Split(markdown, "\n")
-	if !expandComment && len(lines) > 6 {
-		moreComment = true
-		markdown = lines[0] + "\n…"
+	if state.Expands(DocsSectionExamples) {
+		if examplesStr, _ := JoinBlocks(exampleBlocks(examples), embedDescriptionLimit-len(description)-2); examplesStr != "" {
+			description += "\n\n" + examplesStr
+		}
 	}
-	if !expandComment && len(markdown) > 1024 {
-		moreComment = true
-		markdown = markdown[:1023] + "…"
+	return description, expandable
+}
+
+// exampleBlocks renders every example with its output as a single markdown block.
+func exampleBlocks(examples []doc.Example) []string {
+	blocks := make([]string, len(examples))
+	for i, e := range examples {
+		block := e.Name + ":\n" + codeBlock("go", e.Code)
+		if e.Output != "" {
+			block += "\n" + codeBlock("", e.Output)
+		}
+		blocks[i] = block
 	}
+	return blocks
+}
 
-	var examplesStr string
-	if expandExamples {
-		for _, e := range examples {
-			examplesStr += fmt.Sprintf(exampleFormat, e.Name, e.Code, e.Output)
+// FormatRefs lists the refs under the title. Only the first maxDocsRefs refs are listed.
+func FormatRefs(title string, refs []DocsRef) string {
+	s := fmt.Sprintf("\n**%s:**\n", title)
+	if len(refs) == 0 {
+		return s + "None found.\n"
+	}
+	for i, ref := range refs {
+		if i == maxDocsRefs {
+			return s + fmt.Sprintf("… and %d more\n", len(refs)-maxDocsRefs)
 		}
+		s += fmt.Sprintf(refFormat, ref, ref.Package, strings.TrimPrefix(ref.Symbol, "*"))
 	}
-	return fmt.Sprintf(embedDescriptionFormat, signature, markdown, examplesStr), moreSignature, moreComment
+	return s
 }
@@ -0,0 +1,197 @@
+package butler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hhhapz/doc"
+	"golang.org/x/mod/module"
+
+	"github.com/disgoorg/disgo-butler/godoc"
+)
+
+const (
+	// docsFetchTimeout is how long fetching a single package may take. Fetches are not bound to the context of the
+	// search which started them, so an autocomplete request which gives up early still fills the cache.
+	docsFetchTimeout = time.Minute
+	// docsFailureTTL is how long failed fetches are remembered, so packages which don't exist aren't fetched again
+	// on every request.
+	docsFailureTTL = 30 * time.Second
+	// docsPrefetchDelay is how long Prefetch waits for the query to stop changing before it starts fetching.
+	docsPrefetchDelay = 750 * time.Millisecond
+)
+
+func NewDocsCache(searcher doc.Searcher) *DocsCache {
+	return &DocsCache{
+		searcher: searcher,
+		cache:    map[string]*doc.CachedPackage{},
+		indexes:  map[string]*SymbolIndex{},
+		inFlight: map[string]*docsFetch{},
+		failures: map[string]docsFailure{},
+		pending:  map[string]*time.Timer{},
+	}
+}
+
+// DocsCache caches the packages of a doc.Searcher like doc.CachedSearcher. Packages are fetched without holding
+// the cache lock, concurrent searches for the same package share one fetch and every package gets a SymbolIndex
+// once it is loaded. Packages are cached by their query, so each version of a package is cached separately.
+type DocsCache struct {
+	searcher doc.Searcher
+
+	mu       sync.Mutex
+	cache    map[string]*doc.CachedPackage
+	indexes  map[string]*SymbolIndex
+	inFlight map[string]*docsFetch
+	failures map[string]docsFailure
+	pending  map[string]*time.Timer
+}
+
+type docsFailure struct {
+	err error
+	at  time.Time
+}
+
+type docsFetch struct {
+	done chan struct{}
+	pkg  doc.Package
+	err  error
+}
+
+// Search returns the cached package or fetches it. It returns early with the context error if ctx is done before
+// the fetch completes.
+func (c *DocsCache) Search(ctx context.Context, query string) (doc.Package, error) {
+	if pkg, ok := c.Cached(query); ok {
+		return pkg, nil
+	}
+	if err := c.failure(query); err != nil {
+		return doc.Package{}, err
+	}
+	f := c.fetch(query)
+	select {
+	case <-f.done:
+		return f.pkg, f.err
+	case <-ctx.Done():
+		return doc.Package{}, ctx.Err()
+	}
+}
+
+// Prefetch starts fetching the package in the background if it is not cached yet. Queries which are no valid import
+// path are ignored. Prefetches with the same key are debounced, so only the last query of a user who is still typing
+// is fetched.
+func (c *DocsCache) Prefetch(key string, query string) {
+	pkgPath, _ := godoc.SplitVersion(query)
+	if module.CheckImportPath(pkgPath) != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if timer, ok := c.pending[key]; ok {
+		timer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(docsPrefetchDelay, func() {
+		c.mu.Lock()
+		if c.pending[key] != timer {
+			c.mu.Unlock()
+			return
+		}
+		delete(c.pending, key)
+		c.mu.Unlock()
+
+		if _, ok := c.Cached(query); ok || c.failure(query) != nil {
+			return
+		}
+		c.fetch(query)
+	})
+	c.pending[key] = timer
+}
+
+// failure returns the error of the last fetch of the query if it failed recently.
+func (c *DocsCache) failure(query string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	failure, ok := c.failures[query]
+	if !ok {
+		return nil
+	}
+	if time.Since(failure.at) > docsFailureTTL {
+		delete(c.failures, query)
+		return nil
+	}
+	return failure.err
+}
+
+// Cached returns the package if it is cached without fetching it.
+func (c *DocsCache) Cached(query string) (doc.Package, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pkg, ok := c.cache[query]
+	if !ok {
+		return doc.Package{}, false
+	}
+	pkg.Updated = time.Now()
+	return pkg.Package, true
+}
+
+// WithCache calls f with the cache. f must not call other methods of the DocsCache.
+func (c *DocsCache) WithCache(f func(cache map[string]*doc.CachedPackage)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f(c.cache)
+}
+
+// Index returns the symbol index of the package which was found by the query.
+func (c *DocsCache) Index(query string, pkg doc.Package) *SymbolIndex {
+	c.mu.Lock()
+	index, ok := c.indexes[query]
+	c.mu.Unlock()
+	if ok {
+		return index
+	}
+
+	index = NewSymbolIndex(pkg)
+	c.mu.Lock()
+	c.indexes[query] = index
+	c.mu.Unlock()
+	return index
+}
+
+func (c *DocsCache) fetch(query string) *docsFetch {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if f, ok := c.inFlight[query]; ok {
+		return f
+	}
+	f := &docsFetch{done: make(chan struct{})}
+	c.inFlight[query] = f
+
+	go func() {
+		defer close(f.done)
+		ctx, cancel := context.WithTimeout(context.Background(), docsFetchTimeout)
+		defer cancel()
+		f.pkg, f.err = c.searcher.Search(ctx, query)
+
+		var index *SymbolIndex
+		if f.err == nil {
+			index = NewSymbolIndex(f.pkg)
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.inFlight, query)
+		if f.err != nil {
+			c.failures[query] = docsFailure{err: f.err, at: time.Now()}
+			return
+		}
+		now := time.Now()
+		c.cache[query] = &doc.CachedPackage{
+			Package: f.pkg,
+			Created: now,
+			Updated: now,
+		}
+		c.indexes[query] = index
+	}()
+	return f
+}
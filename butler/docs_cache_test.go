@@ -0,0 +1,77 @@
+package butler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hhhapz/doc"
+)
+
+type countingSearcher struct {
+	mu       sync.Mutex
+	searches []string
+}
+
+func (s *countingSearcher) Search(_ context.Context, query string) (doc.Package, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.searches = append(s.searches, query)
+	if query == "example.com/missing" {
+		return doc.Package{}, doc.InvalidStatusError(http.StatusNotFound)
+	}
+	return doc.Package{URL: query, Name: "mod"}, nil
+}
+
+func (s *countingSearcher) Searches() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.searches...)
+}
+
+func TestDocsCacheFailures(t *testing.T) {
+	searcher := &countingSearcher{}
+	c := NewDocsCache(searcher)
+	for i := 0; i < 3; i++ {
+		if _, err := c.Search(context.Background(), "example.com/missing"); err != doc.InvalidStatusError(http.StatusNotFound) {
+			t.Fatalf("expected not found error, got %v", err)
+		}
+	}
+	if searches := searcher.Searches(); len(searches) != 1 {
+		t.Errorf("expected failure to be cached, got %d searches", len(searches))
+	}
+}
+
+func TestDocsCachePrefetch(t *testing.T) {
+	searcher := &countingSearcher{}
+	c := NewDocsCache(searcher)
+	c.Prefetch("user", "example.com/m")
+	c.Prefetch("user", "example.com/mo")
+	c.Prefetch("user", "example.com/mod")
+	c.Prefetch("user", "not a path!")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := c.Cached("example.com/mod"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("package was not prefetched")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if searches := searcher.Searches(); len(searches) != 1 || searches[0] != "example.com/mod" {
+		t.Errorf("expected only the last query to be fetched, got %v", searches)
+	}
+}
+
+func TestDocsCacheIndexVersions(t *testing.T) {
+	c := NewDocsCache(&countingSearcher{})
+	v1 := doc.Package{URL: "example.com/mod", Types: map[string]doc.Type{"old": {Name: "Old"}}}
+	v2 := doc.Package{URL: "example.com/mod", Types: map[string]doc.Type{"new": {Name: "New"}}}
+	if c.Index("example.com/mod@v1.0.0", v1) == c.Index("example.com/mod@v2.0.0", v2) {
+		t.Error("expected versions of a package to have separate indexes")
+	}
+}
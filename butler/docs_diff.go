@@ -0,0 +1,93 @@
+package butler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/disgoorg/disgo/discord"
+
+	"github.com/disgoorg/disgo-butler/godoc"
+)
+
+const (
+	diffColorBreaking = 0xed4245
+	diffColorSafe     = 0x57f287
+)
+
+// GetAPIDiffEmbed summarizes the API changes between two module versions. Breaking changes are listed first.
+// maxLen limits the length of the description.
+func GetAPIDiffEmbed(diff *godoc.APIDiff, maxLen int) discord.Embed {
+	embed := discord.Embed{
+		Title: fmt.Sprintf("API changes of %s %s → %s", diff.Module, diff.From, diff.To),
+		URL:   fmt.Sprintf(embedPackageURLFormat, diff.Module),
+		Color: diffColorSafe,
+	}
+	breaking := diff.Breaking()
+	if len(breaking) > 0 {
+		embed.Color = diffColorBreaking
+	}
+	if len(diff.Changes) == 0 {
+		embed.Description = "No changes to the exported API."
+		return embed
+	}
+
+	var nonBreaking []godoc.Change
+	for _, change := range diff.Changes {
+		if !change.Breaking {
+			nonBreaking = append(nonBreaking, change)
+		}
+	}
+
+	embed.Description = formatChanges("⚠️ Breaking changes", breaking, maxLen/2) +
+		formatChanges("Added", filterChanges(nonBreaking, godoc.ChangeAdded), maxLen/4) +
+		formatChanges("Changed", filterChanges(nonBreaking, godoc.ChangeChanged), maxLen/4)
+	embed.Footer = &discord.EmbedFooter{
+		Text: fmt.Sprintf("%d breaking, %d added, %d removed, %d changed", len(breaking), len(diff.Kind(godoc.ChangeAdded)), len(diff.Kind(godoc.ChangeRemoved)), len(diff.Kind(godoc.ChangeChanged))),
+	}
+	return embed
+}
+
+func filterChanges(changes []godoc.Change, kind godoc.ChangeKind) []godoc.Change {
+	var filtered []godoc.Change
+	for _, change := range changes {
+		if change.Kind == kind {
+			filtered = append(filtered, change)
+		}
+	}
+	return filtered
+}
+
+// formatChanges lists the changes below a title and stops before the section exceeds maxLen bytes.
+func formatChanges(title string, changes []godoc.Change, maxLen int) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	s := fmt.Sprintf("**%s:**\n", title)
+	for i, change := range changes {
+		line := formatChange(change)
+		if len(s)+len(line) > maxLen-20 {
+			s += fmt.Sprintf("… and %d more\n", len(changes)-i)
+			break
+		}
+		s += line
+	}
+	return s + "\n"
+}
+
+func formatChange(change godoc.Change) string {
+	var prefix string
+	switch change.Kind {
+	case godoc.ChangeAdded:
+		prefix = "+"
+	case godoc.ChangeRemoved:
+		prefix = "-"
+	case godoc.ChangeChanged:
+		prefix = "~"
+	}
+
+	name := change.Package[strings.LastIndex(change.Package, "/")+1:]
+	if change.Symbol == "" {
+		return fmt.Sprintf("`%s` package `%s` %s\n", prefix, change.Package, change.Kind)
+	}
+	return fmt.Sprintf("`%s` `%s.%s` %s\n", prefix, name, change.Symbol, change.Kind)
+}
@@ -0,0 +1,134 @@
+package butler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/hhhapz/doc"
+
+	"github.com/disgoorg/disgo-butler/common"
+	"github.com/disgoorg/disgo-butler/gocode"
+	"github.com/disgoorg/disgo-butler/godoc"
+)
+
+// maxRunnableExamples is the maximum number of "run example" options in the docs_action menu.
+const maxRunnableExamples = 5
+
+var ErrExampleNotRunnable = errors.New("only examples of standard library packages can be run")
+
+// DocsExamples returns the examples of the package or the symbol of the query.
+func DocsExamples(pkg doc.Package, query string) []doc.Example {
+	if query == "" || query == PkgInfo {
+		return pkg.Examples
+	}
+	values := strings.Split(strings.ToLower(query), ".")
+	if t, ok := pkg.Types[values[0]]; ok {
+		if len(values) > 1 {
+			return t.Methods[values[1]].Examples
+		}
+		return t.Examples
+	}
+	return pkg.Functions[values[0]].Examples
+}
+
+// IsRunnable reports whether the examples of the package can be run. Piston has no access to other modules, so
+// only standard library examples are runnable.
+func IsRunnable(pkg doc.Package) bool {
+	return modulePrefix(pkg.URL) == "std"
+}
+
+// ExampleProgram turns the example into a program. Examples which are not complete programs already are wrapped
+// in a main function.
+func ExampleProgram(pkg doc.Package, example doc.Example) (string, error) {
+	if !IsRunnable(pkg) {
+		return "", ErrExampleNotRunnable
+	}
+	if gocode.IsProgram(example.Code) {
+		return example.Code, nil
+	}
+	return gocode.WrapMain(example.Code, map[string]string{pkg.Name: pkg.URL})
+}
+
+// RunExample runs the example the same way as the eval command.
+func (b *Butler) RunExample(pkg doc.Package, example doc.Example) (string, error) {
+	program, err := ExampleProgram(pkg, example)
+	if err != nil {
+		return "", err
+	}
+	result := b.Eval(context.Background(), EvalInput{Language: "go", Files: []EvalFile{{Content: program}}})
+	if result.Err != nil {
+		return "", result.Err
+	}
+	if result.Status() == EvalStatusCompileError {
+		return stripANSI(result.Exec.Compile.Output), nil
+	}
+	return stripANSI(result.Exec.Run.Output), nil
+}
+
+// GetExampleEmbed shows the output of an example next to its expected output.
+func GetExampleEmbed(pkg doc.Package, example doc.Example, output string, err error) discord.Embed {
+	embed := discord.Embed{
+		Title: fmt.Sprintf("%s: %s", pkg.URL, example.Name),
+		Color: embedColor,
+	}
+	if err != nil {
+		embed.Description = "Failed to run example: " + err.Error()
+		embed.Color = common.ColorError
+		return embed
+	}
+
+	status := "No expected output"
+	if example.Output != "" {
+		if matchesOutput(output, example.Output, IsUnorderedExample(example)) {
+			status = "✅ Output matches"
+			embed.Color = common.ColorSuccess
+		} else {
+			status = "❌ Output does not match"
+			embed.Color = common.ColorError
+		}
+	}
+	embed.Description = status
+	embed.Fields = []discord.EmbedField{
+		{
+			Name:  "Output",
+			Value: truncateBlock(codeBlock("", output), 1024),
+		},
+	}
+	if example.Output != "" {
+		embed.Fields = append(embed.Fields, discord.EmbedField{
+			Name:  "Expected",
+			Value: truncateBlock(codeBlock("", example.Output), 1024),
+		})
+	}
+	return embed
+}
+
+// IsUnorderedExample reports whether the output of the example may be printed in any order. Only the godoc backend
+// knows this, examples of other backends are compared in order.
+func IsUnorderedExample(example doc.Example) bool {
+	return strings.HasSuffix(strings.TrimSpace(example.Code), godoc.UnorderedOutputComment)
+}
+
+// matchesOutput compares the output like go test does. Unordered output is compared in sorted order.
+func matchesOutput(output string, expected string, unordered bool) bool {
+	got := outputLines(output)
+	want := outputLines(expected)
+	if !unordered {
+		return strings.Join(got, "\n") == strings.Join(want, "\n")
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	return strings.Join(got, "\n") == strings.Join(want, "\n")
+}
+
+func outputLines(output string) []string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	return lines
+}
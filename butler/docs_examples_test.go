@@ -0,0 +1,28 @@
+package butler
+
+import (
+	"testing"
+
+	"github.com/hhhapz/doc"
+)
+
+func TestMatchesOutput(t *testing.T) {
+	ordered := doc.Example{Code: `fmt.Println("a")`, Output: "a\nb\n"}
+	unordered := doc.Example{Code: "fmt.Println(\"a\")\n\n// Unordered output:", Output: "a\nb\n"}
+
+	tests := []struct {
+		example doc.Example
+		output  string
+		want    bool
+	}{
+		{example: ordered, output: "a\nb\n", want: true},
+		{example: ordered, output: "b\na\n", want: false},
+		{example: unordered, output: "b\na\n", want: true},
+		{example: unordered, output: "a\nc\n", want: false},
+	}
+	for _, tt := range tests {
+		if got := matchesOutput(tt.output, tt.example.Output, IsUnorderedExample(tt.example)); got != tt.want {
+			t.Errorf("matchesOutput(%q, %q) = %t, want %t", tt.output, tt.example.Output, got, tt.want)
+		}
+	}
+}
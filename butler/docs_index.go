@@ -0,0 +1,106 @@
+package butler
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hhhapz/doc"
+	"github.com/lithammer/fuzzysearch/fuzzy"
+)
+
+// SymbolIndex allows fast prefix and fuzzy lookups of the types, methods and functions of a package.
+type SymbolIndex struct {
+	// symbols are all symbols of the package sorted by their lowercase name.
+	symbols []string
+	// keys are the lowercase symbols and method names sorted for prefix lookups.
+	keys []symbolKey
+}
+
+type symbolKey struct {
+	key    string
+	symbol int
+}
+
+func NewSymbolIndex(pkg doc.Package) *SymbolIndex {
+	var symbols []string
+	for _, t := range pkg.Types {
+		symbols = append(symbols, t.Name)
+		for _, m := range t.Methods {
+			symbols = append(symbols, t.Name+"."+m.Name)
+		}
+	}
+	for _, f := range pkg.Functions {
+		symbols = append(symbols, f.Name)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		return strings.ToLower(symbols[i]) < strings.ToLower(symbols[j])
+	})
+
+	keys := make([]symbolKey, 0, len(symbols))
+	for i, symbol := range symbols {
+		lower := strings.ToLower(symbol)
+		keys = append(keys, symbolKey{key: lower, symbol: i})
+		if _, method, ok := strings.Cut(lower, "."); ok {
+			keys = append(keys, symbolKey{key: method, symbol: i})
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].key < keys[j].key
+	})
+
+	return &SymbolIndex{
+		symbols: symbols,
+		keys:    keys,
+	}
+}
+
+// Len returns the number of symbols in the index.
+func (x *SymbolIndex) Len() int {
+	return len(x.symbols)
+}
+
+// Lookup returns up to limit symbols matching the query. Prefix matches of the symbol or method name come first
+// ordered by length, followed by fuzzy matches ordered by their rank.
+func (x *SymbolIndex) Lookup(query string, limit int) []string {
+	if query == "" {
+		if len(x.symbols) < limit {
+			limit = len(x.symbols)
+		}
+		return x.symbols[:limit]
+	}
+	query = strings.ToLower(query)
+
+	seen := map[int]struct{}{}
+	var prefixMatches []int
+	for i := sort.Search(len(x.keys), func(i int) bool { return x.keys[i].key >= query }); i < len(x.keys) && strings.HasPrefix(x.keys[i].key, query); i++ {
+		if _, ok := seen[x.keys[i].symbol]; ok {
+			continue
+		}
+		seen[x.keys[i].symbol] = struct{}{}
+		prefixMatches = append(prefixMatches, x.keys[i].symbol)
+	}
+	sort.SliceStable(prefixMatches, func(i, j int) bool {
+		return len(x.symbols[prefixMatches[i]]) < len(x.symbols[prefixMatches[j]])
+	})
+
+	results := make([]string, 0, limit)
+	for _, i := range prefixMatches {
+		if len(results) == limit {
+			return results
+		}
+		results = append(results, x.symbols[i])
+	}
+
+	ranks := fuzzy.RankFindFold(query, x.symbols)
+	sort.Sort(ranks)
+	for _, rank := range ranks {
+		if len(results) == limit {
+			break
+		}
+		if _, ok := seen[rank.OriginalIndex]; ok {
+			continue
+		}
+		results = append(results, rank.Target)
+	}
+	return results
+}
@@ -0,0 +1,44 @@
+package butler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hhhapz/doc"
+)
+
+func TestSymbolIndexLookup(t *testing.T) {
+	index := NewSymbolIndex(doc.Package{
+		Types: map[string]doc.Type{
+			"client": {
+				Name: "Client",
+				Methods: map[string]doc.Method{
+					"close":  {For: "Client", Function: doc.Function{Name: "Close"}},
+					"caches": {For: "Client", Function: doc.Function{Name: "Caches"}},
+				},
+			},
+			"config": {Name: "Config"},
+		},
+		Functions: map[string]doc.Function{
+			"new":       {Name: "New"},
+			"newclient": {Name: "NewClient"},
+		},
+	})
+
+	tests := []struct {
+		query    string
+		limit    int
+		expected []string
+	}{
+		{"", 3, []string{"Client", "Client.Caches", "Client.Close"}},
+		{"new", 25, []string{"New", "NewClient"}},
+		{"clo", 25, []string{"Client.Close"}},
+		{"c", 3, []string{"Client", "Config", "Client.Close"}},
+		{"nclient", 25, []string{"NewClient"}},
+	}
+	for _, tt := range tests {
+		if results := index.Lookup(tt.query, tt.limit); !reflect.DeepEqual(results, tt.expected) {
+			t.Errorf("Lookup(%q, %d): expected %v, got %v", tt.query, tt.limit, tt.expected, results)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package butler
+
+import (
+	"go/doc/comment"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hhhapz/doc"
+
+	"github.com/disgoorg/disgo-butler/godoc"
+)
+
+const (
+	pkgGoDevURL = "https://pkg.go.dev"
+	minBlockLen = 16
+)
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"~", `\~`,
+	"`", "\\`",
+	"|", `\|`,
+)
+
+// RenderComment renders a doc comment as Discord markdown. Every returned string is one block like a paragraph,
+// code block or list, so callers can truncate between blocks. Doc links like [Client] are resolved against pkg
+// and link to pkg.go.dev.
+func RenderComment(pkg doc.Package, c doc.Comment) []string {
+	r := commentRenderer{
+		pkg: pkg,
+		parser: &comment.Parser{
+			LookupPackage: func(name string) (string, bool) {
+				if name == pkg.Name {
+					return pkg.URL, true
+				}
+				return "", false
+			},
+			LookupSym: func(recv string, name string) bool {
+				if recv == "" {
+					_, isType := pkg.Types[strings.ToLower(name)]
+					_, isFunc := pkg.Functions[strings.ToLower(name)]
+					return isType || isFunc
+				}
+				t, ok := pkg.Types[strings.ToLower(recv)]
+				if !ok {
+					return false
+				}
+				_, ok = t.Methods[strings.ToLower(name)]
+				return ok
+			},
+		},
+	}
+
+	blocks := make([]string, 0, len(c))
+	for _, note := range c {
+		switch n := note.(type) {
+		case doc.Heading:
+			blocks = append(blocks, "**"+r.inline(string(n))+"**")
+		case doc.Paragraph:
+			blocks = append(blocks, r.inline(string(n)))
+		case doc.Pre:
+			blocks = append(blocks, codeBlock("go", string(n)))
+		case godoc.List:
+			lines := make([]string, len(n.Items))
+			for i, item := range n.Items {
+				marker := "-"
+				if n.Ordered {
+					marker = strconv.Itoa(i+1) + "."
+				}
+				lines[i] = marker + " " + r.inline(item)
+			}
+			blocks = append(blocks, strings.Join(lines, "\n"))
+		default:
+			blocks = append(blocks, markdownEscaper.Replace(note.Text()))
+		}
+	}
+	return blocks
+}
+
+type commentRenderer struct {
+	pkg    doc.Package
+	parser *comment.Parser
+}
+
+// inline parses the text of a single note to resolve its links.
+func (r commentRenderer) inline(text string) string {
+	parsed := r.parser.Parse(text)
+	parts := make([]string, 0, len(parsed.Content))
+	for _, block := range parsed.Content {
+		switch b := block.(type) {
+		case *comment.Paragraph:
+			parts = append(parts, renderText(r.pkg, b.Text))
+		case *comment.Heading:
+			parts = append(parts, renderText(r.pkg, b.Text))
+		case *comment.Code:
+			parts = append(parts, codeBlock("go", b.Text))
+		case *comment.List:
+			for _, item := range b.Items {
+				for _, content := range item.Content {
+					if paragraph, ok := content.(*comment.Paragraph); ok {
+						parts = append(parts, renderText(r.pkg, paragraph.Text))
+					}
+				}
+			}
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func renderText(pkg doc.Package, texts []comment.Text) string {
+	var sb strings.Builder
+	for _, text := range texts {
+		switch t := text.(type) {
+		case comment.Plain:
+			sb.WriteString(markdownEscaper.Replace(string(t)))
+		case comment.Italic:
+			sb.WriteString("*" + markdownEscaper.Replace(string(t)) + "*")
+		case *comment.Link:
+			if t.Auto {
+				sb.WriteString(t.URL)
+				continue
+			}
+			sb.WriteString("[" + renderText(pkg, t.Text) + "](" + t.URL + ")")
+		case *comment.DocLink:
+			importPath := t.ImportPath
+			if importPath == "" {
+				importPath = pkg.URL
+			}
+			link := comment.DocLink{ImportPath: importPath, Recv: t.Recv, Name: t.Name}
+			sb.WriteString("[`" + plainText(t.Text) + "`](" + link.DefaultURL(pkgGoDevURL) + ")")
+		}
+	}
+	return sb.String()
+}
+
+func plainText(texts []comment.Text) string {
+	var sb strings.Builder
+	for _, text := range texts {
+		switch t := text.(type) {
+		case comment.Plain:
+			sb.WriteString(string(t))
+		case comment.Italic:
+			sb.WriteString(string(t))
+		case *comment.Link:
+			sb.WriteString(plainText(t.Text))
+		case *comment.DocLink:
+			sb.WriteString(plainText(t.Text))
+		}
+	}
+	return sb.String()
+}
+
+// codeBlock wraps code in a fenced code block. Fences inside the code are broken up with a zero width space.
+func codeBlock(lang string, code string) string {
+	code = strings.ReplaceAll(code, "```", "`\u200b``")
+	return "```" + lang + "\n" + strings.TrimSuffix(code, "\n") + "\n```"
+}
+
+// JoinBlocks joins as many markdown blocks as fit into maxLen bytes. If not all blocks fit, "…" is appended and
+// true is returned. A single block which is too long on its own is cut without breaking runes or code fences.
+func JoinBlocks(blocks []string, maxLen int) (string, bool) {
+	const (
+		separator = "\n\n"
+		ellipsis  = "…"
+	)
+	var sb strings.Builder
+	for i, block := range blocks {
+		sep := ""
+		if i > 0 {
+			sep = separator
+		}
+		remaining := maxLen - sb.Len() - len(sep)
+		if i < len(blocks)-1 {
+			remaining -= len(separator) + len(ellipsis)
+		}
+		if len(block) <= remaining {
+			sb.WriteString(sep + block)
+			continue
+		}
+		if i == 0 {
+			return truncateBlock(block, maxLen), true
+		}
+		sb.WriteString(separator + ellipsis)
+		return sb.String(), true
+	}
+	return sb.String(), false
+}
+
+// truncateBlock cuts a single markdown block to maxLen bytes and keeps code blocks closed. If there is no room
+// for a meaningful part of the block, an empty string is returned.
+func truncateBlock(block string, maxLen int) string {
+	if len(block) <= maxLen {
+		return block
+	}
+	if maxLen < minBlockLen {
+		return ""
+	}
+	const fence = "\n…```"
+	if strings.HasPrefix(block, "```") && strings.HasSuffix(block, "```") {
+		body := strings.TrimSuffix(block, "```")
+		body = truncateString(body, maxLen-len(fence))
+		if i := strings.LastIndex(body, "\n"); i > strings.Index(body, "\n") {
+			body = body[:i]
+		}
+		return body + fence
+	}
+	return truncateString(block, maxLen-len("…")) + "…"
+}
+
+// truncateString cuts s to at most maxLen bytes without splitting a rune.
+func truncateString(s string, maxLen int) string {
+	if maxLen <= 0 {
+		return ""
+	}
+	if len(s) <= maxLen {
+		return s
+	}
+	for maxLen > 0 && !utf8.RuneStart(s[maxLen]) {
+		maxLen--
+	}
+	return s[:maxLen]
+}
+
+// unwrapCodeBlock returns the code of a code block created by codeBlock.
+func unwrapCodeBlock(block string) (string, bool) {
+	if !strings.HasPrefix(block, "```") || !strings.HasSuffix(block, "\n```") {
+		return "", false
+	}
+	_, code, ok := strings.Cut(strings.TrimSuffix(block, "\n```"), "\n")
+	if !ok {
+		return "", true
+	}
+	return strings.ReplaceAll(code, "`\u200b``", "```"), true
+}
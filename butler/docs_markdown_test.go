@@ -0,0 +1,68 @@
+package butler
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/hhhapz/doc"
+
+	"github.com/disgoorg/disgo-butler/godoc"
+)
+
+func TestRenderComment(t *testing.T) {
+	pkg := doc.Package{
+		URL:  "example.com/mod",
+		Name: "mod",
+		Types: map[string]doc.Type{
+			"client": {
+				Name: "Client",
+				Methods: map[string]doc.Method{
+					"do": {For: "Client", Function: doc.Function{Name: "Do"}},
+				},
+			},
+		},
+	}
+	c := doc.Comment{
+		doc.Heading("Usage"),
+		doc.Paragraph("Create a [Client] and call [Client.Do] or use [io.Reader] with *pointers*."),
+		doc.Pre("c := mod.Client{}\nc.Do()"),
+		godoc.List{Items: []string{"first", "second"}},
+	}
+
+	expected := []string{
+		"**Usage**",
+		"Create a [`Client`](https://pkg.go.dev/example.com/mod#Client) and call [`Client.Do`](https://pkg.go.dev/example.com/mod#Client.Do) or use [`io.Reader`](https://pkg.go.dev/io#Reader) with \\*pointers\\*.",
+		"```go\nc := mod.Client{}\nc.Do()\n```",
+		"- first\n- second",
+	}
+	blocks := RenderComment(pkg, c)
+	if len(blocks) != len(expected) {
+		t.Fatalf("expected %d blocks, got %d: %q", len(expected), len(blocks), blocks)
+	}
+	for i := range expected {
+		if blocks[i] != expected[i] {
+			t.Errorf("block %d:\nexpected %q\ngot      %q", i, expected[i], blocks[i])
+		}
+	}
+}
+
+func TestJoinBlocks(t *testing.T) {
+	blocks := []string{"first paragraph", "```go\nfunc main() {}\n```", "last"}
+
+	s, more := JoinBlocks(blocks, 100)
+	if more || s != strings.Join(blocks, "\n\n") {
+		t.Errorf("expected all blocks, got %q", s)
+	}
+
+	s, more = JoinBlocks(blocks, 30)
+	if !more || s != "first paragraph\n\n…" {
+		t.Errorf("expected cut after first block, got %q", s)
+	}
+
+	code := "```go\n" + strings.Repeat("ä := 1\n", 100) + "```"
+	s, more = JoinBlocks([]string{code}, 101)
+	if !more || len(s) > 101 || !utf8.ValidString(s) || !strings.HasSuffix(s, "\n…```") {
+		t.Errorf("expected closed code block within limit, got %q", s)
+	}
+}
@@ -0,0 +1,142 @@
+package butler
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hhhapz/doc"
+	"github.com/lithammer/fuzzysearch/fuzzy"
+)
+
+// DocsSearchResult is a symbol found by SearchSymbols.
+type DocsSearchResult struct {
+	Package string
+	Name    string
+	Symbol  string
+	// Tier is 0 for exact matches, 1 for prefix matches and 2 for fuzzy matches.
+	Tier int
+	// Score ranks results within their tier, lower scores are better.
+	Score float64
+}
+
+func NewDocsUsage() *DocsUsage {
+	return &DocsUsage{
+		uses: map[string]int{},
+	}
+}
+
+// DocsUsage counts how often packages are looked up, which is used to rank search results.
+type DocsUsage struct {
+	mu   sync.Mutex
+	uses map[string]int
+}
+
+func (u *DocsUsage) Increment(pkg string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.uses[pkg]++
+}
+
+func (u *DocsUsage) Get(pkg string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.uses[pkg]
+}
+
+// SearchSymbols looks up a symbol in all cached packages. Results are ranked by how well they match, and results
+// which match equally well by their fuzzy score and how often their package is looked up.
+func (b *Butler) SearchSymbols(query string, limit int) []DocsSearchResult {
+	query = strings.ToLower(query)
+	var results []DocsSearchResult
+	b.DocClient.WithCache(func(cache map[string]*doc.CachedPackage) {
+		seen := map[string]struct{}{}
+		for _, pkg := range cache {
+			if _, ok := seen[pkg.URL]; ok {
+				continue
+			}
+			seen[pkg.URL] = struct{}{}
+			results = append(results, searchPackage(pkg.Package, query, b.DocsUsage.Get(pkg.URL))...)
+		}
+	})
+
+	sortSearchResults(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func sortSearchResults(results []DocsSearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Tier != results[j].Tier {
+			return results[i].Tier < results[j].Tier
+		}
+		if results[i].Score != results[j].Score {
+			return results[i].Score < results[j].Score
+		}
+		return results[i].Package+results[i].Symbol < results[j].Package+results[j].Symbol
+	})
+}
+
+func searchPackage(pkg doc.Package, query string, uses int) []DocsSearchResult {
+	var results []DocsSearchResult
+	add := func(symbol string, name string) {
+		tier, score, ok := symbolScore(query, symbol, name)
+		if !ok {
+			return
+		}
+		results = append(results, DocsSearchResult{
+			Package: pkg.URL,
+			Name:    pkg.Name,
+			Symbol:  symbol,
+			Tier:    tier,
+			// popular packages only rank higher within the tier, so they never outrank better matches
+			Score: score / (1 + math.Log1p(float64(uses))),
+		})
+	}
+
+	for _, t := range pkg.Types {
+		add(t.Name, t.Name)
+		for _, m := range t.Methods {
+			add(t.Name+"."+m.Name, m.Name)
+		}
+	}
+	for _, f := range pkg.Functions {
+		add(f.Name, f.Name)
+	}
+	return results
+}
+
+// symbolScore returns the tier and the score within the tier of a symbol for the query. Lower tiers and scores are
+// better. Exact matches are in tier 0, prefix matches in tier 1 and fuzzy matches in tier 2.
+func symbolScore(query string, symbol string, name string) (int, float64, bool) {
+	lowerName := strings.ToLower(name)
+	lowerSymbol := strings.ToLower(symbol)
+	switch {
+	case lowerName == query || lowerSymbol == query:
+		return 0, 0, true
+	case strings.HasPrefix(lowerName, query) || strings.HasPrefix(lowerSymbol, query):
+		return 1, float64(len(lowerName) - len(query)), true
+	}
+
+	rank := fuzzy.RankMatchFold(query, name)
+	if symbolRank := fuzzy.RankMatchFold(query, symbol); symbolRank != -1 && (rank == -1 || symbolRank < rank) {
+		rank = symbolRank
+	}
+	if rank == -1 {
+		return 0, 0, false
+	}
+	return 2, float64(rank), true
+}
+
+// LoadSubpackages loads all subpackages of the package into the docs cache, so they can be searched.
+func (b *Butler) LoadSubpackages(ctx context.Context, pkg doc.Package) {
+	for _, subpackage := range pkg.Subpackages {
+		if _, err := b.DocClient.Search(ctx, subpackage); err != nil {
+			b.Logger.Errorf("Failed to load subpackage %s: %s", subpackage, err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package butler
+
+import (
+	"testing"
+
+	"github.com/hhhapz/doc"
+)
+
+func TestSearchResultsRankMatchBeforePopularity(t *testing.T) {
+	popular := doc.Package{
+		URL:       "example.com/popular",
+		Name:      "popular",
+		Functions: map[string]doc.Function{"newclient": {Name: "NewClient"}},
+	}
+	unpopular := doc.Package{
+		URL:       "example.com/unpopular",
+		Name:      "unpopular",
+		Functions: map[string]doc.Function{"clientoption": {Name: "ClientOption"}},
+	}
+
+	var results []DocsSearchResult
+	results = append(results, searchPackage(popular, "client", 1000)...)
+	results = append(results, searchPackage(unpopular, "client", 0)...)
+	sortSearchResults(results)
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", results)
+	}
+	if results[0].Symbol != "ClientOption" || results[1].Symbol != "NewClient" {
+		t.Errorf("expected prefix match before popular fuzzy match, got %+v", results)
+	}
+}
+
+func TestSearchResultsRankPopularWithinTier(t *testing.T) {
+	a := doc.Package{URL: "example.com/a", Name: "a", Functions: map[string]doc.Function{"newclient": {Name: "NewClient"}}}
+	b := doc.Package{URL: "example.com/b", Name: "b", Functions: map[string]doc.Function{"newclient": {Name: "NewClient"}}}
+
+	var results []DocsSearchResult
+	results = append(results, searchPackage(a, "newc", 0)...)
+	results = append(results, searchPackage(b, "newc", 10)...)
+	sortSearchResults(results)
+
+	if len(results) != 2 || results[0].Package != "example.com/b" {
+		t.Errorf("expected popular package first, got %+v", results)
+	}
+}
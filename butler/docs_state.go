@@ -0,0 +1,216 @@
+package butler
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/disgoorg/snowflake/v2"
+
+	"github.com/disgoorg/disgo-butler/godoc"
+)
+
+const (
+	// docsStateTTL is how long the state of a docs message is kept after it was last used.
+	docsStateTTL = 24 * time.Hour
+	// maxDocsHistory is the maximum number of states the back button can go back to.
+	maxDocsHistory = 10
+	// maxDocsStates is the maximum number of docs messages whose state is kept. The least recently used states are
+	// dropped first, those messages restore their state from the embed title.
+	maxDocsStates = 10000
+)
+
+// DocsSection is a section of a docs embed which can be expanded.
+type DocsSection int
+
+const (
+	DocsSectionSignature DocsSection = 1 << iota
+	DocsSectionMethods
+	DocsSectionComment
+	DocsSectionExamples
+	DocsSectionImplementations
+	DocsSectionUsages
+)
+
+var docsSections = []struct {
+	section DocsSection
+	name    string
+	label   string
+}{
+	{DocsSectionSignature, "signature", "signature"},
+	{DocsSectionMethods, "methods", "methods"},
+	{DocsSectionComment, "comment", "comment"},
+	{DocsSectionExamples, "examples", "examples"},
+	{DocsSectionImplementations, "implementations", "implementations"},
+	{DocsSectionUsages, "usages", "used by"},
+}
+
+// ParseDocsSection returns the section with the given name as used in the docs_action select menu.
+func ParseDocsSection(name string) (DocsSection, bool) {
+	for _, s := range docsSections {
+		if s.name == name {
+			return s.section, true
+		}
+	}
+	return 0, false
+}
+
+// DocsState is everything needed to render a docs message.
+type DocsState struct {
+	// Package is the import path of the package without version.
+	Package string
+	// Version is the requested version of the package or empty for the latest version.
+	Version string
+	// Alias is the docs alias the package was looked up with.
+	Alias    string
+	Query    string
+	Expanded DocsSection
+	// Page is the current page of the methods section.
+	Page int
+	// History contains the previous states when navigating to related symbols.
+	History []DocsState
+}
+
+// NewDocsState creates the state of a new docs message. module may contain a version suffix and can be an alias.
+func NewDocsState(aliases map[string]string, module string, query string) DocsState {
+	pkgPath, version := godoc.SplitVersion(module)
+	var alias string
+	if aliasModule, ok := aliases[pkgPath]; ok {
+		alias = pkgPath
+		pkgPath = aliasModule
+	}
+	return DocsState{
+		Package: pkgPath,
+		Version: version,
+		Alias:   alias,
+		Query:   query,
+	}
+}
+
+// DocsStateFromTitle restores the state from the title of a docs embed. This is used when the state of a
+// message has expired, so only the package, version and query are restored.
+func DocsStateFromTitle(title string) DocsState {
+	module, query, _ := strings.Cut(title, ": ")
+	pkgPath, version := godoc.SplitVersion(module)
+	return DocsState{
+		Package: pkgPath,
+		Version: version,
+		Query:   query,
+	}
+}
+
+// SearchQuery returns the query for the doc.Searcher including the version.
+func (s DocsState) SearchQuery() string {
+	if s.Version != "" {
+		return s.Package + "@" + s.Version
+	}
+	return s.Package
+}
+
+func (s DocsState) Expands(section DocsSection) bool {
+	return s.Expanded&section != 0
+}
+
+func (s *DocsState) Expand(section DocsSection) {
+	s.Expanded |= section
+}
+
+func (s *DocsState) Collapse(section DocsSection) {
+	s.Expanded &^= section
+	if section == DocsSectionMethods {
+		s.Page = 0
+	}
+}
+
+// Navigate returns the state of a related symbol. The current state is pushed onto the history of the new state.
+// The version is kept if the symbol belongs to the same module.
+func (s DocsState) Navigate(ref DocsRef, sameModule bool) DocsState {
+	history := make([]DocsState, 0, len(s.History)+1)
+	history = append(history, s.History...)
+	history = append(history, DocsState{
+		Package:  s.Package,
+		Version:  s.Version,
+		Alias:    s.Alias,
+		Query:    s.Query,
+		Expanded: s.Expanded,
+		Page:     s.Page,
+	})
+	if len(history) > maxDocsHistory {
+		history = history[len(history)-maxDocsHistory:]
+	}
+	state := DocsState{
+		Package: ref.Package,
+		Query:   ref.Symbol,
+		History: history,
+	}
+	if sameModule {
+		state.Version = s.Version
+	}
+	return state
+}
+
+// Back returns the previous state and whether there was one.
+func (s DocsState) Back() (DocsState, bool) {
+	if len(s.History) == 0 {
+		return s, false
+	}
+	previous := s.History[len(s.History)-1]
+	previous.History = s.History[:len(s.History)-1]
+	return previous, true
+}
+
+func NewDocsStates() *DocsStates {
+	return &DocsStates{
+		states: map[snowflake.ID]docsStateEntry{},
+	}
+}
+
+// DocsStates stores the state of docs messages keyed by the id of the interaction which created the message.
+type DocsStates struct {
+	mu     sync.Mutex
+	states map[snowflake.ID]docsStateEntry
+}
+
+type docsStateEntry struct {
+	state    DocsState
+	lastUsed time.Time
+}
+
+func (s *DocsStates) Get(interactionID snowflake.ID) (DocsState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.states[interactionID]
+	if !ok || time.Since(entry.lastUsed) > docsStateTTL {
+		return DocsState{}, false
+	}
+	return entry.state, true
+}
+
+func (s *DocsStates) Set(interactionID snowflake.ID, state DocsState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var (
+		oldestID snowflake.ID
+		oldest   time.Time
+	)
+	for id, entry := range s.states {
+		if now.Sub(entry.lastUsed) > docsStateTTL {
+			delete(s.states, id)
+			continue
+		}
+		if id != interactionID && (oldest.IsZero() || entry.lastUsed.Before(oldest)) {
+			oldestID, oldest = id, entry.lastUsed
+		}
+	}
+	if _, ok := s.states[interactionID]; !ok && len(s.states) >= maxDocsStates {
+		delete(s.states, oldestID)
+	}
+	s.states[interactionID] = docsStateEntry{state: state, lastUsed: now}
+}
+
+func (s *DocsStates) Delete(interactionID snowflake.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, interactionID)
+}
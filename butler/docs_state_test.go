@@ -0,0 +1,83 @@
+package butler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/disgoorg/snowflake/v2"
+)
+
+func TestDocsStateNavigate(t *testing.T) {
+	state := DocsState{Package: "example.com/mod", Version: "v1.0.0", Query: "Client"}
+	state.Expand(DocsSectionMethods)
+	state.Expand(DocsSectionUsages)
+	state.Page = 2
+
+	related := state.Navigate(DocsRef{Package: "example.com/mod/sub", Symbol: "Config"}, true)
+	if related.Package != "example.com/mod/sub" || related.Query != "Config" || related.Version != "v1.0.0" {
+		t.Errorf("unexpected related state %+v", related)
+	}
+	if related.Expanded != 0 || related.Page != 0 {
+		t.Errorf("expected related state to start collapsed, got %+v", related)
+	}
+
+	related.Expand(DocsSectionMethods)
+	related.Page = 1
+	other := related.Navigate(DocsRef{Package: "net/http", Symbol: "Client"}, false)
+	if other.Version != "" {
+		t.Errorf("expected version of other module to be dropped, got %q", other.Version)
+	}
+
+	back, ok := other.Back()
+	if !ok {
+		t.Fatal("expected previous state")
+	}
+	if back.Package != related.Package || back.Expanded != related.Expanded || back.Page != 1 {
+		t.Errorf("expected %+v, got %+v", related, back)
+	}
+
+	back, ok = back.Back()
+	if !ok {
+		t.Fatal("expected previous state")
+	}
+	back.History = nil
+	if !reflect.DeepEqual(back, state) {
+		t.Errorf("expected %+v, got %+v", state, back)
+	}
+	back.Collapse(DocsSectionMethods)
+	if back.Page != 0 || !back.Expands(DocsSectionUsages) {
+		t.Errorf("expected collapsing methods to only reset the page, got %+v", back)
+	}
+
+	if _, ok = back.Back(); ok {
+		t.Error("expected no previous state")
+	}
+}
+
+func TestDocsStateHistoryLimit(t *testing.T) {
+	state := DocsState{Package: "example.com/mod", Query: "T0"}
+	for i := 0; i < maxDocsHistory+5; i++ {
+		state = state.Navigate(DocsRef{Package: "example.com/mod", Symbol: "T"}, true)
+	}
+	if len(state.History) != maxDocsHistory {
+		t.Errorf("expected %d states in history, got %d", maxDocsHistory, len(state.History))
+	}
+}
+
+func TestDocsStatesLimit(t *testing.T) {
+	states := NewDocsStates()
+	for i := 1; i <= maxDocsStates+5; i++ {
+		states.Set(snowflake.ID(i), DocsState{Package: "example.com/mod"})
+	}
+	if len(states.states) != maxDocsStates {
+		t.Errorf("expected %d states, got %d", maxDocsStates, len(states.states))
+	}
+	if _, ok := states.Get(snowflake.ID(maxDocsStates + 5)); !ok {
+		t.Error("expected latest state to be kept")
+	}
+
+	states.Set(snowflake.ID(maxDocsStates+5), DocsState{Package: "example.com/other"})
+	if state, _ := states.Get(snowflake.ID(maxDocsStates + 5)); state.Package != "example.com/other" || len(states.states) != maxDocsStates {
+		t.Errorf("expected existing state to be replaced, got %+v and %d states", state, len(states.states))
+	}
+}
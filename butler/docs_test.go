@@ -0,0 +1,37 @@
+package butler
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hhhapz/doc"
+)
+
+func TestFormatRefs(t *testing.T) {
+	refs := make([]DocsRef, maxDocsRefs+5)
+	for i := range refs {
+		refs[i] = DocsRef{Package: "example.com/mod", Name: "mod", Symbol: "T" + strconv.Itoa(i)}
+	}
+	s := FormatRefs("Implementations", refs)
+	if n := strings.Count(s, "• "); n != maxDocsRefs {
+		t.Errorf("expected %d refs, got %d", maxDocsRefs, n)
+	}
+	if !strings.HasSuffix(s, "… and 5 more\n") {
+		t.Errorf("expected remaining refs to be counted, got %q", s)
+	}
+	if s = FormatRefs("Implementations", refs[:2]); strings.Contains(s, "more") {
+		t.Errorf("unexpected remaining refs in %q", s)
+	}
+}
+
+func TestResolveRefStd(t *testing.T) {
+	pkg := doc.Package{URL: "example.com/mod", Name: "mod"}
+	ref, ok := resolveRef(pkg, "http", "Client", nil)
+	if !ok || ref.Package != "net/http" || ref.Name != "http" {
+		t.Errorf("expected net/http ref, got %+v, %t", ref, ok)
+	}
+	if _, ok = resolveRef(pkg, "template", "Template", nil); ok {
+		t.Error("expected ambiguous package name to not be resolved")
+	}
+}
@@ -0,0 +1,532 @@
+package butler
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+
+	"github.com/hhhapz/doc"
+	"golang.org/x/mod/semver"
+
+	"github.com/disgoorg/disgo-butler/godoc"
+)
+
+// maxEmbedDepth limits how deep embedded fields and interfaces are followed when building method sets.
+const maxEmbedDepth = 3
+
+// DocsRef is a reference to a symbol in a package.
+type DocsRef struct {
+	Package string
+	Name    string
+	Symbol  string
+}
+
+func (r DocsRef) String() string {
+	return r.Name + "." + r.Symbol
+}
+
+// ModulePackages returns all cached packages at the version which belong to the same module as the given package.
+// An empty version is the latest version.
+func (b *Butler) ModulePackages(pkgPath string, version string) []doc.Package {
+	modulePath := b.ModulePath(pkgPath, version)
+	var packages []doc.Package
+	for _, pkg := range b.cachedPackages() {
+		if pkg.version == version && b.ModulePath(pkg.URL, pkg.version) == modulePath {
+			packages = append(packages, pkg.Package)
+		}
+	}
+	return packages
+}
+
+// cachedPackage is a package in the docs cache with the version it was requested at.
+type cachedPackage struct {
+	doc.Package
+	version string
+}
+
+// cachedPackages returns all packages in the docs cache sorted by their import path and version. Packages are
+// deduplicated by their import path and version, as a package can be cached under multiple queries.
+func (b *Butler) cachedPackages() []cachedPackage {
+	var packages []cachedPackage
+	b.DocClient.WithCache(func(cache map[string]*doc.CachedPackage) {
+		seen := map[string]struct{}{}
+		for query, pkg := range cache {
+			_, version := godoc.SplitVersion(query)
+			key := pkg.URL + "@" + version
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			packages = append(packages, cachedPackage{Package: pkg.Package, version: version})
+		}
+	})
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].URL != packages[j].URL {
+			return packages[i].URL < packages[j].URL
+		}
+		return packages[i].version < packages[j].version
+	})
+	return packages
+}
+
+// RelatedSymbols returns all named types referenced in the signature of the symbol. Package names are resolved
+// using the package itself, packages of the same module at the same version, the latest version of all other cached
+// packages and then the standard library.
+func (b *Butler) RelatedSymbols(pkg doc.Package, version string, query string) []DocsRef {
+	var nodes []ast.Node
+	values := strings.Split(strings.ToLower(query), ".")
+	if t, ok := pkg.Types[values[0]]; ok {
+		if len(values) > 1 {
+			if m, ok := t.Methods[values[1]]; ok {
+				if decl := parseFuncDecl(m.Signature); decl != nil {
+					nodes = append(nodes, decl.Recv, decl.Type)
+				}
+			}
+		} else if spec := parseTypeSpec(t.Signature); spec != nil {
+			if spec.TypeParams != nil {
+				nodes = append(nodes, spec.TypeParams)
+			}
+			nodes = append(nodes, spec.Type)
+		}
+	} else if f, ok := pkg.Functions[values[0]]; ok {
+		if decl := parseFuncDecl(f.Signature); decl != nil {
+			nodes = append(nodes, decl.Type)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	packages := b.ModulePackages(pkg.URL, version)
+	modulePath := b.ModulePath(pkg.URL, version)
+	for _, p := range b.cachedPackages() {
+		if p.version == "" && b.ModulePath(p.URL, "") != modulePath {
+			packages = append(packages, p.Package)
+		}
+	}
+
+	var refs []DocsRef
+	seen := map[string]struct{}{}
+	for _, node := range nodes {
+		for _, qualified := range referencedTypes(node, pkg.Name) {
+			name, symbol, _ := strings.Cut(qualified, ".")
+			ref, ok := resolveRef(pkg, name, symbol, packages)
+			if !ok || (ref.Package == pkg.URL && strings.EqualFold(ref.Symbol, query)) {
+				continue
+			}
+			if _, ok = seen[ref.Package+"#"+ref.Symbol]; ok {
+				continue
+			}
+			seen[ref.Package+"#"+ref.Symbol] = struct{}{}
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// resolveRef resolves a package name and symbol as written in a signature of pkg to the package declaring it.
+func resolveRef(pkg doc.Package, name string, symbol string, packages []doc.Package) (DocsRef, bool) {
+	if name == pkg.Name {
+		if _, ok := pkg.Types[strings.ToLower(symbol)]; !ok {
+			// most likely a type parameter
+			return DocsRef{}, false
+		}
+		return DocsRef{Package: pkg.URL, Name: pkg.Name, Symbol: symbol}, true
+	}
+	for _, p := range packages {
+		if p.Name != name {
+			continue
+		}
+		if _, ok := p.Types[strings.ToLower(symbol)]; ok {
+			return DocsRef{Package: p.URL, Name: p.Name, Symbol: symbol}, true
+		}
+	}
+	if pkgPath, ok := godoc.StdPackagePath(name); ok {
+		return DocsRef{Package: pkgPath, Name: name, Symbol: symbol}, true
+	}
+	return DocsRef{}, false
+}
+
+// ModulePath returns the path of the module the package belongs to at the version. The module path is read from the
+// go.mod of the module if the module source provided the package, otherwise it is guessed from the import path.
+func (b *Butler) ModulePath(pkgPath string, version string) string {
+	if b.ModuleSource != nil {
+		if modulePath, ok := b.ModuleSource.ModulePath(pkgPath, version); ok {
+			return modulePath
+		}
+	}
+	return modulePrefix(pkgPath)
+}
+
+// modulePrefix guesses the module of a package by its first three path elements, including a major version suffix
+// and only the first two for gopkg.in. All standard library packages share the same prefix.
+func modulePrefix(pkgPath string) string {
+	elems := strings.Split(pkgPath, "/")
+	if !strings.Contains(elems[0], ".") {
+		return "std"
+	}
+	n := 3
+	if elems[0] == "gopkg.in" && len(elems) > 1 && strings.Contains(elems[1], ".v") {
+		n = 2
+	}
+	if len(elems) > n && semver.IsValid(elems[n]) && semver.Major(elems[n]) == elems[n] {
+		n++
+	}
+	if len(elems) > n {
+		elems = elems[:n]
+	}
+	return strings.Join(elems, "/")
+}
+
+// IsInterface reports whether the type is declared as an interface.
+func IsInterface(t doc.Type) bool {
+	spec := parseTypeSpec(t.Signature)
+	if spec == nil {
+		return false
+	}
+	_, ok := spec.Type.(*ast.InterfaceType)
+	return ok
+}
+
+// FindImplementations returns all non-interface types in the packages which implement the interface.
+// Method signatures are compared after qualifying all exported identifiers with their package name.
+func FindImplementations(pkg doc.Package, t doc.Type, packages []doc.Package) []DocsRef {
+	methods := interfaceMethods(pkg, t, packages, 0)
+	if len(methods) == 0 {
+		return nil
+	}
+
+	var refs []DocsRef
+	for _, p := range packages {
+		for _, candidate := range p.Types {
+			if IsInterface(candidate) {
+				continue
+			}
+			methodSet, pointer := typeMethods(p, candidate, packages, 0)
+			implements := true
+			usesPointer := false
+			for name, signature := range methods {
+				if methodSet[name] != signature {
+					implements = false
+					break
+				}
+				usesPointer = usesPointer || pointer[name]
+			}
+			if !implements {
+				continue
+			}
+			symbol := candidate.Name
+			if usesPointer {
+				symbol = "*" + symbol
+			}
+			refs = append(refs, DocsRef{Package: p.URL, Name: p.Name, Symbol: symbol})
+		}
+	}
+	sortRefs(refs)
+	return refs
+}
+
+// FindUsages returns all functions and methods in the packages which return the type and which take it as an argument.
+func FindUsages(pkg doc.Package, t doc.Type, packages []doc.Package) (returnedBy []DocsRef, acceptedBy []DocsRef) {
+	qualified := pkg.Name + "." + t.Name
+	check := func(p doc.Package, signature string, symbol string) {
+		decl := parseFuncDecl(signature)
+		if decl == nil {
+			return
+		}
+		ref := DocsRef{Package: p.URL, Name: p.Name, Symbol: symbol}
+		if decl.Type.Results != nil && fieldsReference(decl.Type.Results, p.Name, qualified) {
+			returnedBy = append(returnedBy, ref)
+		}
+		if fieldsReference(decl.Type.Params, p.Name, qualified) {
+			acceptedBy = append(acceptedBy, ref)
+		}
+	}
+
+	for _, p := range packages {
+		for _, f := range p.Functions {
+			check(p, f.Signature, f.Name)
+		}
+		for _, typ := range p.Types {
+			for _, m := range typ.Methods {
+				check(p, m.Signature, typ.Name+"."+m.Name)
+			}
+		}
+	}
+	sortRefs(returnedBy)
+	sortRefs(acceptedBy)
+	return
+}
+
+func sortRefs(refs []DocsRef) {
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Package != refs[j].Package {
+			return refs[i].Package < refs[j].Package
+		}
+		return refs[i].Symbol < refs[j].Symbol
+	})
+}
+
+func fieldsReference(fields *ast.FieldList, pkgName string, qualified string) bool {
+	for _, field := range fields.List {
+		for _, name := range referencedTypes(field.Type, pkgName) {
+			if name == qualified {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func referencedTypes(node ast.Node, pkgName string) []string {
+	var names []string
+	seen := map[string]struct{}{}
+	ast.Inspect(node, func(n ast.Node) bool {
+		var name string
+		switch e := n.(type) {
+		case *ast.SelectorExpr:
+			if x, ok := e.X.(*ast.Ident); ok {
+				name = x.Name + "." + e.Sel.Name
+			}
+		case *ast.Ident:
+			if e.IsExported() {
+				name = pkgName + "." + e.Name
+			}
+		case *ast.Field:
+			// skip parameter and field names
+			if e.Type != nil {
+				for _, ref := range referencedTypes(e.Type, pkgName) {
+					if _, ok := seen[ref]; !ok {
+						seen[ref] = struct{}{}
+						names = append(names, ref)
+					}
+				}
+			}
+			return false
+		}
+		if name == "" {
+			return true
+		}
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			names = append(names, name)
+		}
+		return false
+	})
+	return names
+}
+
+// interfaceMethods returns the method set of the interface including embedded interfaces.
+func interfaceMethods(pkg doc.Package, t doc.Type, packages []doc.Package, depth int) map[string]string {
+	spec := parseTypeSpec(t.Signature)
+	if spec == nil {
+		return nil
+	}
+	iface, ok := spec.Type.(*ast.InterfaceType)
+	if !ok {
+		return nil
+	}
+
+	methods := map[string]string{}
+	for _, field := range iface.Methods.List {
+		if funcType, ok := field.Type.(*ast.FuncType); ok {
+			for _, name := range field.Names {
+				methods[name.Name] = funcTypeString(funcType, pkg.Name)
+			}
+			continue
+		}
+		if depth >= maxEmbedDepth {
+			continue
+		}
+		if embeddedPkg, embedded, ok := resolveType(pkg, field.Type, packages); ok {
+			for name, signature := range interfaceMethods(embeddedPkg, embedded, packages, depth+1) {
+				methods[name] = signature
+			}
+		}
+	}
+	return methods
+}
+
+// typeMethods returns the method set of the type including methods promoted from embedded fields.
+// The second map reports which methods require a pointer receiver.
+func typeMethods(pkg doc.Package, t doc.Type, packages []doc.Package, depth int) (map[string]string, map[string]bool) {
+	methods := map[string]string{}
+	pointer := map[string]bool{}
+
+	if spec := parseTypeSpec(t.Signature); spec != nil && depth < maxEmbedDepth {
+		if structType, ok := spec.Type.(*ast.StructType); ok {
+			for _, field := range structType.Fields.List {
+				if len(field.Names) > 0 {
+					continue
+				}
+				fieldType := field.Type
+				if star, ok := fieldType.(*ast.StarExpr); ok {
+					fieldType = star.X
+				}
+				embeddedPkg, embedded, ok := resolveType(pkg, fieldType, packages)
+				if !ok {
+					continue
+				}
+				if IsInterface(embedded) {
+					for name, signature := range interfaceMethods(embeddedPkg, embedded, packages, depth+1) {
+						methods[name] = signature
+					}
+					continue
+				}
+				embeddedMethods, embeddedPointer := typeMethods(embeddedPkg, embedded, packages, depth+1)
+				for name, signature := range embeddedMethods {
+					methods[name] = signature
+					pointer[name] = embeddedPointer[name] && field.Type == fieldType
+				}
+			}
+		}
+	}
+
+	for _, m := range t.Methods {
+		decl := parseFuncDecl(m.Signature)
+		if decl == nil {
+			continue
+		}
+		methods[m.Name] = funcTypeString(decl.Type, pkg.Name)
+		if decl.Recv != nil && len(decl.Recv.List) > 0 {
+			_, pointer[m.Name] = decl.Recv.List[0].Type.(*ast.StarExpr)
+		}
+	}
+	return methods, pointer
+}
+
+// resolveType looks up the declaration of a named type expression in the package or the other packages.
+func resolveType(pkg doc.Package, expr ast.Expr, packages []doc.Package) (doc.Package, doc.Type, bool) {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		t, ok := pkg.Types[strings.ToLower(e.Name)]
+		return pkg, t, ok
+	case *ast.SelectorExpr:
+		x, ok := e.X.(*ast.Ident)
+		if !ok {
+			return doc.Package{}, doc.Type{}, false
+		}
+		for _, p := range packages {
+			if p.Name != x.Name {
+				continue
+			}
+			if t, ok := p.Types[strings.ToLower(e.Sel.Name)]; ok {
+				return p, t, true
+			}
+		}
+	}
+	return doc.Package{}, doc.Type{}, false
+}
+
+// funcTypeString formats the parameter and result types of a function with all identifiers qualified.
+func funcTypeString(funcType *ast.FuncType, pkgName string) string {
+	s := "(" + fieldListString(funcType.Params, pkgName) + ")"
+	if funcType.Results != nil {
+		s += " (" + fieldListString(funcType.Results, pkgName) + ")"
+	}
+	return s
+}
+
+func fieldListString(fields *ast.FieldList, pkgName string) string {
+	if fields == nil {
+		return ""
+	}
+	var types []string
+	for _, field := range fields.List {
+		typ := typeString(field.Type, pkgName)
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			types = append(types, typ)
+		}
+	}
+	return strings.Join(types, ", ")
+}
+
+func typeString(expr ast.Expr, pkgName string) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if e.IsExported() {
+			return pkgName + "." + e.Name
+		}
+		return e.Name
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok {
+			return x.Name + "." + e.Sel.Name
+		}
+	case *ast.StarExpr:
+		return "*" + typeString(e.X, pkgName)
+	case *ast.ParenExpr:
+		return typeString(e.X, pkgName)
+	case *ast.Ellipsis:
+		return "..." + typeString(e.Elt, pkgName)
+	case *ast.ArrayType:
+		if e.Len == nil {
+			return "[]" + typeString(e.Elt, pkgName)
+		}
+		return "[N]" + typeString(e.Elt, pkgName)
+	case *ast.MapType:
+		return "map[" + typeString(e.Key, pkgName) + "]" + typeString(e.Value, pkgName)
+	case *ast.ChanType:
+		switch e.Dir {
+		case ast.SEND:
+			return "chan<- " + typeString(e.Value, pkgName)
+		case ast.RECV:
+			return "<-chan " + typeString(e.Value, pkgName)
+		}
+		return "chan " + typeString(e.Value, pkgName)
+	case *ast.FuncType:
+		return "func" + funcTypeString(e, pkgName)
+	case *ast.IndexExpr:
+		return typeString(e.X, pkgName) + "[" + typeString(e.Index, pkgName) + "]"
+	case *ast.IndexListExpr:
+		indices := make([]string, len(e.Indices))
+		for i, index := range e.Indices {
+			indices[i] = typeString(index, pkgName)
+		}
+		return typeString(e.X, pkgName) + "[" + strings.Join(indices, ", ") + "]"
+	case *ast.InterfaceType:
+		if e.Methods == nil || len(e.Methods.List) == 0 {
+			return "interface{}"
+		}
+		return "interface{...}"
+	case *ast.StructType:
+		if e.Fields == nil || len(e.Fields.List) == 0 {
+			return "struct{}"
+		}
+		return "struct{...}"
+	}
+	return "?"
+}
+
+func parseFuncDecl(signature string) *ast.FuncDecl {
+	if !strings.HasPrefix(signature, "func") {
+		return nil
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+signature, 0)
+	if err != nil || len(file.Decls) == 0 {
+		return nil
+	}
+	decl, _ := file.Decls[0].(*ast.FuncDecl)
+	return decl
+}
+
+func parseTypeSpec(signature string) *ast.TypeSpec {
+	if !strings.HasPrefix(signature, "type") {
+		return nil
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+signature, 0)
+	if err != nil || len(file.Decls) == 0 {
+		return nil
+	}
+	decl, ok := file.Decls[0].(*ast.GenDecl)
+	if !ok || len(decl.Specs) == 0 {
+		return nil
+	}
+	spec, _ := decl.Specs[0].(*ast.TypeSpec)
+	return spec
+}
@@ -0,0 +1,200 @@
+package butler
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hhhapz/doc"
+)
+
+func method(typeName string, name string, signature string) doc.Method {
+	return doc.Method{For: typeName, Function: doc.Function{Name: name, Signature: signature}}
+}
+
+func xrefPackages() (doc.Package, []doc.Package) {
+	io := doc.Package{
+		URL:  "io",
+		Name: "io",
+		Types: map[string]doc.Type{
+			"closer": {Name: "Closer", Signature: "type Closer interface {\n\tClose() error\n}"},
+		},
+	}
+	pkg := doc.Package{
+		URL:  "example.com/mod",
+		Name: "mod",
+		Types: map[string]doc.Type{
+			"reader":     {Name: "Reader", Signature: "type Reader interface {\n\tRead(p []byte) (n int, err error)\n}"},
+			"readcloser": {Name: "ReadCloser", Signature: "type ReadCloser interface {\n\tReader\n\tio.Closer\n}"},
+			"file": {
+				Name:      "File",
+				Signature: "type File struct {\n\tname string\n}",
+				Methods: map[string]doc.Method{
+					"read":  method("File", "Read", "func (f *File) Read(p []byte) (int, error)"),
+					"close": method("File", "Close", "func (f File) Close() error"),
+				},
+			},
+			"value": {
+				Name:      "Value",
+				Signature: "type Value struct{}",
+				Methods: map[string]doc.Method{
+					"read":  method("Value", "Read", "func (Value) Read(b []byte) (int, error)"),
+					"close": method("Value", "Close", "func (Value) Close() error"),
+				},
+			},
+			"embedsvalue":   {Name: "EmbedsValue", Signature: "type EmbedsValue struct {\n\tFile\n}"},
+			"embedspointer": {Name: "EmbedsPointer", Signature: "type EmbedsPointer struct {\n\t*File\n}"},
+			"embedsiface":   {Name: "EmbedsIface", Signature: "type EmbedsIface struct {\n\tReadCloser\n}"},
+			"readonly": {
+				Name:      "ReadOnly",
+				Signature: "type ReadOnly struct{}",
+				Methods: map[string]doc.Method{
+					"read": method("ReadOnly", "Read", "func (r *ReadOnly) Read(p []byte) (int, error)"),
+				},
+			},
+			"wrongsignature": {
+				Name:      "WrongSignature",
+				Signature: "type WrongSignature struct{}",
+				Methods: map[string]doc.Method{
+					"read":  method("WrongSignature", "Read", "func (WrongSignature) Read(p string) (int, error)"),
+					"close": method("WrongSignature", "Close", "func (WrongSignature) Close() error"),
+				},
+			},
+		},
+		Functions: map[string]doc.Function{
+			"open": {Name: "Open", Signature: "func Open(name string) (*File, error)"},
+			"copy": {Name: "Copy", Signature: "func Copy(dst *File, src Reader) error"},
+		},
+	}
+	return pkg, []doc.Package{pkg, io}
+}
+
+func TestInterfaceMethods(t *testing.T) {
+	pkg, packages := xrefPackages()
+	tests := []struct {
+		typ      string
+		expected map[string]string
+	}{
+		{"reader", map[string]string{"Read": "([]byte) (int, error)"}},
+		{"readcloser", map[string]string{"Read": "([]byte) (int, error)", "Close": "() (error)"}},
+		{"file", nil},
+	}
+	for _, tt := range tests {
+		if methods := interfaceMethods(pkg, pkg.Types[tt.typ], packages, 0); !reflect.DeepEqual(methods, tt.expected) {
+			t.Errorf("interfaceMethods(%s): expected %v, got %v", tt.typ, tt.expected, methods)
+		}
+	}
+}
+
+func TestTypeMethods(t *testing.T) {
+	pkg, packages := xrefPackages()
+	tests := []struct {
+		typ     string
+		pointer map[string]bool
+	}{
+		{"file", map[string]bool{"Read": true, "Close": false}},
+		{"value", map[string]bool{"Read": false, "Close": false}},
+		{"embedsvalue", map[string]bool{"Read": true, "Close": false}},
+		{"embedspointer", map[string]bool{"Read": false, "Close": false}},
+		{"embedsiface", map[string]bool{}},
+	}
+	for _, tt := range tests {
+		methods, pointer := typeMethods(pkg, pkg.Types[tt.typ], packages, 0)
+		if !reflect.DeepEqual(pointer, tt.pointer) {
+			t.Errorf("typeMethods(%s): expected pointer receivers %v, got %v", tt.typ, tt.pointer, pointer)
+		}
+		if len(methods) != 2 || methods["Read"] != "([]byte) (int, error)" || methods["Close"] != "() (error)" {
+			t.Errorf("typeMethods(%s): unexpected methods %v", tt.typ, methods)
+		}
+	}
+}
+
+func TestFindImplementations(t *testing.T) {
+	pkg, packages := xrefPackages()
+	tests := []struct {
+		typ      string
+		expected []string
+	}{
+		{"reader", []string{"*EmbedsValue", "*File", "*ReadOnly", "EmbedsIface", "EmbedsPointer", "Value"}},
+		{"readcloser", []string{"*EmbedsValue", "*File", "EmbedsIface", "EmbedsPointer", "Value"}},
+		{"file", nil},
+	}
+	for _, tt := range tests {
+		var symbols []string
+		for _, ref := range FindImplementations(pkg, pkg.Types[tt.typ], packages) {
+			symbols = append(symbols, ref.Symbol)
+		}
+		if !reflect.DeepEqual(symbols, tt.expected) {
+			t.Errorf("FindImplementations(%s): expected %v, got %v", tt.typ, tt.expected, symbols)
+		}
+	}
+}
+
+func TestFindUsages(t *testing.T) {
+	pkg, packages := xrefPackages()
+	tests := []struct {
+		typ        string
+		returnedBy []DocsRef
+		acceptedBy []DocsRef
+	}{
+		{"file", []DocsRef{{Package: "example.com/mod", Name: "mod", Symbol: "Open"}}, []DocsRef{{Package: "example.com/mod", Name: "mod", Symbol: "Copy"}}},
+		{"reader", nil, []DocsRef{{Package: "example.com/mod", Name: "mod", Symbol: "Copy"}}},
+		{"value", nil, nil},
+	}
+	for _, tt := range tests {
+		returnedBy, acceptedBy := FindUsages(pkg, pkg.Types[tt.typ], packages)
+		if !reflect.DeepEqual(returnedBy, tt.returnedBy) || !reflect.DeepEqual(acceptedBy, tt.acceptedBy) {
+			t.Errorf("FindUsages(%s): expected %v and %v, got %v and %v", tt.typ, tt.returnedBy, tt.acceptedBy, returnedBy, acceptedBy)
+		}
+	}
+}
+
+func TestGetDocsEmbedRefsLimit(t *testing.T) {
+	pkg := doc.Package{
+		URL:  "example.com/mod",
+		Name: "mod",
+		Types: map[string]doc.Type{
+			"closer": {Name: "Closer", Signature: "type Closer interface {\n\tClose() error\n}"},
+		},
+	}
+	for i := 0; i < maxDocsRefs+5; i++ {
+		name := "T" + strconv.Itoa(i)
+		pkg.Types[strings.ToLower(name)] = doc.Type{
+			Name:      name,
+			Signature: "type " + name + " struct{}",
+			Methods: map[string]doc.Method{
+				"close": method(name, "Close", "func ("+name+") Close() error"),
+			},
+		}
+	}
+
+	state := DocsState{Package: pkg.URL, Query: "Closer", Expanded: DocsSectionImplementations}
+	embed, _ := GetDocsEmbed(pkg, []doc.Package{pkg}, nil, &state)
+	if n := strings.Count(embed.Description, "• "); n != maxDocsRefs {
+		t.Errorf("expected %d implementations, got %d", maxDocsRefs, n)
+	}
+	if !strings.Contains(embed.Description, "… and 5 more") {
+		t.Errorf("expected remaining implementations to be counted, got %q", embed.Description)
+	}
+}
+
+func TestModulePrefix(t *testing.T) {
+	tests := []struct {
+		pkgPath  string
+		expected string
+	}{
+		{"net/http", "std"},
+		{"github.com/disgoorg/disgo/discord", "github.com/disgoorg/disgo"},
+		{"github.com/disgoorg/snowflake/v2", "github.com/disgoorg/snowflake/v2"},
+		{"github.com/go-chi/chi/v5/middleware", "github.com/go-chi/chi/v5"},
+		{"golang.org/x/mod/semver", "golang.org/x/mod"},
+		{"gopkg.in/yaml.v3", "gopkg.in/yaml.v3"},
+		{"gopkg.in/src-d/go-git.v4/plumbing", "gopkg.in/src-d/go-git.v4"},
+	}
+	for _, tt := range tests {
+		if prefix := modulePrefix(tt.pkgPath); prefix != tt.expected {
+			t.Errorf("modulePrefix(%q): expected %q, got %q", tt.pkgPath, tt.expected, prefix)
+		}
+	}
+}
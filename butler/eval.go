@@ -0,0 +1,150 @@
+package butler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/disgoorg/disgo/discord"
+	"golang.org/x/mod/semver"
+)
+
+// EvalFile is a single source file of an eval. The first file is the entrypoint.
+type EvalFile struct {
+	Name    string `json:"name,omitempty"`
+	Content string `json:"content"`
+}
+
+// EvalMode selects how go code is run.
+type EvalMode string
+
+const (
+	// EvalModeAuto runs go code as tests if it only declares tests and benchmarks and as a program otherwise.
+	EvalModeAuto EvalMode = "auto"
+	// EvalModeProgram runs go code as a program.
+	EvalModeProgram EvalMode = "program"
+	// EvalModeTest runs the tests and benchmarks of go code.
+	EvalModeTest EvalMode = "test"
+)
+
+// ParseEvalMode returns the mode with the given name. Unknown names are EvalModeAuto.
+func ParseEvalMode(name string) EvalMode {
+	switch mode := EvalMode(name); mode {
+	case EvalModeProgram, EvalModeTest:
+		return mode
+	}
+	return EvalModeAuto
+}
+
+// EvalInput is everything needed to run code.
+type EvalInput struct {
+	Language string
+	// Version is the runtime version. An empty version selects the latest version.
+	Version string
+	Files   []EvalFile
+	Stdin   string
+	Args    []string
+	// Mode is how go code is run. The zero value is EvalModeAuto.
+	Mode EvalMode
+}
+
+// ResolveRuntime returns the piston language and version for the given language name or alias. An empty version
+// resolves to the latest version of the language.
+func (b *Butler) ResolveRuntime(name string, version string) (string, string, error) {
+	runtimes, err := b.Executor.Runtimes(context.TODO())
+	if err != nil {
+		return "", "", err
+	}
+	var language, latest string
+	for _, runtime := range runtimes {
+		if !runtime.Matches(name) {
+			continue
+		}
+		language = runtime.Language
+		if version != "" && runtime.Version == version {
+			return language, version, nil
+		}
+		if latest == "" || semver.Compare("v"+runtime.Version, "v"+latest) > 0 {
+			latest = runtime.Version
+		}
+	}
+	if language == "" {
+		return "", "", fmt.Errorf("language %s is not supported", name)
+	}
+	if version != "" {
+		return "", "", fmt.Errorf("version %s of %s is not supported", version, language)
+	}
+	return language, latest, nil
+}
+
+// RuntimeVersions returns all available versions of the language.
+func (b *Butler) RuntimeVersions(language string) ([]string, error) {
+	runtimes, err := b.Executor.Runtimes(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, runtime := range runtimes {
+		if runtime.Matches(language) {
+			versions = append(versions, runtime.Version)
+		}
+	}
+	return versions, nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetEvalSourceEmbed shows the input of evals which were not run from a message, so they can be edited later.
+func GetEvalSourceEmbed(input EvalInput) discord.Embed {
+	embed := discord.Embed{
+		Title: input.Language + " " + input.Version,
+		Color: embedColor,
+	}
+	if len(input.Files) > 0 {
+		embed.Description = codeBlock(input.Language, input.Files[0].Content)
+	}
+	if input.Stdin != "" {
+		embed.Fields = append(embed.Fields, discord.EmbedField{
+			Name:  "Stdin",
+			Value: codeBlock("", input.Stdin),
+		})
+	}
+	if len(input.Args) > 0 {
+		embed.Fields = append(embed.Fields, discord.EmbedField{
+			Name:  "Arguments",
+			Value: codeBlock("", strings.Join(input.Args, "\n")),
+		})
+	}
+	return embed
+}
+
+// EvalInputFromEmbed restores the input from an embed created by GetEvalSourceEmbed.
+func EvalInputFromEmbed(embed discord.Embed) (EvalInput, bool) {
+	code, ok := unwrapCodeBlock(embed.Description)
+	if !ok || embed.Title == "" {
+		return EvalInput{}, false
+	}
+	language, version, _ := strings.Cut(embed.Title, " ")
+	input := EvalInput{
+		Language: language,
+		Version:  version,
+		Files:    []EvalFile{{Content: code}},
+	}
+	for _, field := range embed.Fields {
+		value, _ := unwrapCodeBlock(field.Value)
+		switch field.Name {
+		case "Stdin":
+			input.Stdin = value
+		case "Arguments":
+			input.Args = strings.Split(value, "\n")
+		}
+	}
+	return input, true
+}
@@ -0,0 +1,77 @@
+package butler
+
+import (
+	"context"
+	"strings"
+
+	"github.com/disgoorg/disgo-butler/gocode"
+)
+
+// isGo reports whether the language is go or one of its common aliases.
+func isGo(language string) bool {
+	return strings.EqualFold(language, "go") || strings.EqualFold(language, "golang")
+}
+
+// prepareGo turns go snippets into programs and stores the fixed source, the go vet diagnostics and whether the
+// code has tests in the result. A single file is wrapped in a main function if needed, or in a main function which
+// runs its tests and benchmarks in test mode. Files of multi file evals only get their imports fixed. Files which
+// can't be parsed are left as they are, so the compiler reports the error.
+func (b *Butler) prepareGo(ctx context.Context, result *EvalResult) EvalInput {
+	input := result.Input
+	files := make([]EvalFile, len(input.Files))
+	copy(files, input.Files)
+	if len(files) == 1 {
+		tests, benchmarks := gocode.TestFuncs(files[0].Content)
+		result.HasTests = len(tests)+len(benchmarks) > 0
+	}
+	for i, file := range files {
+		if file.Name != "" && !strings.HasSuffix(file.Name, ".go") {
+			continue
+		}
+		var (
+			fixed string
+			err   error
+		)
+		if len(files) == 1 && result.runsTests() {
+			if fixed, err = gocode.WrapTests(file.Content); err == nil {
+				result.Tests = &gocode.TestReport{}
+			}
+		} else if len(files) == 1 {
+			fixed, err = gocode.Fix(file.Content)
+		} else if gocode.IsProgram(file.Content) {
+			fixed, err = gocode.FixImports(file.Content, nil)
+		} else {
+			continue
+		}
+		if err == nil {
+			files[i].Content = fixed
+		}
+	}
+	if len(files) == 0 {
+		return input
+	}
+
+	if files[0].Content != input.Files[0].Content {
+		result.Source = files[0].Content
+	}
+	if b.Config.Eval.GoVet && len(files) == 1 {
+		var err error
+		if result.Diagnostics, err = gocode.Vet(ctx, files[0].Content); err != nil {
+			b.Logger.Debugf("Failed to vet eval: %s", err)
+		}
+	}
+
+	input.Files = files
+	return input
+}
+
+// runsTests reports whether the go code of the eval is run as tests.
+func (r EvalResult) runsTests() bool {
+	switch r.Input.Mode {
+	case EvalModeTest:
+		return r.HasTests
+	case EvalModeProgram:
+		return false
+	}
+	return r.HasTests && gocode.OnlyTests(r.Input.Files[0].Content)
+}
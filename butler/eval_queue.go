@@ -0,0 +1,310 @@
+package butler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/disgoorg/snowflake/v2"
+)
+
+const (
+	defaultEvalWorkers          = 2
+	defaultEvalMaxQueuedPerUser = 3
+	defaultEvalUserQuota        = 10
+	defaultEvalChannelQuota     = 30
+	defaultEvalQuotaWindow      = time.Minute
+
+	// EvalQueueTimeout is the maximum duration an eval waits in the queue.
+	EvalQueueTimeout = 5 * time.Minute
+)
+
+// EvalQuotaError is returned if a user or channel ran too many evals recently.
+type EvalQuotaError struct {
+	// Scope is who exceeded the quota, either "you" or "this channel".
+	Scope      string
+	Limit      int
+	Window     time.Duration
+	RetryAfter time.Duration
+}
+
+func (e *EvalQuotaError) Error() string {
+	return fmt.Sprintf("%s can run at most %d evals per %s, try again in %s", e.Scope, e.Limit, e.Window, e.RetryAfter.Round(time.Second))
+}
+
+// EvalQueueFullError is returned if a user already has too many evals waiting.
+type EvalQueueFullError struct {
+	Limit int
+}
+
+func (e *EvalQueueFullError) Error() string {
+	return fmt.Sprintf("you already have %d evals waiting, wait for them to finish first", e.Limit)
+}
+
+// NewEvalQueue creates the queue of evals. Zero values of the config use the defaults and negative quotas disable
+// them.
+func NewEvalQueue(cfg EvalQueueConfig) *EvalQueue {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = defaultEvalWorkers
+	}
+	maxQueued := cfg.MaxQueuedPerUser
+	if maxQueued <= 0 {
+		maxQueued = defaultEvalMaxQueuedPerUser
+	}
+	window := time.Duration(cfg.QuotaWindow) * time.Second
+	if window <= 0 {
+		window = defaultEvalQuotaWindow
+	}
+	userQuota := cfg.UserQuota
+	if userQuota == 0 {
+		userQuota = defaultEvalUserQuota
+	}
+	channelQuota := cfg.ChannelQuota
+	if channelQuota == 0 {
+		channelQuota = defaultEvalChannelQuota
+	}
+
+	return &EvalQueue{
+		workers:      workers,
+		maxQueued:    maxQueued,
+		userQuota:    newEvalQuota("you", userQuota, window),
+		channelQuota: newEvalQuota("this channel", channelQuota, window),
+		pending:      map[snowflake.ID][]*EvalTicket{},
+	}
+}
+
+// EvalQueue limits how many evals run at the same time. Waiting evals are started round-robin by user, so a
+// single user can't starve the others.
+type EvalQueue struct {
+	jobs sync.WaitGroup
+
+	mu           sync.Mutex
+	workers      int
+	running      int
+	maxQueued    int
+	userQuota    *evalQuota
+	channelQuota *evalQuota
+	// user ID -> waiting tickets
+	pending map[snowflake.ID][]*EvalTicket
+	// users with waiting tickets in the order they are served
+	users []snowflake.ID
+}
+
+// EvalTicket is the place of an eval in the queue.
+type EvalTicket struct {
+	queue      *EvalQueue
+	userID     snowflake.ID
+	ready      chan struct{}
+	position   int
+	onPosition func(position int)
+	started    bool
+	done       bool
+}
+
+// Enqueue checks the quotas of the user and channel and puts the eval into the queue. The ticket must be released
+// with Done.
+func (q *EvalQueue) Enqueue(userID snowflake.ID, channelID snowflake.ID) (*EvalTicket, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending[userID]) >= q.maxQueued {
+		return nil, &EvalQueueFullError{Limit: q.maxQueued}
+	}
+	now := time.Now()
+	if err := q.userQuota.check(userID, now); err != nil {
+		return nil, err
+	}
+	if err := q.channelQuota.check(channelID, now); err != nil {
+		return nil, err
+	}
+	q.userQuota.use(userID, now)
+	q.channelQuota.use(channelID, now)
+
+	ticket := &EvalTicket{
+		queue:  q,
+		userID: userID,
+		ready:  make(chan struct{}),
+	}
+	if len(q.pending[userID]) == 0 {
+		q.users = append(q.users, userID)
+	}
+	q.pending[userID] = append(q.pending[userID], ticket)
+	q.dispatch()
+	return ticket, nil
+}
+
+// Position returns the position of the ticket in the queue. It is 0 once the eval may run.
+func (t *EvalTicket) Position() int {
+	t.queue.mu.Lock()
+	defer t.queue.mu.Unlock()
+	return t.position
+}
+
+// Wait blocks until the eval may run. onPosition is called whenever the position of the ticket changes while it
+// waits, starting with its current position.
+func (t *EvalTicket) Wait(ctx context.Context, onPosition func(position int)) error {
+	t.queue.mu.Lock()
+	t.onPosition = onPosition
+	position := t.position
+	t.queue.mu.Unlock()
+
+	if position > 0 && onPosition != nil {
+		onPosition(position)
+	}
+	select {
+	case <-t.ready:
+		return nil
+	case <-ctx.Done():
+		t.Done()
+		return ctx.Err()
+	}
+}
+
+// Go runs f in a new goroutine and releases the ticket once f returns. Interaction handlers respond first and hand
+// the eval to Go, so evals of different users run at the same time while the events are dispatched one by one.
+func (t *EvalTicket) Go(f func()) {
+	t.queue.jobs.Add(1)
+	go func() {
+		defer t.queue.jobs.Done()
+		defer t.Done()
+		f()
+	}()
+}
+
+// WaitIdle blocks until all evals started with EvalTicket.Go returned.
+func (q *EvalQueue) WaitIdle() {
+	q.jobs.Wait()
+}
+
+// Done releases the ticket. It removes waiting tickets from the queue and frees the worker of running ones.
+func (t *EvalTicket) Done() {
+	q := t.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if t.done {
+		return
+	}
+	t.done = true
+	if t.started {
+		q.running--
+	} else {
+		q.remove(t)
+	}
+	q.dispatch()
+}
+
+func (q *EvalQueue) remove(ticket *EvalTicket) {
+	tickets := q.pending[ticket.userID]
+	for i, t := range tickets {
+		if t == ticket {
+			q.pending[ticket.userID] = append(tickets[:i:i], tickets[i+1:]...)
+			break
+		}
+	}
+	if len(q.pending[ticket.userID]) > 0 {
+		return
+	}
+	delete(q.pending, ticket.userID)
+	for i, userID := range q.users {
+		if userID == ticket.userID {
+			q.users = append(q.users[:i:i], q.users[i+1:]...)
+			break
+		}
+	}
+}
+
+// dispatch starts waiting evals while workers are free and updates the positions of the others.
+func (q *EvalQueue) dispatch() {
+	for q.running < q.workers && len(q.users) > 0 {
+		userID := q.users[0]
+		q.users = q.users[1:]
+		ticket := q.pending[userID][0]
+		q.pending[userID] = q.pending[userID][1:]
+		if len(q.pending[userID]) > 0 {
+			q.users = append(q.users, userID)
+		} else {
+			delete(q.pending, userID)
+		}
+		q.running++
+		ticket.started = true
+		ticket.position = 0
+		close(ticket.ready)
+	}
+
+	// serve the users round-robin to find the position of every ticket
+	var notify []*EvalTicket
+	position := 1
+	for round := 0; ; round++ {
+		var found bool
+		for _, userID := range q.users {
+			tickets := q.pending[userID]
+			if round >= len(tickets) {
+				continue
+			}
+			found = true
+			ticket := tickets[round]
+			if ticket.position != position && ticket.onPosition != nil {
+				notify = append(notify, ticket)
+			}
+			ticket.position = position
+			position++
+		}
+		if !found {
+			break
+		}
+	}
+
+	for _, ticket := range notify {
+		go ticket.onPosition(ticket.position)
+	}
+}
+
+func newEvalQuota(scope string, limit int, window time.Duration) *evalQuota {
+	return &evalQuota{
+		scope:  scope,
+		limit:  limit,
+		window: window,
+		uses:   map[snowflake.ID][]time.Time{},
+	}
+}
+
+// evalQuota limits the number of evals per ID in a sliding window.
+type evalQuota struct {
+	scope  string
+	limit  int
+	window time.Duration
+	uses   map[snowflake.ID][]time.Time
+}
+
+func (q *evalQuota) check(id snowflake.ID, now time.Time) error {
+	if q.limit < 0 {
+		return nil
+	}
+	uses := q.uses[id]
+	for len(uses) > 0 && now.Sub(uses[0]) >= q.window {
+		uses = uses[1:]
+	}
+	if len(uses) == 0 {
+		delete(q.uses, id)
+	} else {
+		q.uses[id] = uses
+	}
+	if len(uses) < q.limit {
+		return nil
+	}
+	return &EvalQuotaError{
+		Scope:      q.scope,
+		Limit:      q.limit,
+		Window:     q.window,
+		RetryAfter: q.window - now.Sub(uses[0]),
+	}
+}
+
+func (q *evalQuota) use(id snowflake.ID, now time.Time) {
+	if q.limit < 0 {
+		return
+	}
+	q.uses[id] = append(q.uses[id], now)
+}
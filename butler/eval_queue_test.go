@@ -0,0 +1,127 @@
+package butler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/snowflake/v2"
+)
+
+func TestEvalQueueFairness(t *testing.T) {
+	q := NewEvalQueue(EvalQueueConfig{Workers: 1, MaxQueuedPerUser: 5, UserQuota: -1, ChannelQuota: -1})
+
+	running, err := q.Enqueue(1, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if running.Position() != 0 {
+		t.Fatalf("expected first eval to run, got position %d", running.Position())
+	}
+
+	a1, _ := q.Enqueue(1, 100)
+	a2, _ := q.Enqueue(1, 100)
+	b1, _ := q.Enqueue(2, 100)
+	if a1.Position() != 1 || b1.Position() != 2 || a2.Position() != 3 {
+		t.Errorf("expected round-robin positions 1, 2, 3, got %d, %d, %d", a1.Position(), b1.Position(), a2.Position())
+	}
+
+	running.Done()
+	if err = a1.Wait(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if b1.Position() != 1 || a2.Position() != 2 {
+		t.Errorf("expected other users to go first, got %d, %d", b1.Position(), a2.Position())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err = b1.Wait(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected timeout, got %v", err)
+	}
+	if a2.Position() != 1 {
+		t.Errorf("expected cancelled eval to leave the queue, got position %d", a2.Position())
+	}
+}
+
+func TestEvalQueueConcurrentFairness(t *testing.T) {
+	q := NewEvalQueue(EvalQueueConfig{Workers: 1, MaxQueuedPerUser: 5, UserQuota: -1, ChannelQuota: -1})
+	blocker, err := q.Enqueue(3, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu    sync.Mutex
+		order []snowflake.ID
+		wg    sync.WaitGroup
+	)
+	for _, userID := range []snowflake.ID{1, 2} {
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func(userID snowflake.ID) {
+				defer wg.Done()
+				ticket, err := q.Enqueue(userID, 100)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				ticket.Go(func() {
+					if err := ticket.Wait(context.Background(), nil); err != nil {
+						t.Error(err)
+						return
+					}
+					mu.Lock()
+					order = append(order, userID)
+					mu.Unlock()
+				})
+			}(userID)
+		}
+	}
+	wg.Wait()
+	blocker.Done()
+	q.WaitIdle()
+
+	if len(order) != 6 {
+		t.Fatalf("expected 6 evals to run, got %v", order)
+	}
+	for i := 1; i < len(order); i++ {
+		if order[i] == order[i-1] {
+			t.Errorf("expected users to take turns, got %v", order)
+			break
+		}
+	}
+}
+
+func TestEvalQueueQuotas(t *testing.T) {
+	q := NewEvalQueue(EvalQueueConfig{Workers: 10, MaxQueuedPerUser: 1, UserQuota: 2, ChannelQuota: 3})
+
+	for i := 0; i < 2; i++ {
+		ticket, err := q.Enqueue(1, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ticket.Done()
+	}
+	var quotaErr *EvalQuotaError
+	if _, err := q.Enqueue(1, 100); !errors.As(err, &quotaErr) || quotaErr.Scope != "you" {
+		t.Errorf("expected user quota error, got %v", err)
+	}
+
+	if _, err := q.Enqueue(2, 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue(3, 100); !errors.As(err, &quotaErr) || quotaErr.Scope != "this channel" {
+		t.Errorf("expected channel quota error, got %v", err)
+	}
+
+	full := NewEvalQueue(EvalQueueConfig{Workers: 1, MaxQueuedPerUser: 1, UserQuota: -1, ChannelQuota: -1})
+	_, _ = full.Enqueue(1, 100)
+	_, _ = full.Enqueue(1, 100)
+	var fullErr *EvalQueueFullError
+	if _, err := full.Enqueue(1, 100); !errors.As(err, &fullErr) {
+		t.Errorf("expected queue full error, got %v", err)
+	}
+}
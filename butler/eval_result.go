@@ -0,0 +1,314 @@
+package butler
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/json"
+
+	"github.com/disgoorg/disgo-butler/common"
+	"github.com/disgoorg/disgo-butler/gocode"
+)
+
+const (
+	evalColorCompileError = 0xe67e22
+	evalColorTimeout      = 0xfee75c
+)
+
+// ansiRegex matches ANSI escape sequences like colors and cursor movements.
+var ansiRegex = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// EvalStatus is the outcome of an eval.
+type EvalStatus int
+
+const (
+	EvalStatusSuccess EvalStatus = iota
+	EvalStatusCompileError
+	EvalStatusRuntimeError
+	EvalStatusTimeout
+	// EvalStatusError means the code could not be run at all.
+	EvalStatusError
+)
+
+func (s EvalStatus) String() string {
+	switch s {
+	case EvalStatusSuccess:
+		return "Success"
+	case EvalStatusCompileError:
+		return "Compile error"
+	case EvalStatusRuntimeError:
+		return "Runtime error"
+	case EvalStatusTimeout:
+		return "Timeout"
+	case EvalStatusError:
+		return "Error"
+	}
+	return "Unknown"
+}
+
+func (s EvalStatus) Color() int {
+	switch s {
+	case EvalStatusSuccess:
+		return common.ColorSuccess
+	case EvalStatusCompileError:
+		return evalColorCompileError
+	case EvalStatusTimeout:
+		return evalColorTimeout
+	}
+	return common.ColorError
+}
+
+// EvalResult is the result of running an EvalInput.
+type EvalResult struct {
+	Input EvalInput
+	Exec  *ExecResult
+	// Err is set if the code could not be run.
+	Err error
+	// Duration is the wall-clock duration of the whole execution including the request.
+	Duration time.Duration
+	// Source is the source of the first file which was actually run if it differs from the input, for example
+	// because a go snippet was wrapped in a main function.
+	Source string
+	// Diagnostics are reported by go vet.
+	Diagnostics []string
+	// HasTests reports whether the go code declares tests or benchmarks, so it can be run in either mode.
+	HasTests bool
+	// Tests is the report of the tests and benchmarks if the go code was run as tests.
+	Tests *gocode.TestReport
+}
+
+// Eval runs the input and measures how long it took. Go snippets are turned into programs first.
+func (b *Butler) Eval(ctx context.Context, input EvalInput) EvalResult {
+	start := time.Now()
+	result := EvalResult{Input: input}
+	executed := input
+	if isGo(input.Language) {
+		executed = b.prepareGo(ctx, &result)
+	}
+	result.Exec, result.Err = b.Executor.Execute(ctx, executed)
+	result.Duration = time.Since(start)
+	if result.Tests != nil && result.Exec != nil {
+		report := gocode.ParseTestOutput(stripANSI(result.Exec.Run.Stdout))
+		result.Tests = &report
+	}
+	return result
+}
+
+func (r EvalResult) Status() EvalStatus {
+	switch {
+	case r.Err != nil || r.Exec == nil:
+		return EvalStatusError
+	case r.Exec.Compile != nil && r.Exec.Compile.TimedOut(), r.Exec.Run.TimedOut():
+		return EvalStatusTimeout
+	case r.Exec.Compile != nil && r.Exec.Compile.Failed():
+		return EvalStatusCompileError
+	case r.Exec.Run.Failed():
+		return EvalStatusRuntimeError
+	}
+	return EvalStatusSuccess
+}
+
+// evalSection is a named part of the output of an eval.
+type evalSection struct {
+	name    string
+	content string
+}
+
+func (r EvalResult) sections() []evalSection {
+	var sections []evalSection
+	add := func(name string, content string) {
+		if content = stripANSI(content); strings.TrimSpace(content) != "" {
+			sections = append(sections, evalSection{name: name, content: content})
+		}
+	}
+	add("go vet", strings.Join(r.Diagnostics, "\n"))
+	if r.Err != nil || r.Exec == nil {
+		if r.Err != nil {
+			add("Error", r.Err.Error())
+		}
+		return sections
+	}
+	if r.Exec.Compile != nil {
+		add("Compile stdout", r.Exec.Compile.Stdout)
+		add("Compile stderr", r.Exec.Compile.Stderr)
+	}
+	if r.Tests != nil {
+		add("Tests", testsTable(r.Tests.Tests))
+		add("Benchmarks", benchmarksTable(r.Tests.Benchmarks))
+		add("Stdout", r.Tests.Output)
+	} else {
+		add("Stdout", r.Exec.Run.Stdout)
+	}
+	add("Stderr", r.Exec.Run.Stderr)
+	return sections
+}
+
+func testsTable(tests []gocode.TestResult) string {
+	if len(tests) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	for _, test := range tests {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", test.Status, test.Name, test.Duration)
+	}
+	_ = w.Flush()
+	return sb.String()
+}
+
+func benchmarksTable(benchmarks []gocode.BenchmarkResult) string {
+	if len(benchmarks) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprint(w, "Benchmark\truns\tns/op\tB/op\tallocs/op\n")
+	for _, benchmark := range benchmarks {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", benchmark.Name, benchmark.N, benchmark.NsPerOp, benchmark.BytesPerOp, benchmark.AllocsPerOp)
+	}
+	_ = w.Flush()
+	return sb.String()
+}
+
+// Output returns the whole output of the eval as plain text.
+func (r EvalResult) Output() string {
+	var sb strings.Builder
+	for _, section := range r.sections() {
+		sb.WriteString("--- " + section.name + " ---\n")
+		sb.WriteString(section.content)
+		if !strings.HasSuffix(section.content, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// exitStatus formats the exit code or signal of the stage which ended the eval.
+func (r EvalResult) exitStatus() string {
+	stage := r.Exec.Run
+	if r.Exec.Compile != nil && r.Exec.Compile.Failed() {
+		stage = *r.Exec.Compile
+	}
+	if stage.Signal != nil {
+		return "signal " + *stage.Signal
+	}
+	if stage.Code != nil {
+		return strconv.Itoa(*stage.Code)
+	}
+	return "unknown"
+}
+
+// GetEvalEmbed creates the result embed of an eval and the files which should be attached to it. The source which
+// was actually run is attached if it differs from the input, and the full output if it doesn't fit into the embed.
+// maxLength is the maximum length of the whole embed as counted by common.EmbedLength.
+func GetEvalEmbed(result EvalResult, maxLength int) (discord.Embed, []*discord.File) {
+	status := result.Status()
+	embed := discord.Embed{
+		Title: "Eval",
+		Color: status.Color(),
+		Fields: []discord.EmbedField{
+			{
+				Name:   "Status",
+				Value:  status.String(),
+				Inline: json.Ptr(true),
+			},
+			{
+				Name:   "Duration",
+				Value:  result.Duration.Round(time.Millisecond).String(),
+				Inline: json.Ptr(true),
+			},
+		},
+	}
+	if result.Exec != nil {
+		embed.Fields = append(embed.Fields, discord.EmbedField{
+			Name:   "Exit code",
+			Value:  result.exitStatus(),
+			Inline: json.Ptr(true),
+		})
+		if result.Exec.Run.WallTime != nil {
+			runTime := fmt.Sprintf("%dms", *result.Exec.Run.WallTime)
+			if result.Exec.Run.CPUTime != nil {
+				runTime += fmt.Sprintf(" (%dms CPU)", *result.Exec.Run.CPUTime)
+			}
+			embed.Fields = append(embed.Fields, discord.EmbedField{
+				Name:   "Run time",
+				Value:  runTime,
+				Inline: json.Ptr(true),
+			})
+		}
+		if result.Tests != nil && len(result.Tests.Tests) > 0 {
+			passed, failed := result.Tests.Passed()
+			embed.Fields = append(embed.Fields, discord.EmbedField{
+				Name:   "Tests",
+				Value:  fmt.Sprintf("%d passed, %d failed", passed, failed),
+				Inline: json.Ptr(true),
+			})
+		}
+		embed.Footer = &discord.EmbedFooter{Text: result.Exec.Language + " " + result.Exec.Version}
+	}
+
+	var files []*discord.File
+	if result.Source != "" {
+		files = append(files, discord.NewFile("main.go", "The source which was run", strings.NewReader(result.Source)))
+	}
+
+	sections := result.sections()
+	if len(sections) == 0 {
+		embed.Description = "No output."
+		return embed, files
+	}
+	descriptionLimit := embedDescriptionLimit
+	if remaining := maxLength - common.EmbedLength(embed); remaining < descriptionLimit {
+		descriptionLimit = remaining
+	}
+	blocks, truncated := fitSections(sections, descriptionLimit)
+	embed.Description = strings.Join(blocks, "\n")
+	if truncated {
+		files = append(files, discord.NewFile("output.txt", "The full output of the eval", strings.NewReader(result.Output())))
+	}
+	return embed, files
+}
+
+// fitSections renders the sections as code blocks and shares the available length between them. Sections which are
+// shorter than their share leave the rest to the others. It reports whether any section had to be truncated.
+func fitSections(sections []evalSection, maxLen int) ([]string, bool) {
+	blocks := make([]string, len(sections))
+	for i, section := range sections {
+		blocks[i] = codeBlock("", section.content)
+	}
+	order := make([]int, len(blocks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return len(blocks[order[i]]) < len(blocks[order[j]])
+	})
+
+	// the sections are separated by a newline
+	remaining := maxLen - len(blocks) + 1
+	var truncated bool
+	for i, index := range order {
+		header := "**" + sections[index].name + "**\n"
+		share := remaining/(len(blocks)-i) - len(header)
+		if len(blocks[index]) > share {
+			blocks[index] = truncateBlock(blocks[index], share)
+			truncated = true
+		}
+		if blocks[index] != "" {
+			blocks[index] = header + blocks[index]
+		}
+		remaining -= len(blocks[index])
+	}
+	return blocks, truncated
+}
+
+func stripANSI(s string) string {
+	return ansiRegex.ReplaceAllString(s, "")
+}
@@ -0,0 +1,55 @@
+package butler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/disgoorg/disgo-butler/common"
+)
+
+func TestEvalResultStatus(t *testing.T) {
+	zero, one := 0, 1
+	kill, timeout := "SIGKILL", "TO"
+	tests := []struct {
+		name     string
+		result   EvalResult
+		expected EvalStatus
+	}{
+		{"error", EvalResult{Err: errors.New("unavailable")}, EvalStatusError},
+		{"success", EvalResult{Exec: &ExecResult{Compile: &ExecStage{Code: &zero}, Run: ExecStage{Code: &zero}}}, EvalStatusSuccess},
+		{"compile error", EvalResult{Exec: &ExecResult{Compile: &ExecStage{Code: &one}}}, EvalStatusCompileError},
+		{"runtime error", EvalResult{Exec: &ExecResult{Run: ExecStage{Code: &one}}}, EvalStatusRuntimeError},
+		{"timeout", EvalResult{Exec: &ExecResult{Run: ExecStage{Signal: &kill, Status: &timeout}}}, EvalStatusTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := tt.result.Status(); status != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, status)
+			}
+		})
+	}
+}
+
+func TestGetEvalEmbed(t *testing.T) {
+	one := 1
+	result := EvalResult{Exec: &ExecResult{Run: ExecStage{
+		Stdout: strings.Repeat("line\n", 2000),
+		Stderr: "\x1b[31mpanic: oops\x1b[0m\n",
+		Code:   &one,
+	}}}
+
+	embed, files := GetEvalEmbed(result, common.MaxEmbedsLength)
+	if len(embed.Description) > embedDescriptionLimit {
+		t.Errorf("description too long: %d", len(embed.Description))
+	}
+	if !strings.Contains(embed.Description, "**Stderr**\n```\npanic: oops\n```") {
+		t.Errorf("expected stripped stderr section, got:\n%s", embed.Description)
+	}
+	if len(files) != 1 || files[0].Name != "output.txt" {
+		t.Fatalf("expected output file, got %v", files)
+	}
+	if embed.Fields[2].Value != "1" {
+		t.Errorf("expected exit code 1, got %s", embed.Fields[2].Value)
+	}
+}
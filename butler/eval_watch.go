@@ -0,0 +1,117 @@
+package butler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/disgoorg/snowflake/v2"
+)
+
+// defaultEvalRerunWindow is how long edits of a message rerun its eval if the config doesn't set a window.
+const defaultEvalRerunWindow = 10 * time.Minute
+
+// EvalWatch links the message an eval was run on to the message with its result.
+type EvalWatch struct {
+	ChannelID snowflake.ID
+	ResultID  snowflake.ID
+	// Content is the content of the message when the eval was last run. Edits which don't change the content, like
+	// embeds being added, don't rerun the eval.
+	Content string
+	// Selection is the selection of code blocks which is run.
+	Selection string
+	Mode      EvalMode
+	Expires   time.Time
+}
+
+// NewEvalWatches creates the store of watched eval messages. A zero window uses the default window and a negative
+// window disables rerunning evals on edits.
+func NewEvalWatches(window time.Duration) *EvalWatches {
+	if window == 0 {
+		window = defaultEvalRerunWindow
+	}
+	return &EvalWatches{
+		window:  window,
+		watches: map[snowflake.ID]EvalWatch{},
+		results: map[snowflake.ID]snowflake.ID{},
+	}
+}
+
+// EvalWatches tracks which eval results belong to which messages, so evals can be rerun when their message is
+// edited.
+type EvalWatches struct {
+	mu     sync.Mutex
+	window time.Duration
+	// source message ID -> watch
+	watches map[snowflake.ID]EvalWatch
+	// result message ID -> source message ID
+	results map[snowflake.ID]snowflake.ID
+}
+
+// Watch starts tracking the message until the window expires. Watching a message again updates its result, content,
+// selection and mode but keeps the expiry.
+func (w *EvalWatches) Watch(messageID snowflake.ID, channelID snowflake.ID, resultID snowflake.ID, content string, selection string, mode EvalMode) {
+	if w.window < 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := time.Now()
+	for id, watch := range w.watches {
+		if now.After(watch.Expires) {
+			w.remove(id)
+		}
+	}
+
+	expires := now.Add(w.window)
+	if watch, ok := w.watches[messageID]; ok {
+		expires = watch.Expires
+		delete(w.results, watch.ResultID)
+	}
+	w.watches[messageID] = EvalWatch{
+		ChannelID: channelID,
+		ResultID:  resultID,
+		Content:   content,
+		Selection: selection,
+		Mode:      mode,
+		Expires:   expires,
+	}
+	w.results[resultID] = messageID
+}
+
+// Get returns the watch of the message if it didn't expire yet.
+func (w *EvalWatches) Get(messageID snowflake.ID) (EvalWatch, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	watch, ok := w.watches[messageID]
+	if !ok || time.Now().After(watch.Expires) {
+		return EvalWatch{}, false
+	}
+	return watch, true
+}
+
+// SetContent updates the content of a watched message when its eval is rerun.
+func (w *EvalWatches) SetContent(messageID snowflake.ID, content string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if watch, ok := w.watches[messageID]; ok {
+		watch.Content = content
+		w.watches[messageID] = watch
+	}
+}
+
+// Remove stops tracking the message. The ID can be of the source or the result message.
+func (w *EvalWatches) Remove(messageID snowflake.ID) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if sourceID, ok := w.results[messageID]; ok {
+		messageID = sourceID
+	}
+	w.remove(messageID)
+}
+
+func (w *EvalWatches) remove(messageID snowflake.ID) {
+	if watch, ok := w.watches[messageID]; ok {
+		delete(w.results, watch.ResultID)
+		delete(w.watches, messageID)
+	}
+}
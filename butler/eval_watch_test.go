@@ -0,0 +1,38 @@
+package butler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvalWatches(t *testing.T) {
+	watches := NewEvalWatches(time.Minute)
+	watches.Watch(1, 10, 2, "```go\nfoo\n```", "auto", EvalModeAuto)
+	watch, ok := watches.Get(1)
+	if !ok || watch.ResultID != 2 || watch.ChannelID != 10 {
+		t.Fatalf("expected watch, got %+v", watch)
+	}
+
+	watches.SetContent(1, "```go\nbar\n```")
+	if watch, _ = watches.Get(1); watch.Content != "```go\nbar\n```" {
+		t.Errorf("expected updated content, got %q", watch.Content)
+	}
+
+	watches.Remove(2)
+	if _, ok = watches.Get(1); ok {
+		t.Error("expected deleting the result to stop the watch")
+	}
+
+	disabled := NewEvalWatches(-1)
+	disabled.Watch(1, 10, 2, "", "auto", EvalModeAuto)
+	if _, ok = disabled.Get(1); ok {
+		t.Error("expected no watch with a negative window")
+	}
+
+	expired := NewEvalWatches(time.Nanosecond)
+	expired.Watch(1, 10, 2, "", "auto", EvalModeAuto)
+	time.Sleep(time.Millisecond)
+	if _, ok = expired.Get(1); ok {
+		t.Error("expected expired watch")
+	}
+}
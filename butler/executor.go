@@ -0,0 +1,289 @@
+package butler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gopiston "github.com/milindmadhukar/go-piston"
+)
+
+const (
+	defaultRuntimesRefresh = time.Hour
+	runtimesTimeout        = 30 * time.Second
+)
+
+// Runtime is a language version which an Executor can run.
+type Runtime struct {
+	Language string
+	Version  string
+	Aliases  []string
+}
+
+// Matches reports whether the runtime is the language or one of its aliases.
+func (r Runtime) Matches(language string) bool {
+	return strings.EqualFold(r.Language, language) || containsFold(r.Aliases, language)
+}
+
+// Executor runs code for evals.
+type Executor interface {
+	// Runtimes returns all languages and versions the executor can run.
+	Runtimes(ctx context.Context) ([]Runtime, error)
+	// Execute runs the input. The language and version of the input are resolved already.
+	Execute(ctx context.Context, input EvalInput) (*ExecResult, error)
+}
+
+// ExecStage is the result of the compile or run stage of an execution.
+type ExecStage struct {
+	Stdout string  `json:"stdout"`
+	Stderr string  `json:"stderr"`
+	Output string  `json:"output"`
+	Code   *int    `json:"code"`
+	Signal *string `json:"signal"`
+	// Status is set if the stage did not exit normally, for example "TO" for timeouts.
+	Status *string `json:"status"`
+	// WallTime and CPUTime are the durations of the stage in milliseconds if the executor reports them.
+	WallTime *int `json:"wall_time"`
+	CPUTime  *int `json:"cpu_time"`
+}
+
+// Failed reports whether the stage exited with a non-zero code or was killed.
+func (s ExecStage) Failed() bool {
+	return (s.Code != nil && *s.Code != 0) || s.Signal != nil
+}
+
+// TimedOut reports whether the stage was killed because it exceeded its timeout. Older piston versions don't
+// report a status, but kill stages which time out with SIGKILL.
+func (s ExecStage) TimedOut() bool {
+	if s.Status != nil {
+		return *s.Status == "TO"
+	}
+	return s.Signal != nil && *s.Signal == "SIGKILL"
+}
+
+// ExecResult is the result of an execution. Compile is nil for interpreted languages.
+type ExecResult struct {
+	Language string     `json:"language"`
+	Version  string     `json:"version"`
+	Compile  *ExecStage `json:"compile"`
+	Run      ExecStage  `json:"run"`
+}
+
+// NewExecutor creates the executor of the eval config. Without configured backends the public piston instance is
+// used. The runtimes of every backend are cached.
+func NewExecutor(cfg EvalConfig) (Executor, error) {
+	refresh := time.Duration(cfg.RuntimesRefresh) * time.Second
+	if refresh <= 0 {
+		refresh = defaultRuntimesRefresh
+	}
+	if len(cfg.Backends) == 0 {
+		return NewCachedExecutor(NewPistonExecutor(gopiston.CreateDefaultClient(), cfg.EvalLimits), refresh), nil
+	}
+
+	executors := make(map[string]Executor, len(cfg.Backends))
+	for name, backend := range cfg.Backends {
+		switch backend.Type {
+		case EvalBackendPiston:
+			client := gopiston.CreateDefaultClient()
+			if backend.URL != "" {
+				client = gopiston.New(backend.APIKey, client.HttpClient, backend.URL)
+			}
+			executors[name] = NewCachedExecutor(NewPistonExecutor(client, cfg.EvalLimits), refresh)
+		case EvalBackendSandbox:
+			executors[name] = NewSandboxExecutor(backend.Runtimes, cfg.EvalLimits, backend.Sandbox)
+		default:
+			return nil, fmt.Errorf("unknown type %q of eval backend %s", backend.Type, name)
+		}
+	}
+	for language, names := range cfg.Languages {
+		for _, name := range names {
+			if _, ok := executors[name]; !ok {
+				return nil, fmt.Errorf("unknown eval backend %s for language %s", name, language)
+			}
+		}
+	}
+	return NewRoutingExecutor(executors, cfg.Languages), nil
+}
+
+// NewCachedExecutor creates an executor which caches the runtimes of the executor. The first call loads the
+// runtimes, later calls return the cached ones and refresh them in the background once they are older than refresh.
+func NewCachedExecutor(executor Executor, refresh time.Duration) *CachedExecutor {
+	return &CachedExecutor{
+		Executor: executor,
+		refresh:  refresh,
+	}
+}
+
+// CachedExecutor caches the runtimes of an executor. See NewCachedExecutor.
+type CachedExecutor struct {
+	Executor
+	refresh time.Duration
+
+	mu         sync.Mutex
+	runtimes   []Runtime
+	loadedAt   time.Time
+	refreshing bool
+}
+
+func (e *CachedExecutor) Runtimes(ctx context.Context) ([]Runtime, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.runtimes == nil {
+		runtimes, err := e.Executor.Runtimes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		e.runtimes, e.loadedAt = runtimes, time.Now()
+		return runtimes, nil
+	}
+	if time.Since(e.loadedAt) > e.refresh && !e.refreshing {
+		e.refreshing = true
+		go e.reload()
+	}
+	return e.runtimes, nil
+}
+
+func (e *CachedExecutor) reload() {
+	ctx, cancel := context.WithTimeout(context.Background(), runtimesTimeout)
+	defer cancel()
+	runtimes, err := e.Executor.Runtimes(ctx)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.refreshing = false
+	// keep the stale runtimes if the refresh failed and retry on the next call after refresh
+	e.loadedAt = time.Now()
+	if err == nil {
+		e.runtimes = runtimes
+	}
+}
+
+// NewRoutingExecutor creates an executor which selects the backends by language. The backends of a language are
+// tried in order until one of them runs the input. The "*" entry of languages applies to all other languages.
+// Languages without an entry try all backends in name order.
+func NewRoutingExecutor(executors map[string]Executor, languages map[string][]string) *RoutingExecutor {
+	names := make([]string, 0, len(executors))
+	for name := range executors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	routes := make(map[string][]string, len(languages))
+	for language, route := range languages {
+		routes[strings.ToLower(language)] = route
+	}
+	return &RoutingExecutor{
+		executors: executors,
+		routes:    routes,
+		names:     names,
+	}
+}
+
+// RoutingExecutor runs each language on its configured backends. See NewRoutingExecutor.
+type RoutingExecutor struct {
+	executors map[string]Executor
+	routes    map[string][]string
+	names     []string
+}
+
+func (e *RoutingExecutor) route(language string) []string {
+	if route, ok := e.routes[strings.ToLower(language)]; ok {
+		return route
+	}
+	if route, ok := e.routes["*"]; ok {
+		return route
+	}
+	return e.names
+}
+
+// Runtimes returns the runtimes of all backends which are routed to them. If a backend fails, its runtimes are
+// left out.
+func (e *RoutingExecutor) Runtimes(ctx context.Context) ([]Runtime, error) {
+	var (
+		runtimes []Runtime
+		errs     []error
+	)
+	seen := map[string]struct{}{}
+	for _, name := range e.names {
+		backendRuntimes, err := e.executors[name].Runtimes(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		for _, runtime := range backendRuntimes {
+			if !contains(e.route(runtime.Language), name) {
+				continue
+			}
+			key := strings.ToLower(runtime.Language) + "@" + runtime.Version
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			runtimes = append(runtimes, runtime)
+		}
+	}
+	if len(errs) == len(e.names) && len(errs) > 0 {
+		return nil, joinErrors(errs)
+	}
+	return runtimes, nil
+}
+
+// Execute runs the input on the first backend of its language which supports the version. If a backend fails,
+// the next one is tried.
+func (e *RoutingExecutor) Execute(ctx context.Context, input EvalInput) (*ExecResult, error) {
+	var errs []error
+	for _, name := range e.route(input.Language) {
+		executor := e.executors[name]
+		runtimes, err := executor.Runtimes(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		if !supportsRuntime(runtimes, input.Language, input.Version) {
+			continue
+		}
+		rs, err := executor.Execute(ctx, input)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		return rs, nil
+	}
+	if len(errs) > 0 {
+		return nil, joinErrors(errs)
+	}
+	return nil, fmt.Errorf("no eval backend supports %s %s", input.Language, input.Version)
+}
+
+func supportsRuntime(runtimes []Runtime, language string, version string) bool {
+	for _, runtime := range runtimes {
+		if runtime.Matches(language) && (version == "" || runtime.Version == version) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// joinErrors combines the errors of multiple backends into one.
+func joinErrors(errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return fmt.Errorf("all eval backends failed: %s", strings.Join(messages, "; "))
+}
@@ -0,0 +1,81 @@
+package butler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/disgo-butler/butler"
+	"github.com/disgoorg/disgo-butler/internal/evaltest"
+)
+
+func TestRoutingExecutor(t *testing.T) {
+	newExecutor := func(name string, err error, runtimes ...butler.Runtime) *evaltest.Executor {
+		return evaltest.NewExecutor(runtimes, func(input butler.EvalInput) (*butler.ExecResult, error) {
+			if err != nil {
+				return nil, err
+			}
+			return &butler.ExecResult{Language: input.Language, Version: input.Version, Run: butler.ExecStage{Stdout: name}}, nil
+		})
+	}
+	goRuntime := butler.Runtime{Language: "go", Version: "1.20.0", Aliases: []string{"golang"}}
+	pythonRuntime := butler.Runtime{Language: "python", Version: "3.10.0", Aliases: []string{"py"}}
+
+	sandbox := newExecutor("sandbox", errors.New("sandbox unavailable"), goRuntime)
+	piston := newExecutor("piston", nil, goRuntime, pythonRuntime)
+	executor := butler.NewRoutingExecutor(map[string]butler.Executor{
+		"sandbox": sandbox,
+		"piston":  piston,
+	}, map[string][]string{
+		"go": {"sandbox", "piston"},
+		"*":  {"piston"},
+	})
+
+	rs, err := executor.Execute(context.Background(), butler.EvalInput{Language: "go", Version: "1.20.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.Run.Stdout != "piston" || len(sandbox.Inputs()) != 1 {
+		t.Errorf("expected fallback to piston after sandbox, got %s", rs.Run.Stdout)
+	}
+
+	if _, err = executor.Execute(context.Background(), butler.EvalInput{Language: "rust", Version: "1.68.0"}); err == nil {
+		t.Error("expected error for unsupported language")
+	}
+
+	runtimes, err := executor.Runtimes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runtimes) != 2 {
+		t.Errorf("expected deduplicated runtimes, got %+v", runtimes)
+	}
+}
+
+func TestCachedExecutor(t *testing.T) {
+	var calls int
+	fake := evaltest.NewExecutor([]butler.Runtime{{Language: "go", Version: "1.20.0"}}, nil)
+	counting := &countingExecutor{Executor: fake, calls: &calls}
+	executor := butler.NewCachedExecutor(counting, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		runtimes, err := executor.Runtimes(context.Background())
+		if err != nil || len(runtimes) != 1 {
+			t.Fatalf("unexpected runtimes %v: %v", runtimes, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected runtimes to be loaded once, got %d", calls)
+	}
+}
+
+type countingExecutor struct {
+	butler.Executor
+	calls *int
+}
+
+func (e *countingExecutor) Runtimes(ctx context.Context) ([]butler.Runtime, error) {
+	*e.calls++
+	return e.Executor.Runtimes(ctx)
+}
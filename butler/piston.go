@@ -0,0 +1,109 @@
+package butler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	gopiston "github.com/milindmadhukar/go-piston"
+)
+
+// pistonRequest is the body of the piston execute endpoint. go-piston sends the timeouts in seconds while piston
+// expects milliseconds and doesn't expose the compile stage, so the request is built here.
+type pistonRequest struct {
+	Language           string     `json:"language"`
+	Version            string     `json:"version"`
+	Files              []EvalFile `json:"files"`
+	Stdin              string     `json:"stdin,omitempty"`
+	Args               []string   `json:"args,omitempty"`
+	CompileTimeout     int        `json:"compile_timeout,omitempty"`
+	RunTimeout         int        `json:"run_timeout,omitempty"`
+	CompileMemoryLimit int        `json:"compile_memory_limit,omitempty"`
+	RunMemoryLimit     int        `json:"run_memory_limit,omitempty"`
+}
+
+type pistonError struct {
+	Message string `json:"message"`
+}
+
+// NewPistonExecutor creates an executor which runs code on the piston instance of the client.
+func NewPistonExecutor(client *gopiston.Client, limits EvalLimits) *PistonExecutor {
+	return &PistonExecutor{
+		Client: client,
+		Limits: limits,
+	}
+}
+
+// PistonExecutor runs code on a public or self-hosted piston instance.
+type PistonExecutor struct {
+	Client *gopiston.Client
+	Limits EvalLimits
+}
+
+func (e *PistonExecutor) Runtimes(_ context.Context) ([]Runtime, error) {
+	pistonRuntimes, err := e.Client.GetRuntimes()
+	if err != nil {
+		return nil, err
+	}
+	runtimes := make([]Runtime, len(*pistonRuntimes))
+	for i, runtime := range *pistonRuntimes {
+		runtimes[i] = Runtime{
+			Language: runtime.Language,
+			Version:  runtime.Version,
+			Aliases:  runtime.Aliases,
+		}
+	}
+	return runtimes, nil
+}
+
+// Execute runs the input with the configured limits.
+func (e *PistonExecutor) Execute(ctx context.Context, input EvalInput) (*ExecResult, error) {
+	data, err := json.Marshal(pistonRequest{
+		Language:           input.Language,
+		Version:            input.Version,
+		Files:              input.Files,
+		Stdin:              input.Stdin,
+		Args:               input.Args,
+		CompileTimeout:     e.Limits.CompileTimeout,
+		RunTimeout:         e.Limits.RunTimeout,
+		CompileMemoryLimit: e.Limits.CompileMemoryLimit,
+		RunMemoryLimit:     e.Limits.RunMemoryLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(e.Client.BaseURL, "/")+"/execute", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	rq.Header.Set("Content-Type", "application/json")
+	if e.Client.ApiKey != "" {
+		rq.Header.Set("Authorization", e.Client.ApiKey)
+	}
+
+	rs, err := e.Client.HttpClient.Do(rq)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Body.Close()
+
+	if rs.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(rs.Body)
+		var pErr pistonError
+		if err = json.Unmarshal(body, &pErr); err == nil && pErr.Message != "" {
+			return nil, fmt.Errorf("piston: %s", pErr.Message)
+		}
+		return nil, fmt.Errorf("piston: unexpected status code %d: %s", rs.StatusCode, body)
+	}
+
+	var result ExecResult
+	if err = json.NewDecoder(rs.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
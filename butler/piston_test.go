@@ -0,0 +1,50 @@
+package butler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gopiston "github.com/milindmadhukar/go-piston"
+)
+
+func TestPistonExecutor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/execute" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var rq pistonRequest
+		if err := json.NewDecoder(r.Body).Decode(&rq); err != nil {
+			t.Fatal(err)
+		}
+		if rq.RunTimeout != 3000 || rq.CompileTimeout != 10000 || rq.RunMemoryLimit != 64<<20 {
+			t.Errorf("unexpected limits: %+v", rq)
+		}
+		if len(rq.Files) != 2 || rq.Files[1].Name != "util.go" || rq.Stdin != "input" || len(rq.Args) != 1 {
+			t.Errorf("unexpected input: %+v", rq)
+		}
+		_, _ = w.Write([]byte(`{"language":"go","version":"1.16.2","compile":{"stdout":"","stderr":"","output":"","code":0,"signal":null},"run":{"stdout":"hi\n","stderr":"","output":"hi\n","code":0,"signal":null}}`))
+	}))
+	defer server.Close()
+
+	executor := NewPistonExecutor(gopiston.New("", server.Client(), server.URL+"/"), EvalLimits{
+		CompileTimeout: 10000,
+		RunTimeout:     3000,
+		RunMemoryLimit: 64 << 20,
+	})
+	rs, err := executor.Execute(context.Background(), EvalInput{
+		Language: "go",
+		Version:  "1.16.2",
+		Files:    []EvalFile{{Name: "main.go", Content: "package main"}, {Name: "util.go", Content: "package main"}},
+		Stdin:    "input",
+		Args:     []string{"-v"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.Compile == nil || rs.Run.Output != "hi\n" || rs.Run.Code == nil || *rs.Run.Code != 0 {
+		t.Errorf("unexpected result: %+v", rs)
+	}
+}
@@ -0,0 +1,122 @@
+package butler
+
+import (
+	"bytes"
+	"context"
+	"strings"
+)
+
+const (
+	// maxSandboxOutput is the maximum number of bytes kept of stdout and stderr of a sandbox stage.
+	maxSandboxOutput = 64 << 10
+
+	defaultSandboxProcessLimit  = 256
+	defaultSandboxFileSizeLimit = 64 << 20
+	defaultSandboxDiskLimit     = 256 << 20
+)
+
+// defaultSandboxRootPaths are the host paths which are mounted into the sandbox by default. Missing paths are
+// skipped. Only the parts of /etc which programs need to start are included.
+var defaultSandboxRootPaths = []string{"/bin", "/sbin", "/lib", "/lib32", "/lib64", "/usr", "/etc/alternatives", "/etc/ld.so.cache"}
+
+// SandboxRuntime configures how the local sandbox runs a language. The commands are run in /sandbox, which contains
+// the files of the eval.
+type SandboxRuntime struct {
+	Language string   `json:"language"`
+	Version  string   `json:"version"`
+	Aliases  []string `json:"aliases"`
+	// FileName is the name of the entrypoint if the eval doesn't name it, for example main.go.
+	FileName string `json:"file_name"`
+	// Compile is the optional command which compiles the files.
+	Compile []string `json:"compile"`
+	// Run is the command which runs the program. The arguments of the eval are appended.
+	Run []string `json:"run"`
+}
+
+// SandboxOptions configure the filesystem and process limits of the local sandbox. Zero values use the defaults.
+type SandboxOptions struct {
+	// RootPaths are the host paths which are mounted read-only into the sandbox. They have to contain the shell
+	// and the toolchains of the runtimes. Defaults to /bin, /sbin, /lib, /lib32, /lib64, /usr, /etc/alternatives
+	// and /etc/ld.so.cache.
+	RootPaths []string `json:"root_paths"`
+	// ProcessLimit is the maximum number of processes of a stage. Defaults to 256.
+	ProcessLimit int `json:"process_limit"`
+	// FileSizeLimit is the maximum size of a single file written by a stage in bytes. Defaults to 64 MiB.
+	FileSizeLimit int `json:"file_size_limit"`
+	// DiskLimit is the size of the tmpfs the files of the eval are stored in, which is the only writable directory
+	// of the sandbox, in bytes. Defaults to 256 MiB.
+	DiskLimit int `json:"disk_limit"`
+}
+
+// NewSandboxExecutor creates an executor which runs code on the bot host. Every execution runs in new user, mount,
+// pid, network, ipc and uts namespaces with a read-only root filesystem which only contains the root paths. The
+// files of the eval are stored in a size limited tmpfs at /sandbox. The stages run as an unprivileged user with
+// rlimits for cpu time, memory, processes and file size.
+func NewSandboxExecutor(runtimes []SandboxRuntime, limits EvalLimits, options SandboxOptions) *SandboxExecutor {
+	if len(options.RootPaths) == 0 {
+		options.RootPaths = defaultSandboxRootPaths
+	}
+	if options.ProcessLimit <= 0 {
+		options.ProcessLimit = defaultSandboxProcessLimit
+	}
+	if options.FileSizeLimit <= 0 {
+		options.FileSizeLimit = defaultSandboxFileSizeLimit
+	}
+	if options.DiskLimit <= 0 {
+		options.DiskLimit = defaultSandboxDiskLimit
+	}
+	return &SandboxExecutor{
+		runtimes: runtimes,
+		Limits:   limits,
+		Options:  options,
+	}
+}
+
+// SandboxExecutor runs code in a local sandbox. See NewSandboxExecutor.
+type SandboxExecutor struct {
+	runtimes []SandboxRuntime
+	Limits   EvalLimits
+	Options  SandboxOptions
+}
+
+func (e *SandboxExecutor) Runtimes(_ context.Context) ([]Runtime, error) {
+	runtimes := make([]Runtime, len(e.runtimes))
+	for i, runtime := range e.runtimes {
+		runtimes[i] = Runtime{
+			Language: runtime.Language,
+			Version:  runtime.Version,
+			Aliases:  runtime.Aliases,
+		}
+	}
+	return runtimes, nil
+}
+
+func (e *SandboxExecutor) runtime(language string, version string) (SandboxRuntime, bool) {
+	for _, runtime := range e.runtimes {
+		if (strings.EqualFold(runtime.Language, language) || containsFold(runtime.Aliases, language)) && (version == "" || runtime.Version == version) {
+			return runtime, true
+		}
+	}
+	return SandboxRuntime{}, false
+}
+
+// limitedBuffer keeps the first max bytes written to it and discards the rest.
+type limitedBuffer struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.max - b.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buf.Write(p[:remaining])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
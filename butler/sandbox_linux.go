@@ -0,0 +1,412 @@
+package butler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// sandboxInitArg makes the bot binary run as the init process of a sandbox instead of starting the bot.
+	sandboxInitArg = "__butler_sandbox_init"
+	// sandboxDir is the tmpfs in the sandbox which contains the files of the eval.
+	sandboxDir = "/sandbox"
+	// sandboxUID is the unprivileged user the stages run as.
+	sandboxUID = 1000
+
+	// sandboxScript applies the rlimits before it replaces itself with the command. $1 is the cpu time in seconds
+	// and $2 the virtual memory in KiB.
+	sandboxScript = `ulimit -t "$1" && ulimit -v "$2" && shift 2 && exec "$@"`
+)
+
+// sandboxDevices are the devices which are mounted into the sandbox.
+var sandboxDevices = []string{"/dev/null", "/dev/zero", "/dev/random", "/dev/urandom"}
+
+func init() {
+	if len(os.Args) == 2 && os.Args[1] == sandboxInitArg {
+		os.Exit(runSandboxInit())
+	}
+}
+
+// sandboxRequest is sent to the init process of the sandbox on stdin.
+type sandboxRequest struct {
+	Options SandboxOptions    `json:"options"`
+	Path    string            `json:"path"`
+	Files   map[string]string `json:"files"`
+	Stages  []sandboxStage    `json:"stages"`
+}
+
+// sandboxStage is a single command of a sandboxRequest. The timeout is in milliseconds and the memory limit in bytes.
+type sandboxStage struct {
+	Command     []string `json:"command"`
+	Stdin       string   `json:"stdin"`
+	Timeout     int      `json:"timeout"`
+	MemoryLimit int      `json:"memory_limit"`
+}
+
+// Execute starts the init process of the sandbox, which runs the compile and run commands of the runtime.
+func (e *SandboxExecutor) Execute(ctx context.Context, input EvalInput) (*ExecResult, error) {
+	runtime, ok := e.runtime(input.Language, input.Version)
+	if !ok {
+		return nil, fmt.Errorf("sandbox: %s %s is not supported", input.Language, input.Version)
+	}
+	if len(runtime.Run) == 0 {
+		return nil, errors.New("sandbox: empty command")
+	}
+
+	request := sandboxRequest{
+		Options: e.Options,
+		Path:    os.Getenv("PATH"),
+		Files:   make(map[string]string, len(input.Files)),
+	}
+	for i, file := range input.Files {
+		name := filepath.Base(file.Name)
+		if file.Name == "" {
+			name = runtime.FileName
+			if i > 0 || name == "" {
+				name = "file" + strconv.Itoa(i)
+			}
+		}
+		request.Files[name] = file.Content
+	}
+	if len(runtime.Compile) > 0 {
+		request.Stages = append(request.Stages, sandboxStage{
+			Command:     runtime.Compile,
+			Timeout:     e.Limits.CompileTimeout,
+			MemoryLimit: e.Limits.CompileMemoryLimit,
+		})
+	}
+	request.Stages = append(request.Stages, sandboxStage{
+		Command:     append(runtime.Run[:len(runtime.Run):len(runtime.Run)], input.Args...),
+		Stdin:       input.Stdin,
+		Timeout:     e.Limits.RunTimeout,
+		MemoryLimit: e.Limits.RunMemoryLimit,
+	})
+
+	stages, err := startSandbox(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ExecResult{
+		Language: runtime.Language,
+		Version:  runtime.Version,
+	}
+	if len(runtime.Compile) > 0 {
+		result.Compile = &stages[0]
+		if stages = stages[1:]; len(stages) == 0 {
+			return result, nil
+		}
+	}
+	result.Run = stages[0]
+	return result, nil
+}
+
+// startSandbox runs the init process in new user, mount, pid, network, ipc and uts namespaces and returns the results
+// of the stages it ran.
+func startSandbox(ctx context.Context, request sandboxRequest) ([]ExecStage, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout bytes.Buffer
+	stderr := limitedBuffer{max: maxSandboxOutput}
+	cmd := exec.CommandContext(ctx, "/proc/self/exe", sandboxInitArg)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getuid(), Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getgid(), Size: 1},
+		},
+		Pdeathsig: syscall.SIGKILL,
+	}
+	if err = cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("sandbox: %s", message)
+		}
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+
+	var stages []ExecStage
+	if err = json.Unmarshal(stdout.Bytes(), &stages); err != nil {
+		return nil, fmt.Errorf("sandbox: failed to decode results: %w", err)
+	}
+	if len(stages) == 0 {
+		return nil, errors.New("sandbox: no stage was run")
+	}
+	return stages, nil
+}
+
+// runSandboxInit is the init process of the sandbox. It reads a sandboxRequest from stdin, switches to a new root
+// filesystem and runs the stages until one fails. The results are written to stdout and errors to stderr.
+func runSandboxInit() int {
+	var request sandboxRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&request); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to decode request: %s\n", err)
+		return 1
+	}
+	if err := setupSandboxRoot(request); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set up root filesystem: %s\n", err)
+		return 1
+	}
+	// the limits are inherited by the stages and don't change between them, the init process doesn't create
+	// processes or write files itself anymore
+	if err := setRlimit(unix.RLIMIT_NPROC, request.Options.ProcessLimit); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to limit processes: %s\n", err)
+		return 1
+	}
+	if err := setRlimit(unix.RLIMIT_FSIZE, request.Options.FileSizeLimit); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to limit file size: %s\n", err)
+		return 1
+	}
+
+	stages := make([]ExecStage, 0, len(request.Stages))
+	for _, s := range request.Stages {
+		stage, err := runSandboxStage(request.Path, s)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		stages = append(stages, *stage)
+		if stage.Failed() {
+			break
+		}
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(stages); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode results: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// setupSandboxRoot builds the root filesystem in a tmpfs and pivots into it. The root contains read-only binds of the
+// root paths, a few devices, /proc and a size limited tmpfs at sandboxDir with the files of the eval, which is the
+// only writable directory.
+func setupSandboxRoot(request sandboxRequest) error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+	root := os.TempDir()
+	if err := unix.Mount("tmpfs", root, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "size=1m,mode=0755"); err != nil {
+		return fmt.Errorf("failed to mount root: %w", err)
+	}
+
+	for _, path := range request.Options.RootPaths {
+		if err := bindSandboxPath(root, filepath.Clean(path), true); err != nil {
+			return err
+		}
+	}
+	for _, device := range sandboxDevices {
+		if err := bindSandboxPath(root, device, false); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, "proc"), 0755); err != nil {
+		return err
+	}
+	// mounting proc fails if parts of the host proc are hidden, for example in containers
+	_ = unix.Mount("proc", filepath.Join(root, "proc"), "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "")
+
+	dir := filepath.Join(root, sandboxDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := unix.Mount("tmpfs", dir, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, fmt.Sprintf("size=%d,mode=0755", request.Options.DiskLimit)); err != nil {
+		return fmt.Errorf("failed to mount %s: %w", sandboxDir, err)
+	}
+	for name, content := range request.Files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	oldRoot := filepath.Join(root, ".old")
+	if err := os.Mkdir(oldRoot, 0700); err != nil {
+		return err
+	}
+	if err := unix.PivotRoot(root, oldRoot); err != nil {
+		return fmt.Errorf("failed to pivot root: %w", err)
+	}
+	if err := unix.Chdir("/"); err != nil {
+		return err
+	}
+	if err := unix.Unmount("/.old", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("failed to unmount old root: %w", err)
+	}
+	if err := os.Remove("/.old"); err != nil {
+		return err
+	}
+	if err := unix.Mount("", "/", "", unix.MS_REMOUNT|unix.MS_BIND|unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("failed to remount root read-only: %w", err)
+	}
+	return nil
+}
+
+// bindSandboxPath binds the host path to the same path in the root. Symlinks are copied, so paths like /bin which
+// link to /usr/bin keep working. Missing paths are skipped. Mounts below the path are not included.
+func bindSandboxPath(root string, path string, readOnly bool) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	target := filepath.Join(root, path)
+	if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, target)
+	}
+	if info.IsDir() {
+		err = os.MkdirAll(target, 0755)
+	} else {
+		err = os.WriteFile(target, nil, 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = unix.Mount(path, target, "", unix.MS_BIND, ""); err != nil {
+		return fmt.Errorf("failed to bind %s: %w", path, err)
+	}
+	if !readOnly {
+		return nil
+	}
+	// flags of the host mount like nodev are locked in a user namespace and have to be kept when remounting
+	var stat unix.Statfs_t
+	if err = unix.Statfs(target, &stat); err != nil {
+		return err
+	}
+	flags := uintptr(unix.MS_REMOUNT | unix.MS_BIND | unix.MS_RDONLY | unix.MS_NOSUID | unix.MS_NODEV)
+	for statFlag, mountFlag := range map[int64]uintptr{
+		unix.ST_NOEXEC:     unix.MS_NOEXEC,
+		unix.ST_NOATIME:    unix.MS_NOATIME,
+		unix.ST_NODIRATIME: unix.MS_NODIRATIME,
+		unix.ST_RELATIME:   unix.MS_RELATIME,
+	} {
+		if int64(stat.Flags)&statFlag != 0 {
+			flags |= mountFlag
+		}
+	}
+	if err = unix.Mount("", target, "", flags, ""); err != nil {
+		return fmt.Errorf("failed to remount %s read-only: %w", path, err)
+	}
+	return nil
+}
+
+func setRlimit(resource int, limit int) error {
+	return unix.Setrlimit(resource, &unix.Rlimit{Cur: uint64(limit), Max: uint64(limit)})
+}
+
+// runSandboxStage runs a single command in sandboxDir as sandboxUID in a nested user namespace, so the command has
+// no capabilities. Every other process in the sandbox is killed once the command exits or times out.
+func runSandboxStage(path string, s sandboxStage) (*ExecStage, error) {
+	if len(s.Command) == 0 {
+		return nil, errors.New("sandbox: empty command")
+	}
+	ctx := context.Background()
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(s.Timeout)*time.Millisecond)
+		defer cancel()
+	}
+
+	cpuLimit, memLimit := "unlimited", "unlimited"
+	if s.Timeout > 0 {
+		cpuLimit = strconv.Itoa((s.Timeout + 999) / 1000)
+	}
+	if s.MemoryLimit > 0 {
+		memLimit = strconv.Itoa(s.MemoryLimit / 1024)
+	}
+
+	var stdout, stderr, output limitedBuffer
+	stdout.max, stderr.max, output.max = maxSandboxOutput, maxSandboxOutput, 2*maxSandboxOutput
+
+	cmd := exec.Command("/bin/sh", append([]string{"-c", sandboxScript, "sandbox", cpuLimit, memLimit}, s.Command...)...)
+	cmd.Dir = sandboxDir
+	cmd.Env = []string{"PATH=" + path, "HOME=" + sandboxDir, "TMPDIR=" + sandboxDir}
+	cmd.Stdin = strings.NewReader(s.Stdin)
+	cmd.Stdout = io.MultiWriter(&stdout, &output)
+	cmd.Stderr = io.MultiWriter(&stderr, &output)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: sandboxUID, HostID: 0, Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: sandboxUID, HostID: 0, Size: 1},
+		},
+		Pdeathsig: syscall.SIGKILL,
+	}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+	// processes which were started in the background keep the output open, so they are killed on timeout to let
+	// Wait return
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = unix.Kill(-1, unix.SIGKILL)
+		case <-done:
+		}
+	}()
+	err := cmd.Wait()
+	close(done)
+	wallTime := int(time.Since(start).Milliseconds())
+	_ = unix.Kill(-1, unix.SIGKILL)
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+
+	stage := &ExecStage{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Output:   output.String(),
+		WallTime: &wallTime,
+	}
+	state := cmd.ProcessState
+	cpuTime := int((state.UserTime() + state.SystemTime()).Milliseconds())
+	stage.CPUTime = &cpuTime
+
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		signal := unix.SignalName(status.Signal())
+		stage.Signal = &signal
+	} else {
+		code := state.ExitCode()
+		stage.Code = &code
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		status := "TO"
+		stage.Status = &status
+	}
+	return stage, nil
+}
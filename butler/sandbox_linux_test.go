@@ -0,0 +1,50 @@
+package butler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSandboxExecutor(t *testing.T) {
+	hostFile, err := filepath.Abs("sandbox_linux_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	executor := NewSandboxExecutor([]SandboxRuntime{{
+		Language: "sh",
+		FileName: "main.sh",
+		Run:      []string{"/bin/sh", "main.sh"},
+	}}, EvalLimits{RunTimeout: 10000}, SandboxOptions{
+		ProcessLimit:  64,
+		FileSizeLimit: 1 << 20,
+		DiskLimit:     4 << 20,
+	})
+	rs, err := executor.Execute(context.Background(), EvalInput{
+		Language: "sh",
+		Files: []EvalFile{{Content: `
+cat "$1" >/dev/null 2>&1 && echo "host file readable"
+touch /usr/sandbox-test 2>/dev/null && echo "root path writable"
+touch /sandbox-test 2>/dev/null && echo "root writable"
+head -c 2097152 /dev/zero >big 2>/dev/null && echo "file size not limited"
+echo ok >out && cat out
+id -u
+awk '/Max processes/ { print $3 }' /proc/self/limits
+`}},
+		Args: []string{hostFile},
+	})
+	if err != nil {
+		if _, statErr := os.Stat("/proc/self/ns/user"); statErr != nil {
+			t.Skipf("user namespaces are not supported: %s", err)
+		}
+		t.Fatal(err)
+	}
+	if expected := "ok\n1000\n64\n"; rs.Run.Stdout != expected {
+		t.Errorf("expected stdout %q, got %q (stderr %q)", expected, rs.Run.Stdout, rs.Run.Stderr)
+	}
+	if rs.Run.Failed() {
+		t.Errorf("expected run to succeed, got %+v", rs.Run)
+	}
+}
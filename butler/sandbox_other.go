@@ -0,0 +1,13 @@
+//go:build !linux
+
+package butler
+
+import (
+	"context"
+	"errors"
+)
+
+// Execute always fails as the sandbox relies on linux namespaces.
+func (e *SandboxExecutor) Execute(_ context.Context, _ EvalInput) (*ExecResult, error) {
+	return nil, errors.New("sandbox: the local sandbox is only supported on linux")
+}
@@ -0,0 +1,311 @@
+package butler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/json"
+
+	"github.com/disgoorg/disgo-butler/common"
+	"github.com/disgoorg/disgo-butler/db"
+)
+
+const (
+	// MaxTagAttachmentSize is the maximum size of a single attachment of a tag.
+	MaxTagAttachmentSize = 8 * 1024 * 1024
+	// MaxTagAttachments is the maximum number of attachments of a tag.
+	MaxTagAttachments = 10
+	// MaxTagAttachmentsSize is the maximum total size of the attachments of a tag, so they fit into one message.
+	MaxTagAttachmentsSize = 8 * 1024 * 1024
+	// MaxTagsImportSize is the maximum size of a file tags are imported from.
+	MaxTagsImportSize = 25 * 1024 * 1024
+	// MaxTagsExportSize is the maximum size of a file tags are exported to in discord. Larger exports can be created
+	// with the -export-tags flag.
+	MaxTagsExportSize = 8 * 1024 * 1024
+	// MaxTagNameLength is the maximum length of the names and aliases of tags. It keeps the custom ID of the tag modal
+	// within the limit of discord.
+	MaxTagNameLength = 80
+
+	maxTagContentLength = 2000
+	maxTagButtonLength  = 80
+	maxTagButtons       = 5
+	maxTagEmbedFields   = 25
+
+	maxTagEmbedTitleLength       = 256
+	maxTagEmbedDescriptionLength = 4096
+	maxTagEmbedFieldNameLength   = 256
+	maxTagEmbedFieldValueLength  = 1024
+)
+
+// TagMessage creates the message a tag is sent as. Tags can only mention users, so they can't be used to ping roles
+// or everyone. Attachments which exceed MaxTagAttachmentsSize together with the previous ones are left out.
+func TagMessage(tag db.TagBody, attachments []db.TagAttachment) discord.MessageCreate {
+	message := discord.MessageCreate{
+		Content: tag.Content,
+		AllowedMentions: &discord.AllowedMentions{
+			Parse: []discord.AllowedMentionType{discord.AllowedMentionTypeUsers},
+		},
+	}
+	if tag.Embed != nil {
+		message.Embeds = []discord.Embed{tagEmbed(*tag.Embed)}
+	}
+	if len(tag.Buttons) > 0 {
+		row := make(discord.ActionRowComponent, len(tag.Buttons))
+		for i, button := range tag.Buttons {
+			row[i] = discord.NewLinkButton(button.Label, button.URL)
+		}
+		message.Components = []discord.ContainerComponent{row}
+	}
+	var size int
+	for _, attachment := range attachments {
+		if size += len(attachment.Data); size > MaxTagAttachmentsSize {
+			break
+		}
+		message.Files = append(message.Files, discord.NewFile(attachment.Filename, "", bytes.NewReader(attachment.Data)))
+	}
+	return message
+}
+
+func tagEmbed(tagEmbed db.TagEmbed) discord.Embed {
+	embed := discord.Embed{
+		Title:       tagEmbed.Title,
+		URL:         tagEmbed.URL,
+		Description: tagEmbed.Description,
+		Color:       tagEmbed.Color,
+	}
+	if tagEmbed.Image != "" {
+		embed.Image = &discord.EmbedResource{URL: tagEmbed.Image}
+	}
+	for _, field := range tagEmbed.Fields {
+		embed.Fields = append(embed.Fields, discord.EmbedField{
+			Name:   field.Name,
+			Value:  field.Value,
+			Inline: json.Ptr(field.Inline),
+		})
+	}
+	return embed
+}
+
+// ParseTagEmbed creates the embed of a tag from the inputs of the tag modal. The options have one setting per line:
+// "color: #5865f2", "url: https://...", "image: https://...", "field: Name | Value" or "inline: Name | Value". It
+// returns nil if all inputs are empty.
+func ParseTagEmbed(title string, description string, options string) (*db.TagEmbed, error) {
+	embed := db.TagEmbed{
+		Title:       strings.TrimSpace(title),
+		Description: strings.TrimSpace(description),
+	}
+	for i, line := range strings.Split(options, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d of the embed options is not formatted as `key: value`", i+1)
+		}
+		value = strings.TrimSpace(value)
+		switch key = strings.ToLower(strings.TrimSpace(key)); key {
+		case "color":
+			color, err := strconv.ParseInt(strings.TrimPrefix(value, "#"), 16, 32)
+			if err != nil || color < 0 || color > 0xffffff {
+				return nil, fmt.Errorf("invalid color `%s`, use a hex color like #5865f2", value)
+			}
+			embed.Color = int(color)
+		case "url", "image":
+			if !isLink(value) {
+				return nil, fmt.Errorf("invalid %s `%s`, only http and https links are allowed", key, value)
+			}
+			if key == "url" {
+				embed.URL = value
+			} else {
+				embed.Image = value
+			}
+		case "field", "inline":
+			name, fieldValue, ok := strings.Cut(value, "|")
+			if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(fieldValue) == "" {
+				return nil, fmt.Errorf("invalid field `%s`, use `%s: Name | Value`", value, key)
+			}
+			if len(embed.Fields) == maxTagEmbedFields {
+				return nil, fmt.Errorf("embeds can have at most %d fields", maxTagEmbedFields)
+			}
+			embed.Fields = append(embed.Fields, db.TagEmbedField{
+				Name:   strings.TrimSpace(name),
+				Value:  strings.ReplaceAll(strings.TrimSpace(fieldValue), `\n`, "\n"),
+				Inline: key == "inline",
+			})
+		default:
+			return nil, fmt.Errorf("unknown embed option `%s`", key)
+		}
+	}
+	if embed.Title == "" && embed.Description == "" && embed.Image == "" && len(embed.Fields) == 0 {
+		if embed.Color != 0 || embed.URL != "" {
+			return nil, errors.New("embeds need a title, description, image or field")
+		}
+		return nil, nil
+	}
+	if err := ValidateTagEmbed(embed); err != nil {
+		return nil, err
+	}
+	return &embed, nil
+}
+
+// ValidateTagName checks that the name can be used for a tag or an alias.
+func ValidateTagName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("tag names can't be empty")
+	}
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("tag name `%s` contains `/`", name)
+	}
+	if utf8.RuneCountInString(name) > MaxTagNameLength {
+		return fmt.Errorf("tag names can be at most %d characters long", MaxTagNameLength)
+	}
+	return nil
+}
+
+// ValidateTagBody checks the message of a tag the same way ParseTagEmbed and ParseTagButtons do, for tags which were
+// not created with the tag modal.
+func ValidateTagBody(body db.TagBody) error {
+	if body.Content == "" && body.Embed == nil {
+		return errors.New("tags need content or an embed")
+	}
+	if utf8.RuneCountInString(body.Content) > maxTagContentLength {
+		return fmt.Errorf("tag content can be at most %d characters long", maxTagContentLength)
+	}
+	if embed := body.Embed; embed != nil {
+		if embed.URL != "" && !isLink(embed.URL) {
+			return fmt.Errorf("invalid url `%s`, only http and https links are allowed", embed.URL)
+		}
+		if embed.Image != "" && !isLink(embed.Image) {
+			return fmt.Errorf("invalid image `%s`, only http and https links are allowed", embed.Image)
+		}
+		if len(embed.Fields) > maxTagEmbedFields {
+			return fmt.Errorf("embeds can have at most %d fields", maxTagEmbedFields)
+		}
+		for _, field := range embed.Fields {
+			if strings.TrimSpace(field.Name) == "" || strings.TrimSpace(field.Value) == "" {
+				return errors.New("embed fields need a name and a value")
+			}
+		}
+		if err := ValidateTagEmbed(*embed); err != nil {
+			return err
+		}
+	}
+	if len(body.Buttons) > maxTagButtons {
+		return fmt.Errorf("tags can have at most %d buttons", maxTagButtons)
+	}
+	for _, button := range body.Buttons {
+		if strings.TrimSpace(button.Label) == "" || utf8.RuneCountInString(button.Label) > maxTagButtonLength || !isLink(button.URL) {
+			return fmt.Errorf("invalid button `%s | %s`, buttons need a label of up to %d characters and an http or https link", button.Label, button.URL, maxTagButtonLength)
+		}
+	}
+	return nil
+}
+
+// ValidateTagEmbed checks the embed against the length limits of discord, so tags can't be saved which fail to send.
+func ValidateTagEmbed(embed db.TagEmbed) error {
+	if utf8.RuneCountInString(embed.Title) > maxTagEmbedTitleLength {
+		return fmt.Errorf("embed titles can be at most %d characters long", maxTagEmbedTitleLength)
+	}
+	if utf8.RuneCountInString(embed.Description) > maxTagEmbedDescriptionLength {
+		return fmt.Errorf("embed descriptions can be at most %d characters long", maxTagEmbedDescriptionLength)
+	}
+	for _, field := range embed.Fields {
+		if utf8.RuneCountInString(field.Name) > maxTagEmbedFieldNameLength {
+			return fmt.Errorf("embed field names can be at most %d characters long", maxTagEmbedFieldNameLength)
+		}
+		if utf8.RuneCountInString(field.Value) > maxTagEmbedFieldValueLength {
+			return fmt.Errorf("the value of the embed field `%s` can be at most %d characters long", field.Name, maxTagEmbedFieldValueLength)
+		}
+	}
+	if length := common.EmbedLength(tagEmbed(embed)); length > common.MaxEmbedsLength {
+		return fmt.Errorf("embeds can be at most %d characters long, this one has %d", common.MaxEmbedsLength, length)
+	}
+	return nil
+}
+
+// FormatTagEmbedOptions formats the options of the embed the way ParseTagEmbed parses them.
+func FormatTagEmbedOptions(embed *db.TagEmbed) string {
+	if embed == nil {
+		return ""
+	}
+	var lines []string
+	if embed.Color != 0 {
+		lines = append(lines, fmt.Sprintf("color: #%06x", embed.Color))
+	}
+	if embed.URL != "" {
+		lines = append(lines, "url: "+embed.URL)
+	}
+	if embed.Image != "" {
+		lines = append(lines, "image: "+embed.Image)
+	}
+	for _, field := range embed.Fields {
+		key := "field"
+		if field.Inline {
+			key = "inline"
+		}
+		lines = append(lines, key+": "+field.Name+" | "+strings.ReplaceAll(field.Value, "\n", `\n`))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ParseTagButtons parses link buttons with one button per line formatted as "Label | https://...".
+func ParseTagButtons(text string) ([]db.TagButton, error) {
+	var buttons []db.TagButton
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		label, link, ok := strings.Cut(line, "|")
+		label, link = strings.TrimSpace(label), strings.TrimSpace(link)
+		if !ok || label == "" || !isLink(link) {
+			return nil, fmt.Errorf("invalid button `%s`, use `Label | https://...`", line)
+		}
+		if utf8.RuneCountInString(label) > maxTagButtonLength {
+			return nil, fmt.Errorf("button labels can be at most %d characters long", maxTagButtonLength)
+		}
+		if len(buttons) == maxTagButtons {
+			return nil, fmt.Errorf("tags can have at most %d buttons", maxTagButtons)
+		}
+		buttons = append(buttons, db.TagButton{Label: label, URL: link})
+	}
+	return buttons, nil
+}
+
+// FormatTagButtons formats the buttons the way ParseTagButtons parses them.
+func FormatTagButtons(buttons []db.TagButton) string {
+	lines := make([]string, len(buttons))
+	for i, button := range buttons {
+		lines[i] = button.Label + " | " + button.URL
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatTagBody renders the whole message of a tag as text, so revisions can be compared.
+func FormatTagBody(body db.TagBody) string {
+	text := body.Content
+	if body.Embed != nil {
+		text += "\n--- embed ---\n"
+		if body.Embed.Title != "" {
+			text += "title: " + body.Embed.Title + "\n"
+		}
+		if body.Embed.Description != "" {
+			text += body.Embed.Description + "\n"
+		}
+		text += FormatTagEmbedOptions(body.Embed)
+	}
+	if len(body.Buttons) > 0 {
+		text += "\n--- buttons ---\n" + FormatTagButtons(body.Buttons)
+	}
+	return strings.TrimSpace(text)
+}
+
+func isLink(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
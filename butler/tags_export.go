@@ -0,0 +1,361 @@
+package butler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/disgoorg/snowflake/v2"
+	"gopkg.in/yaml.v3"
+
+	"github.com/disgoorg/disgo-butler/db"
+)
+
+// tagsExportVersion is the version of the export format. It is increased on incompatible changes.
+const tagsExportVersion = 1
+
+// TagsFormat is the file format of exported tags.
+type TagsFormat string
+
+const (
+	TagsFormatJSON TagsFormat = "json"
+	TagsFormatYAML TagsFormat = "yaml"
+)
+
+// TagsFormatFromFilename returns the format of the file by its extension. Files which are not YAML are read as JSON.
+func TagsFormatFromFilename(filename string) TagsFormat {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return TagsFormatYAML
+	}
+	return TagsFormatJSON
+}
+
+// TagConflict decides what happens to imported tags whose name is already taken.
+type TagConflict string
+
+const (
+	// TagConflictSkip keeps the existing tag.
+	TagConflictSkip TagConflict = "skip"
+	// TagConflictOverwrite replaces the message of the existing tag with a new revision and replaces its files. Names
+	// which are taken by an alias are renamed instead, so the tag of the alias is kept.
+	TagConflictOverwrite TagConflict = "overwrite"
+	// TagConflictRename imports the tag with a number appended to its name.
+	TagConflictRename TagConflict = "rename"
+)
+
+// ParseTagConflict returns the conflict strategy with the given name.
+func ParseTagConflict(name string) (TagConflict, error) {
+	switch conflict := TagConflict(name); conflict {
+	case TagConflictSkip, TagConflictOverwrite, TagConflictRename:
+		return conflict, nil
+	}
+	return "", fmt.Errorf("unknown conflict strategy %q, use skip, overwrite or rename", name)
+}
+
+// TagsExport is the file exported tags are stored in.
+type TagsExport struct {
+	Version    int           `json:"version" yaml:"version"`
+	GuildID    snowflake.ID  `json:"guild_id" yaml:"guild_id"`
+	ExportedAt time.Time     `json:"exported_at" yaml:"exported_at"`
+	Tags       []ExportedTag `json:"tags" yaml:"tags"`
+}
+
+// ExportedTag is a tag with its aliases and files. Its uses and timestamps are informational and are not restored on
+// import.
+type ExportedTag struct {
+	Name        string            `json:"name" yaml:"name"`
+	Content     string            `json:"content,omitempty" yaml:"content,omitempty"`
+	Embed       *db.TagEmbed      `json:"embed,omitempty" yaml:"embed,omitempty"`
+	Buttons     []db.TagButton    `json:"buttons,omitempty" yaml:"buttons,omitempty"`
+	Aliases     []string          `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	Attachments []ExportedTagFile `json:"attachments,omitempty" yaml:"attachments,omitempty"`
+	OwnerID     snowflake.ID      `json:"owner_id" yaml:"owner_id"`
+	Uses        int               `json:"uses" yaml:"uses"`
+	CreatedAt   time.Time         `json:"created_at" yaml:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at" yaml:"updated_at"`
+}
+
+// ExportedTagFile is an attachment of an exported tag. Its data is base64 encoded in JSON and binary in YAML. Files
+// of exports which would be too large are exported without their data.
+type ExportedTagFile struct {
+	Filename string `json:"filename" yaml:"filename"`
+	Size     int    `json:"size" yaml:"size"`
+	Data     []byte `json:"data,omitempty" yaml:"data,omitempty"`
+}
+
+// TagsImportResult lists what happened to the imported tags.
+type TagsImportResult struct {
+	Created     []string
+	Overwritten []string
+	Skipped     []string
+	// Renamed maps the name in the file to the name the tag was imported as.
+	Renamed map[string]string
+}
+
+func (r TagsImportResult) String() string {
+	return fmt.Sprintf("%d created, %d overwritten, %d renamed, %d skipped", len(r.Created), len(r.Overwritten), len(r.Renamed), len(r.Skipped))
+}
+
+// ExportTags collects all tags of the guild with their aliases and files. The data of the files is included until
+// it exceeds maxFilesSize in total, the remaining files are exported without their data. A negative maxFilesSize
+// includes the data of all files.
+func ExportTags(tagsDB db.TagsDB, guildID snowflake.ID, maxFilesSize int) (TagsExport, error) {
+	tags, err := tagsDB.GetAll(guildID)
+	if err != nil {
+		return TagsExport{}, err
+	}
+	aliases, err := tagsDB.GetAllAliases(guildID)
+	if err != nil {
+		return TagsExport{}, err
+	}
+	tagAliases := map[string][]string{}
+	for _, alias := range aliases {
+		tagAliases[alias.TagName] = append(tagAliases[alias.TagName], alias.Name)
+	}
+
+	export := TagsExport{
+		Version:    tagsExportVersion,
+		GuildID:    guildID,
+		ExportedAt: time.Now().UTC(),
+		Tags:       make([]ExportedTag, len(tags)),
+	}
+	var filesSize int
+	for i, tag := range tags {
+		attachments, err := tagsDB.GetAttachments(guildID, tag.Name)
+		if err != nil {
+			return TagsExport{}, err
+		}
+		var files []ExportedTagFile
+		for _, attachment := range attachments {
+			file := ExportedTagFile{Filename: attachment.Filename, Size: len(attachment.Data)}
+			if maxFilesSize < 0 || filesSize+file.Size <= maxFilesSize {
+				file.Data = attachment.Data
+				filesSize += file.Size
+			}
+			files = append(files, file)
+		}
+		export.Tags[i] = ExportedTag{
+			Name:        tag.Name,
+			Content:     tag.Content,
+			Embed:       tag.Embed,
+			Buttons:     tag.Buttons,
+			Aliases:     tagAliases[tag.Name],
+			Attachments: files,
+			OwnerID:     tag.OwnerID,
+			Uses:        tag.Uses,
+			CreatedAt:   tag.CreatedAt,
+			UpdatedAt:   tag.UpdatedAt,
+		}
+	}
+	return export, nil
+}
+
+// OmittedFiles returns the number of files which were exported without their data.
+func (e TagsExport) OmittedFiles() int {
+	var omitted int
+	for _, tag := range e.Tags {
+		for _, file := range tag.Attachments {
+			if file.Data == nil && file.Size > 0 {
+				omitted++
+			}
+		}
+	}
+	return omitted
+}
+
+// EncodeTags writes the export in the format.
+func EncodeTags(export TagsExport, format TagsFormat) ([]byte, error) {
+	if format == TagsFormatYAML {
+		return yaml.Marshal(export)
+	}
+	return json.MarshalIndent(export, "", "\t")
+}
+
+// DecodeTags reads an export in the format and validates it.
+func DecodeTags(data []byte, format TagsFormat) (TagsExport, error) {
+	var (
+		export TagsExport
+		err    error
+	)
+	if format == TagsFormatYAML {
+		err = yaml.Unmarshal(data, &export)
+	} else {
+		err = json.Unmarshal(data, &export)
+	}
+	if err != nil {
+		return TagsExport{}, fmt.Errorf("failed to read %s: %w", format, err)
+	}
+	if export.Version > tagsExportVersion {
+		return TagsExport{}, fmt.Errorf("the file has version %d, but only versions up to %d are supported", export.Version, tagsExportVersion)
+	}
+	for i, tag := range export.Tags {
+		if err = ValidateTagName(tag.Name); err != nil {
+			return TagsExport{}, fmt.Errorf("tag %d: %w", i+1, err)
+		}
+		for _, alias := range tag.Aliases {
+			if err = ValidateTagName(alias); err != nil {
+				return TagsExport{}, fmt.Errorf("alias of tag %s: %w", tag.Name, err)
+			}
+		}
+		if err = ValidateTagBody(db.TagBody{Content: tag.Content, Embed: tag.Embed, Buttons: tag.Buttons}); err != nil {
+			return TagsExport{}, fmt.Errorf("tag %s: %w", tag.Name, err)
+		}
+		if len(tag.Attachments) > MaxTagAttachments {
+			return TagsExport{}, fmt.Errorf("tag %s has more than %d attachments", tag.Name, MaxTagAttachments)
+		}
+		var size int
+		filenames := map[string]struct{}{}
+		for _, attachment := range tag.Attachments {
+			if strings.TrimSpace(attachment.Filename) == "" {
+				return TagsExport{}, fmt.Errorf("tag %s has a file without a name", tag.Name)
+			}
+			if _, ok := filenames[attachment.Filename]; ok {
+				return TagsExport{}, fmt.Errorf("tag %s has multiple files named %s", tag.Name, attachment.Filename)
+			}
+			filenames[attachment.Filename] = struct{}{}
+			if len(attachment.Data) > MaxTagAttachmentSize {
+				return TagsExport{}, fmt.Errorf("file %s of tag %s is larger than %d MiB", attachment.Filename, tag.Name, MaxTagAttachmentSize/1024/1024)
+			}
+			size += len(attachment.Data)
+		}
+		if size > MaxTagAttachmentsSize {
+			return TagsExport{}, fmt.Errorf("the files of tag %s are larger than %d MiB together", tag.Name, MaxTagAttachmentsSize/1024/1024)
+		}
+	}
+	return export, nil
+}
+
+// ImportTags creates the tags of the export in the guild. Tags whose name is taken by a tag or alias are handled by
+// the conflict strategy. Owners are kept, except for tags without owner, which are owned by the importer. Aliases
+// whose name is taken and files without data are left out. The tags are imported in one transaction, so nothing is
+// imported if an error is returned.
+func ImportTags(tagsDB db.TagsDB, guildID snowflake.ID, export TagsExport, conflict TagConflict, importerID snowflake.ID) (TagsImportResult, error) {
+	var result TagsImportResult
+	err := tagsDB.RunInTx(func(tagsDB db.TagsDB) error {
+		var err error
+		result, err = importTags(tagsDB, guildID, export, conflict, importerID)
+		return err
+	})
+	if err != nil {
+		return TagsImportResult{}, err
+	}
+	return result, nil
+}
+
+func importTags(tagsDB db.TagsDB, guildID snowflake.ID, export TagsExport, conflict TagConflict, importerID snowflake.ID) (TagsImportResult, error) {
+	result := TagsImportResult{Renamed: map[string]string{}}
+	for _, tag := range export.Tags {
+		name := strings.ToLower(tag.Name)
+		body := db.TagBody{Content: tag.Content, Embed: tag.Embed, Buttons: tag.Buttons}
+		ownerID := tag.OwnerID
+		if ownerID == 0 {
+			ownerID = importerID
+		}
+
+		// Get resolves aliases, only a tag with the same name is overwritten
+		existing, err := tagsDB.Get(guildID, name)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			if err = tagsDB.Create(guildID, ownerID, name, body); err != nil {
+				return result, fmt.Errorf("failed to create tag %s: %w", name, err)
+			}
+			result.Created = append(result.Created, name)
+		case err != nil:
+			return result, err
+		case conflict == TagConflictSkip:
+			result.Skipped = append(result.Skipped, name)
+			continue
+		case conflict == TagConflictOverwrite && existing.Name == name:
+			if err = tagsDB.Edit(guildID, name, body, importerID); err != nil {
+				return result, fmt.Errorf("failed to overwrite tag %s: %w", name, err)
+			}
+			if err = removeTagFiles(tagsDB, guildID, name, tag.Attachments); err != nil {
+				return result, err
+			}
+			result.Overwritten = append(result.Overwritten, name)
+		default:
+			newName, err := freeTagName(tagsDB, guildID, name)
+			if err != nil {
+				return result, err
+			}
+			if err = tagsDB.Create(guildID, ownerID, newName, body); err != nil {
+				return result, fmt.Errorf("failed to create tag %s: %w", newName, err)
+			}
+			result.Renamed[name] = newName
+			name = newName
+		}
+
+		for _, attachment := range tag.Attachments {
+			// files which were exported without their data are left out
+			if attachment.Data == nil && attachment.Size > 0 {
+				continue
+			}
+			if err = tagsDB.SetAttachment(db.TagAttachment{
+				GuildID:  guildID,
+				TagName:  name,
+				Filename: attachment.Filename,
+				Data:     attachment.Data,
+			}); err != nil {
+				return result, fmt.Errorf("failed to add file %s to tag %s: %w", attachment.Filename, name, err)
+			}
+		}
+		for _, alias := range tag.Aliases {
+			alias = strings.ToLower(alias)
+			if _, err = tagsDB.Get(guildID, alias); !errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err = tagsDB.CreateAlias(guildID, alias, name); err != nil {
+				return result, fmt.Errorf("failed to add alias %s to tag %s: %w", alias, name, err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// removeTagFiles removes the files of an overwritten tag, so they are replaced by the imported files. Files which were
+// exported without their data are kept.
+func removeTagFiles(tagsDB db.TagsDB, guildID snowflake.ID, name string, imported []ExportedTagFile) error {
+	attachments, err := tagsDB.GetAttachments(guildID, name)
+	if err != nil {
+		return err
+	}
+	keep := map[string]bool{}
+	for _, file := range imported {
+		if file.Data == nil && file.Size > 0 {
+			keep[file.Filename] = true
+		}
+	}
+	for _, attachment := range attachments {
+		if keep[attachment.Filename] {
+			continue
+		}
+		if err = tagsDB.DeleteAttachment(guildID, name, attachment.Filename); err != nil {
+			return fmt.Errorf("failed to remove file %s of tag %s: %w", attachment.Filename, name, err)
+		}
+	}
+	return nil
+}
+
+// freeTagName appends the first number to the name which makes it unique. The name is shortened if the number would
+// make it longer than MaxTagNameLength.
+func freeTagName(tagsDB db.TagsDB, guildID snowflake.ID, name string) (string, error) {
+	for i := 2; ; i++ {
+		suffix := "-" + strconv.Itoa(i)
+		base := []rune(name)
+		if len(base)+len(suffix) > MaxTagNameLength {
+			base = base[:MaxTagNameLength-len(suffix)]
+		}
+		newName := string(base) + suffix
+		if _, err := tagsDB.Get(guildID, newName); errors.Is(err, sql.ErrNoRows) {
+			return newName, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
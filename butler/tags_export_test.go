@@ -0,0 +1,346 @@
+package butler
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/disgoorg/snowflake/v2"
+
+	"github.com/disgoorg/disgo-butler/db"
+)
+
+func TestTagsExportImport(t *testing.T) {
+	source := newMemTagsDB()
+	_ = source.Create(1, 10, "intents", db.TagBody{Content: "use intents", Buttons: []db.TagButton{{Label: "Docs", URL: "https://example.com"}}})
+	_ = source.Create(1, 11, "embed", db.TagBody{Embed: &db.TagEmbed{Title: "Embed", Color: 0xff0000}})
+	_ = source.CreateAlias(1, "intent", "intents")
+	_ = source.SetAttachment(db.TagAttachment{GuildID: 1, TagName: "intents", Filename: "intents.go", Data: []byte("package main")})
+
+	export, err := ExportTags(source, 1, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []TagsFormat{TagsFormatJSON, TagsFormatYAML} {
+		data, err := EncodeTags(export, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := DecodeTags(data, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded.Tags, export.Tags) {
+			t.Errorf("expected %s to round trip, got %+v", format, decoded.Tags)
+		}
+	}
+
+	target := newMemTagsDB()
+	_ = target.Create(2, 20, "intents", db.TagBody{Content: "old"})
+	result, err := ImportTags(target, 2, export, TagConflictRename, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Renamed["intents"] != "intents-2" || len(result.Created) != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	tag, _ := target.Get(2, "intent")
+	if tag.Name != "intents-2" || tag.OwnerID != 10 {
+		t.Errorf("expected alias of renamed tag, got %+v", tag)
+	}
+	if attachments, _ := target.GetAttachments(2, "intents-2"); len(attachments) != 1 {
+		t.Errorf("expected attachment of renamed tag, got %+v", attachments)
+	}
+
+	if result, err = ImportTags(target, 2, export, TagConflictSkip, 30); err != nil || len(result.Skipped) != 2 {
+		t.Errorf("expected all tags to be skipped, got %+v, %v", result, err)
+	}
+	if result, err = ImportTags(target, 2, export, TagConflictOverwrite, 30); err != nil || len(result.Overwritten) != 2 {
+		t.Errorf("expected all tags to be overwritten, got %+v, %v", result, err)
+	}
+	if tag, _ = target.Get(2, "intents"); tag.Content != "use intents" || tag.OwnerID != 20 {
+		t.Errorf("expected overwritten content with the old owner, got %+v", tag)
+	}
+
+	if _, err = DecodeTags([]byte(`{"version":1,"tags":[{"name":"a/b","content":"x"}]}`), TagsFormatJSON); err == nil {
+		t.Error("expected error for invalid tag name")
+	}
+}
+
+func TestDecodeTagsValidation(t *testing.T) {
+	long := strings.Repeat("a", MaxTagNameLength+1)
+	tests := []struct {
+		name string
+		tag  string
+	}{
+		{"long name", `{"name":"` + long + `","content":"x"}`},
+		{"long alias", `{"name":"a","content":"x","aliases":["` + long + `"]}`},
+		{"alias with slash", `{"name":"a","content":"x","aliases":["a/b"]}`},
+		{"empty", `{"name":"a"}`},
+		{"too many buttons", `{"name":"a","content":"x","buttons":[` + strings.Repeat(`{"label":"l","url":"https://example.com"},`, maxTagButtons) + `{"label":"l","url":"https://example.com"}]}`},
+		{"button link", `{"name":"a","content":"x","buttons":[{"label":"l","url":"javascript:alert(1)"}]}`},
+		{"image link", `{"name":"a","embed":{"title":"t","image":"file:///etc/passwd"}}`},
+		{"too many fields", `{"name":"a","embed":{"fields":[` + strings.Repeat(`{"name":"n","value":"v"},`, maxTagEmbedFields) + `{"name":"n","value":"v"}]}}`},
+		{"empty field", `{"name":"a","embed":{"fields":[{"name":"n","value":""}]}}`},
+		{"long description", `{"name":"a","embed":{"description":"` + strings.Repeat("d", maxTagEmbedDescriptionLength+1) + `"}}`},
+		{"empty filename", `{"name":"a","content":"x","attachments":[{"filename":"","data":"eA=="}]}`},
+		{"duplicate filename", `{"name":"a","content":"x","attachments":[{"filename":"a.go","data":"eA=="},{"filename":"a.go","data":"eA=="}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeTags([]byte(`{"version":1,"tags":[`+tt.tag+`]}`), TagsFormatJSON); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestTagsImportOverwrite(t *testing.T) {
+	target := newMemTagsDB()
+	_ = target.Create(1, 10, "other", db.TagBody{Content: "other"})
+	_ = target.CreateAlias(1, "intent", "other")
+	_ = target.Create(1, 10, "intents", db.TagBody{Content: "old"})
+	_ = target.SetAttachment(db.TagAttachment{GuildID: 1, TagName: "intents", Filename: "old.go", Data: []byte("old")})
+
+	export := TagsExport{Tags: []ExportedTag{
+		{Name: "intent", Content: "new intent"},
+		{Name: "intents", Content: "new", Attachments: []ExportedTagFile{{Filename: "new.go", Size: 3, Data: []byte("new")}}},
+	}}
+	result, err := ImportTags(target, 1, export, TagConflictOverwrite, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Renamed["intent"] != "intent-2" || len(result.Overwritten) != 1 {
+		t.Errorf("expected alias name to be renamed, got %+v", result)
+	}
+	if tag, _ := target.Get(1, "other"); tag.Content != "other" {
+		t.Errorf("expected tag of the alias to be kept, got %+v", tag)
+	}
+	if attachments, _ := target.GetAttachments(1, "intents"); len(attachments) != 1 || attachments[0].Filename != "new.go" {
+		t.Errorf("expected files to be replaced, got %+v", attachments)
+	}
+}
+
+func TestTagsExportMaxFilesSize(t *testing.T) {
+	source := newMemTagsDB()
+	_ = source.Create(1, 10, "a", db.TagBody{Content: "a"})
+	_ = source.Create(1, 10, "b", db.TagBody{Content: "b"})
+	_ = source.SetAttachment(db.TagAttachment{GuildID: 1, TagName: "a", Filename: "a.txt", Data: []byte("aaaa")})
+	_ = source.SetAttachment(db.TagAttachment{GuildID: 1, TagName: "b", Filename: "b.txt", Data: []byte("bbbb")})
+
+	export, err := ExportTags(source, 1, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if omitted := export.OmittedFiles(); omitted != 1 {
+		t.Fatalf("expected one file without data, got %d", omitted)
+	}
+
+	target := newMemTagsDB()
+	if _, err = ImportTags(target, 2, export, TagConflictSkip, 30); err != nil {
+		t.Fatal(err)
+	}
+	var files int
+	for _, name := range []string{"a", "b"} {
+		attachments, _ := target.GetAttachments(2, name)
+		files += len(attachments)
+	}
+	if files != 1 {
+		t.Errorf("expected only the file with data to be imported, got %d files", files)
+	}
+}
+
+func TestImportTagsRollback(t *testing.T) {
+	export := TagsExport{Tags: []ExportedTag{
+		{Name: "a", Content: "a", Aliases: []string{"b"}, Attachments: []ExportedTagFile{{Filename: "a.txt", Data: []byte("a")}}},
+		{Name: "c", Content: "c"},
+	}}
+	target := newMemTagsDB()
+	target.failCreate = "c"
+	if result, err := ImportTags(target, 1, export, TagConflictSkip, 30); err == nil || len(result.Created) != 0 {
+		t.Errorf("expected error without result, got %+v, %v", result, err)
+	}
+	if len(target.tags[1]) != 0 || len(target.aliases[1]) != 0 || len(target.attachments[1]) != 0 {
+		t.Errorf("expected import to be rolled back, got %v, %v and %v", target.tags, target.aliases, target.attachments)
+	}
+}
+
+// memTagsDB is an in memory db.TagsDB for tests. It ignores revisions.
+type memTagsDB struct {
+	tags        map[snowflake.ID]map[string]db.Tag
+	aliases     map[snowflake.ID]map[string]string
+	attachments map[snowflake.ID]map[string][]db.TagAttachment
+	// failCreate is the name of a tag which can't be created.
+	failCreate string
+}
+
+func newMemTagsDB() *memTagsDB {
+	return &memTagsDB{
+		tags:        map[snowflake.ID]map[string]db.Tag{},
+		aliases:     map[snowflake.ID]map[string]string{},
+		attachments: map[snowflake.ID]map[string][]db.TagAttachment{},
+	}
+}
+
+func (m *memTagsDB) resolve(guildID snowflake.ID, name string) string {
+	if tagName, ok := m.aliases[guildID][name]; ok {
+		return tagName
+	}
+	return name
+}
+
+func (m *memTagsDB) Get(guildID snowflake.ID, name string) (db.Tag, error) {
+	tag, ok := m.tags[guildID][m.resolve(guildID, name)]
+	if !ok {
+		return db.Tag{}, sql.ErrNoRows
+	}
+	return tag, nil
+}
+
+func (m *memTagsDB) GetAndIncrement(guildID snowflake.ID, name string, _ snowflake.ID, _ snowflake.ID) (db.Tag, error) {
+	tag, err := m.Get(guildID, name)
+	if err == nil {
+		tag.Uses++
+		m.tags[guildID][tag.Name] = tag
+	}
+	return tag, err
+}
+
+func (m *memTagsDB) GetAll(guildID snowflake.ID) ([]db.Tag, error) {
+	var tags []db.Tag
+	for _, tag := range m.tags[guildID] {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func (m *memTagsDB) GetAllUser(guildID snowflake.ID, userID snowflake.ID) ([]db.Tag, error) {
+	var tags []db.Tag
+	all, _ := m.GetAll(guildID)
+	for _, tag := range all {
+		if tag.OwnerID == userID {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+func (m *memTagsDB) Create(guildID snowflake.ID, ownerID snowflake.ID, name string, body db.TagBody) error {
+	if name == m.failCreate {
+		return errors.New("create failed")
+	}
+	if m.tags[guildID] == nil {
+		m.tags[guildID] = map[string]db.Tag{}
+	}
+	m.tags[guildID][name] = db.Tag{GuildID: guildID, Name: name, OwnerID: ownerID, Content: body.Content, Embed: body.Embed, Buttons: body.Buttons}
+	return nil
+}
+
+func (m *memTagsDB) Edit(guildID snowflake.ID, name string, body db.TagBody, _ snowflake.ID) error {
+	tag := m.tags[guildID][name]
+	tag.Content, tag.Embed, tag.Buttons = body.Content, body.Embed, body.Buttons
+	m.tags[guildID][name] = tag
+	return nil
+}
+
+func (m *memTagsDB) Delete(guildID snowflake.ID, name string) error {
+	delete(m.tags[guildID], name)
+	return nil
+}
+
+func (m *memTagsDB) Transfer(guildID snowflake.ID, name string, ownerID snowflake.ID) error {
+	tag := m.tags[guildID][name]
+	tag.OwnerID = ownerID
+	m.tags[guildID][name] = tag
+	return nil
+}
+
+func (m *memTagsDB) GetAttachments(guildID snowflake.ID, tagName string) ([]db.TagAttachment, error) {
+	return m.attachments[guildID][tagName], nil
+}
+
+func (m *memTagsDB) SetAttachment(attachment db.TagAttachment) error {
+	if m.attachments[attachment.GuildID] == nil {
+		m.attachments[attachment.GuildID] = map[string][]db.TagAttachment{}
+	}
+	m.attachments[attachment.GuildID][attachment.TagName] = append(m.attachments[attachment.GuildID][attachment.TagName], attachment)
+	return nil
+}
+
+func (m *memTagsDB) DeleteAttachment(guildID snowflake.ID, tagName string, _ string) error {
+	delete(m.attachments[guildID], tagName)
+	return nil
+}
+
+func (m *memTagsDB) GetRevisions(snowflake.ID, string) ([]db.TagRevision, error) {
+	return nil, nil
+}
+
+func (m *memTagsDB) GetRevision(snowflake.ID, string, int) (db.TagRevision, error) {
+	return db.TagRevision{}, sql.ErrNoRows
+}
+
+func (m *memTagsDB) GetAlias(guildID snowflake.ID, name string) (db.TagAlias, error) {
+	tagName, ok := m.aliases[guildID][name]
+	if !ok {
+		return db.TagAlias{}, sql.ErrNoRows
+	}
+	return db.TagAlias{GuildID: guildID, Name: name, TagName: tagName}, nil
+}
+
+func (m *memTagsDB) GetAliases(guildID snowflake.ID, tagName string) ([]db.TagAlias, error) {
+	var aliases []db.TagAlias
+	all, _ := m.GetAllAliases(guildID)
+	for _, alias := range all {
+		if alias.TagName == tagName {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases, nil
+}
+
+func (m *memTagsDB) GetAllAliases(guildID snowflake.ID) ([]db.TagAlias, error) {
+	var aliases []db.TagAlias
+	for name, tagName := range m.aliases[guildID] {
+		aliases = append(aliases, db.TagAlias{GuildID: guildID, Name: name, TagName: tagName})
+	}
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Name < aliases[j].Name })
+	return aliases, nil
+}
+
+func (m *memTagsDB) CreateAlias(guildID snowflake.ID, name string, tagName string) error {
+	if m.aliases[guildID] == nil {
+		m.aliases[guildID] = map[string]string{}
+	}
+	m.aliases[guildID][name] = tagName
+	return nil
+}
+
+func (m *memTagsDB) DeleteAlias(guildID snowflake.ID, name string) error {
+	delete(m.aliases[guildID], name)
+	return nil
+}
+
+func (m *memTagsDB) RunInTx(f func(tagsDB db.TagsDB) error) error {
+	tags, aliases, attachments := cloneGuilds(m.tags), cloneGuilds(m.aliases), cloneGuilds(m.attachments)
+	if err := f(m); err != nil {
+		m.tags, m.aliases, m.attachments = tags, aliases, attachments
+		return err
+	}
+	return nil
+}
+
+func cloneGuilds[V any](guilds map[snowflake.ID]map[string]V) map[snowflake.ID]map[string]V {
+	clone := make(map[snowflake.ID]map[string]V, len(guilds))
+	for guildID, values := range guilds {
+		clone[guildID] = make(map[string]V, len(values))
+		for name, value := range values {
+			clone[guildID][name] = value
+		}
+	}
+	return clone
+}
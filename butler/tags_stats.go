@@ -0,0 +1,121 @@
+package butler
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/disgoorg/snowflake/v2"
+
+	"github.com/disgoorg/disgo-butler/db"
+)
+
+const day = 24 * time.Hour
+
+// TagStats are the uses of the tags of a guild over a time window.
+type TagStats struct {
+	Top      []db.TagUseCount
+	Trending []TagTrend
+	Unused   []db.Tag
+	TopUsers []db.TagUserUseCount
+}
+
+// TagTrend compares the uses of a tag in the window to the window before it.
+type TagTrend struct {
+	TagName      string
+	Uses         int
+	PreviousUses int
+}
+
+// GetTagStats collects the stats of the tags of the guild over the last days. Each list has at most limit entries,
+// except for the unused tags.
+func GetTagStats(usesDB db.TagUsesDB, guildID snowflake.ID, days int, limit int) (TagStats, error) {
+	now := time.Now()
+	from := now.Add(-time.Duration(days) * day)
+	current, err := usesDB.GetTagUseCounts(guildID, from, now)
+	if err != nil {
+		return TagStats{}, err
+	}
+	previous, err := usesDB.GetTagUseCounts(guildID, from.Add(-time.Duration(days)*day), from)
+	if err != nil {
+		return TagStats{}, err
+	}
+	unused, err := usesDB.GetUnusedTags(guildID, from)
+	if err != nil {
+		return TagStats{}, err
+	}
+	topUsers, err := usesDB.GetTagUserUseCounts(guildID, from, now, limit)
+	if err != nil {
+		return TagStats{}, err
+	}
+
+	top := current
+	if len(top) > limit {
+		top = top[:limit]
+	}
+	return TagStats{
+		Top:      top,
+		Trending: trendingTags(current, previous, limit),
+		Unused:   unused,
+		TopUsers: topUsers,
+	}, nil
+}
+
+// trendingTags returns the tags which gained the most uses compared to the previous window.
+func trendingTags(current []db.TagUseCount, previous []db.TagUseCount, limit int) []TagTrend {
+	previousUses := make(map[string]int, len(previous))
+	for _, count := range previous {
+		previousUses[count.TagName] = count.Uses
+	}
+	var trends []TagTrend
+	for _, count := range current {
+		if count.Uses > previousUses[count.TagName] {
+			trends = append(trends, TagTrend{
+				TagName:      count.TagName,
+				Uses:         count.Uses,
+				PreviousUses: previousUses[count.TagName],
+			})
+		}
+	}
+	sort.SliceStable(trends, func(i, j int) bool {
+		return trends[i].Uses-trends[i].PreviousUses > trends[j].Uses-trends[j].PreviousUses
+	})
+	if len(trends) > limit {
+		trends = trends[:limit]
+	}
+	return trends
+}
+
+// DailyTagUses returns the uses of each of the last days up to today in UTC, including days without uses.
+func DailyTagUses(uses []db.TagDailyUses, days int, now time.Time) []int {
+	start := now.UTC().Truncate(day).Add(-time.Duration(days-1) * day)
+	values := make([]int, days)
+	for _, use := range uses {
+		i := int(use.Day.UTC().Truncate(day).Sub(start) / day)
+		if i >= 0 && i < days {
+			values[i] += use.Uses
+		}
+	}
+	return values
+}
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws the values as a line of block characters scaled to the largest value.
+func Sparkline(values []int) string {
+	maxValue := 0
+	for _, value := range values {
+		if value > maxValue {
+			maxValue = value
+		}
+	}
+	var sb strings.Builder
+	for _, value := range values {
+		i := 0
+		if maxValue > 0 {
+			i = value * (len(sparkBlocks) - 1) / maxValue
+		}
+		sb.WriteRune(sparkBlocks[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,50 @@
+package butler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/disgo-butler/db"
+)
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		values []int
+		want   string
+	}{
+		{values: nil, want: ""},
+		{values: []int{0, 0, 0}, want: "▁▁▁"},
+		{values: []int{0, 1, 2, 3, 4, 5, 6, 7}, want: "▁▂▃▄▅▆▇█"},
+		{values: []int{1, 100}, want: "▁█"},
+	}
+	for _, tt := range tests {
+		if got := Sparkline(tt.values); got != tt.want {
+			t.Errorf("Sparkline(%v) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestDailyTagUses(t *testing.T) {
+	now := time.Date(2023, 3, 10, 15, 0, 0, 0, time.UTC)
+	uses := []db.TagDailyUses{
+		{Day: time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), Uses: 9},
+		{Day: time.Date(2023, 3, 8, 0, 0, 0, 0, time.UTC), Uses: 2},
+		{Day: time.Date(2023, 3, 10, 0, 0, 0, 0, time.UTC), Uses: 5},
+	}
+	if got, want := DailyTagUses(uses, 3, now), []int{2, 0, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTrendingTags(t *testing.T) {
+	current := []db.TagUseCount{{TagName: "a", Uses: 10}, {TagName: "b", Uses: 8}, {TagName: "c", Uses: 3}, {TagName: "d", Uses: 1}}
+	previous := []db.TagUseCount{{TagName: "a", Uses: 9}, {TagName: "b", Uses: 2}, {TagName: "d", Uses: 4}}
+	want := []TagTrend{
+		{TagName: "b", Uses: 8, PreviousUses: 2},
+		{TagName: "c", Uses: 3, PreviousUses: 0},
+	}
+	if got := trendingTags(current, previous, 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
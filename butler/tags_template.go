@@ -0,0 +1,160 @@
+package butler
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/disgoorg/disgo"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/snowflake/v2"
+
+	"github.com/disgoorg/disgo-butler/db"
+)
+
+// maxTagVariables is the maximum number of variables which are replaced in a single text of a tag. Further variables
+// are left as they are.
+const maxTagVariables = 50
+
+// blankTagText replaces field names, field values and button labels which are empty after rendering, as discord
+// doesn't allow them to be empty.
+const blankTagText = "\u200b"
+
+// TagContext is what the variables of a tag are replaced with.
+type TagContext struct {
+	User      discord.User
+	ChannelID snowflake.ID
+	GuildName string
+	// Args is the text the tag was called with.
+	Args    string
+	Version string
+}
+
+// lookup returns the value of the variable. Supported variables are user, user.name, user.id, channel, guild, args,
+// args.N (the Nth word of args starting at 1), version and disgo.version.
+func (c TagContext) lookup(name string) (string, bool) {
+	switch name {
+	case "user":
+		return discord.UserMention(c.User.ID), true
+	case "user.name":
+		return c.User.Username, true
+	case "user.id":
+		return c.User.ID.String(), true
+	case "channel":
+		return discord.ChannelMention(c.ChannelID), true
+	case "guild":
+		return c.GuildName, true
+	case "args":
+		return c.Args, true
+	case "version":
+		return c.Version, true
+	case "disgo.version":
+		return disgo.Version, true
+	}
+	if strings.HasPrefix(name, "args.") {
+		n, err := strconv.Atoi(strings.TrimPrefix(name, "args."))
+		if err != nil || n < 1 {
+			return "", false
+		}
+		if args := strings.Fields(c.Args); n <= len(args) {
+			return args[n-1], true
+		}
+		return "", true
+	}
+	return "", false
+}
+
+// RenderTag replaces the variables in the content, embed and button labels of the tag. Variables are written as
+// {{name}} or {{name|fallback}}, where the fallback is used if the variable is empty. Values are inserted as they are
+// and never rendered again, so arguments can't inject variables. It returns the names of unknown variables, which
+// are left as they are. Embeds which are empty after rendering are left out, so the rendered tag can be empty if it
+// only uses variables which weren't given, see TagBodyEmpty.
+func RenderTag(body db.TagBody, ctx TagContext) (db.TagBody, []string) {
+	r := tagRenderer{ctx: ctx, seen: map[string]struct{}{}}
+	rendered := db.TagBody{
+		Content: r.render(body.Content, 2000),
+	}
+	if body.Embed != nil {
+		embed := *body.Embed
+		embed.Title = r.render(embed.Title, 256)
+		embed.Description = r.render(embed.Description, 4096)
+		embed.Fields = make([]db.TagEmbedField, len(body.Embed.Fields))
+		for i, field := range body.Embed.Fields {
+			embed.Fields[i] = db.TagEmbedField{
+				Name:   orBlank(r.render(field.Name, 256)),
+				Value:  orBlank(r.render(field.Value, 1024)),
+				Inline: field.Inline,
+			}
+		}
+		if embed.Title != "" || embed.Description != "" || embed.Image != "" || len(embed.Fields) > 0 {
+			rendered.Embed = &embed
+		}
+	}
+	if len(body.Buttons) > 0 {
+		rendered.Buttons = make([]db.TagButton, len(body.Buttons))
+		for i, button := range body.Buttons {
+			rendered.Buttons[i] = db.TagButton{
+				Label: orBlank(r.render(button.Label, 80)),
+				URL:   button.URL,
+			}
+		}
+	}
+	return rendered, r.unknown
+}
+
+// TagBodyEmpty reports whether the tag has neither content nor an embed.
+func TagBodyEmpty(body db.TagBody) bool {
+	return strings.TrimSpace(body.Content) == "" && body.Embed == nil
+}
+
+func orBlank(text string) string {
+	if strings.TrimSpace(text) == "" {
+		return blankTagText
+	}
+	return text
+}
+
+type tagRenderer struct {
+	ctx     TagContext
+	unknown []string
+	seen    map[string]struct{}
+}
+
+// render replaces the variables of the text and cuts the result to maxLen characters.
+func (r *tagRenderer) render(text string, maxLen int) string {
+	var sb strings.Builder
+	for replaced := 0; ; replaced++ {
+		start := strings.Index(text, "{{")
+		if start < 0 || replaced == maxTagVariables {
+			break
+		}
+		end := strings.Index(text[start:], "}}")
+		if end < 0 {
+			break
+		}
+		end += start
+		sb.WriteString(text[:start])
+
+		name, fallback, _ := strings.Cut(text[start+2:end], "|")
+		name = strings.TrimSpace(name)
+		value, ok := r.ctx.lookup(name)
+		if !ok {
+			if _, seen := r.seen[name]; !seen {
+				r.seen[name] = struct{}{}
+				r.unknown = append(r.unknown, name)
+			}
+			value = text[start : end+2]
+		} else if value == "" {
+			value = fallback
+		}
+		sb.WriteString(value)
+		text = text[end+2:]
+	}
+	sb.WriteString(text)
+
+	rendered := sb.String()
+	if utf8.RuneCountInString(rendered) > maxLen {
+		rendered = string([]rune(rendered)[:maxLen])
+	}
+	return rendered
+}
@@ -0,0 +1,170 @@
+package butler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/disgoorg/disgo/discord"
+
+	"github.com/disgoorg/disgo-butler/db"
+)
+
+func TestParseTagEmbed(t *testing.T) {
+	options := "color: #5865f2\nimage: https://example.com/intents.png\nfield: Guilds | 1 << 0\ninline: Members | privileged\\nrequires approval"
+	embed, err := ParseTagEmbed("Intents", " Gateway intents ", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &db.TagEmbed{
+		Title:       "Intents",
+		Description: "Gateway intents",
+		Color:       0x5865f2,
+		Image:       "https://example.com/intents.png",
+		Fields: []db.TagEmbedField{
+			{Name: "Guilds", Value: "1 << 0"},
+			{Name: "Members", Value: "privileged\nrequires approval", Inline: true},
+		},
+	}
+	if !reflect.DeepEqual(embed, expected) {
+		t.Errorf("expected %+v, got %+v", expected, embed)
+	}
+	if formatted := FormatTagEmbedOptions(embed); formatted != options {
+		t.Errorf("expected options to round trip, got %q", formatted)
+	}
+
+	if embed, err = ParseTagEmbed("", "", ""); embed != nil || err != nil {
+		t.Errorf("expected no embed, got %+v, %v", embed, err)
+	}
+	for _, options := range []string{"color: blue", "image: javascript:alert(1)", "field: no value", "size: 3", "color: #ffffff"} {
+		if _, err = ParseTagEmbed("", "", options); err == nil {
+			t.Errorf("expected error for options %q", options)
+		}
+	}
+}
+
+func TestParseTagEmbedLength(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		options     string
+	}{
+		{"long field name", "", "field: " + strings.Repeat("n", maxTagEmbedFieldNameLength+1) + " | value"},
+		{"long field value", "", "field: name | " + strings.Repeat("v", maxTagEmbedFieldValueLength+1)},
+		{"long embed", strings.Repeat("d", 4000), strings.Repeat("field: name | "+strings.Repeat("v", 1000)+"\n", 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseTagEmbed("title", tt.description, tt.options); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	options := "field: " + strings.Repeat("n", maxTagEmbedFieldNameLength) + " | " + strings.Repeat("v", maxTagEmbedFieldValueLength)
+	if _, err := ParseTagEmbed("title", strings.Repeat("d", 4000), options); err != nil {
+		t.Errorf("expected embed at the limits to be valid, got %s", err)
+	}
+}
+
+func TestParseTagButtons(t *testing.T) {
+	buttons, err := ParseTagButtons("Docs | https://pkg.go.dev/github.com/disgoorg/disgo\n\nGitHub|https://github.com/disgoorg/disgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []db.TagButton{
+		{Label: "Docs", URL: "https://pkg.go.dev/github.com/disgoorg/disgo"},
+		{Label: "GitHub", URL: "https://github.com/disgoorg/disgo"},
+	}
+	if !reflect.DeepEqual(buttons, expected) {
+		t.Errorf("expected %+v, got %+v", expected, buttons)
+	}
+	if _, err = ParseTagButtons("Docs"); err == nil {
+		t.Error("expected error for button without link")
+	}
+}
+
+func TestTagMessage(t *testing.T) {
+	message := TagMessage(db.TagBody{
+		Content: "see below",
+		Embed:   &db.TagEmbed{Title: "Intents"},
+		Buttons: []db.TagButton{{Label: "Docs", URL: "https://example.com"}},
+	}, []db.TagAttachment{{Filename: "intents.go", Data: []byte("package main")}})
+
+	if message.Content != "see below" || len(message.Embeds) != 1 || message.Embeds[0].Title != "Intents" {
+		t.Errorf("unexpected message %+v", message)
+	}
+	if button := message.Components[0].(discord.ActionRowComponent)[0].(discord.ButtonComponent); button.URL != "https://example.com" {
+		t.Errorf("expected link button, got %+v", button)
+	}
+	if len(message.Files) != 1 || message.Files[0].Name != "intents.go" {
+		t.Errorf("expected attachment, got %+v", message.Files)
+	}
+
+	message = TagMessage(db.TagBody{Content: "files"}, []db.TagAttachment{
+		{Filename: "a.bin", Data: make([]byte, MaxTagAttachmentsSize/2)},
+		{Filename: "b.bin", Data: make([]byte, MaxTagAttachmentsSize/2)},
+		{Filename: "c.bin", Data: make([]byte, 1)},
+	})
+	if len(message.Files) != 2 {
+		t.Errorf("expected attachments over the size limit to be left out, got %d files", len(message.Files))
+	}
+}
+
+func TestRenderTag(t *testing.T) {
+	ctx := TagContext{
+		User:      discord.User{ID: 1, Username: "topi"},
+		ChannelID: 2,
+		Args:      "foo {{user}}",
+		Version:   "dev",
+	}
+	body, unknown := RenderTag(db.TagBody{
+		Content: "Hi {{user}} in {{ channel }}: {{args}} / {{args.1}} / {{args.3|none}} {{env.HOME}} {{version}}",
+		Embed:   &db.TagEmbed{Title: "{{user.name}}", Fields: []db.TagEmbedField{{Name: "{{args.2}}", Value: "{{nope}}"}}},
+	}, ctx)
+
+	expected := "Hi <@1> in <#2>: foo {{user}} / foo / none {{env.HOME}} dev"
+	if body.Content != expected {
+		t.Errorf("expected %q, got %q", expected, body.Content)
+	}
+	if body.Embed.Title != "topi" || body.Embed.Fields[0].Name != "{{user}}" {
+		t.Errorf("unexpected embed %+v", body.Embed)
+	}
+	if !reflect.DeepEqual(unknown, []string{"env.HOME", "nope"}) {
+		t.Errorf("expected unknown variables, got %v", unknown)
+	}
+
+	long, _ := RenderTag(db.TagBody{Content: strings.Repeat("{{args}}", 100)}, TagContext{Args: strings.Repeat("a", 100)})
+	if len(long.Content) != 2000 {
+		t.Errorf("expected content to be cut to 2000 characters, got %d", len(long.Content))
+	}
+}
+
+func TestRenderTagEmpty(t *testing.T) {
+	body := db.TagBody{
+		Content: "{{args}}",
+		Embed: &db.TagEmbed{
+			Title:  "{{args.1}}",
+			Fields: []db.TagEmbedField{{Name: "{{args.1}}", Value: "{{args.2}}"}},
+		},
+		Buttons: []db.TagButton{{Label: "{{args.1}}", URL: "https://example.com"}},
+	}
+	rendered, _ := RenderTag(body, TagContext{})
+	if TagBodyEmpty(rendered) {
+		t.Error("expected tag with embed fields to not be empty")
+	}
+	if field := rendered.Embed.Fields[0]; field.Name != blankTagText || field.Value != blankTagText {
+		t.Errorf("expected empty field to be filled, got %+v", field)
+	}
+	if rendered.Buttons[0].Label != blankTagText {
+		t.Errorf("expected empty button label to be filled, got %q", rendered.Buttons[0].Label)
+	}
+
+	body.Embed.Fields = nil
+	if rendered, _ = RenderTag(body, TagContext{}); rendered.Embed != nil || !TagBodyEmpty(rendered) {
+		t.Errorf("expected empty embed to be left out, got %+v", rendered)
+	}
+	if rendered, _ = RenderTag(body, TagContext{Args: "foo"}); TagBodyEmpty(rendered) || rendered.Embed.Title != "foo" {
+		t.Errorf("expected rendered tag, got %+v", rendered)
+	}
+}
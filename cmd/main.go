@@ -1,8 +1,12 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"os"
 
+	"github.com/disgoorg/disgo/bot"
 	"github.com/disgoorg/disgo/handler"
 	"github.com/disgoorg/log"
 	"github.com/disgoorg/snowflake/v2"
@@ -11,6 +15,8 @@ import (
 	"github.com/disgoorg/disgo-butler/butler"
 	"github.com/disgoorg/disgo-butler/commands"
 	"github.com/disgoorg/disgo-butler/components"
+	"github.com/disgoorg/disgo-butler/listeners"
+	"github.com/disgoorg/disgo-butler/modals"
 	"github.com/disgoorg/disgo-butler/routes"
 )
 
@@ -19,11 +25,21 @@ const version = "development"
 var (
 	shouldSyncDBTables *bool
 	shouldSyncCommands *bool
+	exportTagsFile     *string
+	importTagsFile     *string
+	tagsGuildID        *string
+	tagsConflict       *string
+	tagsOwnerID        *string
 )
 
 func init() {
 	shouldSyncDBTables = flag.Bool("sync-db", false, "Whether to sync the database tables")
 	shouldSyncCommands = flag.Bool("sync-commands", false, "Whether to sync the commands")
+	exportTagsFile = flag.String("export-tags", "", "Exports the tags of -tags-guild to the JSON or YAML file and exits")
+	importTagsFile = flag.String("import-tags", "", "Imports the tags of the JSON or YAML file into -tags-guild and exits")
+	tagsGuildID = flag.String("tags-guild", "", "The guild to export or import tags, defaults to the guild of the file when importing")
+	tagsConflict = flag.String("tags-conflict", string(butler.TagConflictSkip), "What to do with imported tags which already exist: skip, overwrite or rename")
+	tagsOwnerID = flag.String("tags-owner", "", "The user who owns imported tags without owner and edits overwritten tags, required if the import has such tags")
 	flag.Parse()
 }
 
@@ -39,6 +55,14 @@ func main() {
 
 	b := butler.New(logger, version, *cfg)
 
+	if *exportTagsFile != "" || *importTagsFile != "" {
+		b.SetupDB(*shouldSyncDBTables)
+		if err = migrateTags(b); err != nil {
+			logger.Fatalf("Failed to migrate tags: %s", err)
+		}
+		return
+	}
+
 	r := chi.NewRouter()
 	r.Route("/github", func(r chi.Router) {
 		r.Get("/", routes.HandleGithub(b))
@@ -66,13 +90,25 @@ func main() {
 		})
 	})
 	cr.Route("/docs", func(cr handler.Router) {
-		cr.Command("/", commands.HandleDocs(b))
-		cr.Autocomplete("/", commands.HandleDocsAutocomplete(b))
+		cr.Command("/lookup", commands.HandleDocs(b))
+		cr.Autocomplete("/lookup", commands.HandleDocsAutocomplete(b))
+		cr.Command("/search", commands.HandleDocsSearch(b))
+		cr.Command("/diff", commands.HandleDocsDiff(b))
+		cr.Autocomplete("/diff", commands.HandleDocsDiffAutocomplete(b))
 	})
 	cr.Component("docs_action", components.HandleDocsAction(b))
+	cr.Component("docs_page/{direction}", components.HandleDocsPage(b))
+	cr.Component("docs_related", components.HandleDocsRelated(b))
+	cr.Component("docs_back", components.HandleDocsBack(b))
+	cr.Component("docs_search", components.HandleDocsSearch(b))
 	cr.Component("eval/rerun/{message_id}", components.HandleEvalRerunAction(b))
+	cr.Component("eval/edit/{message_id}", components.HandleEvalEditAction(b))
+	cr.Component("eval/select/{message_id}", components.HandleEvalSelectAction(b))
 	cr.Component("eval/delete", components.HandleEvalDeleteAction)
+	cr.Modal("eval_modal/{mode}/{language}/{version}", modals.HandleEval(b))
+	cr.Modal("tags/{mode}/{name}", modals.HandleTag(b))
 	cr.Command("/eval", commands.HandleEval(b))
+	cr.Autocomplete("/eval", commands.HandleEvalAutocomplete(b))
 	cr.Command("/info", commands.HandleInfo(b))
 	cr.Command("/ping", commands.HandlePing)
 	cr.Route("/tag", func(cr handler.Router) {
@@ -85,13 +121,42 @@ func main() {
 		cr.Command("/delete", commands.HandleDeleteTag(b))
 		cr.Command("/info", commands.HandleTagInfo(b))
 		cr.Command("/list", commands.HandleListTags(b))
+		cr.Command("/history", commands.HandleTagHistory(b))
+		cr.Command("/rollback", commands.HandleTagRollback(b))
+		cr.Command("/transfer", commands.HandleTransferTag(b))
+		cr.Command("/claim", commands.HandleClaimTag(b))
+		cr.Command("/stats", commands.HandleTagStats(b))
+		cr.Command("/export", commands.HandleExportTags(b))
+		cr.Command("/import", commands.HandleImportTags(b))
 		cr.Autocomplete("/edit", commands.HandleTagListAutoComplete(b, true))
 		cr.Autocomplete("/delete", commands.HandleTagListAutoComplete(b, true))
 		cr.Autocomplete("/info", commands.HandleTagListAutoComplete(b, false))
 		cr.Autocomplete("/list", commands.HandleTagListAutoComplete(b, false))
+		cr.Autocomplete("/history", commands.HandleTagListAutoComplete(b, false))
+		cr.Autocomplete("/rollback", commands.HandleTagListAutoComplete(b, true))
+		cr.Autocomplete("/transfer", commands.HandleTagListAutoComplete(b, true))
+		cr.Autocomplete("/claim", commands.HandleTagListAutoComplete(b, false))
+		cr.Route("/attachment", func(cr handler.Router) {
+			cr.Command("/add", commands.HandleAddTagAttachment(b))
+			cr.Command("/remove", commands.HandleRemoveTagAttachment(b))
+			cr.Autocomplete("/add", commands.HandleTagListAutoComplete(b, true))
+			cr.Autocomplete("/remove", commands.HandleTagListAutoComplete(b, true))
+		})
+		cr.Route("/alias", func(cr handler.Router) {
+			cr.Command("/add", commands.HandleAddTagAlias(b))
+			cr.Command("/remove", commands.HandleRemoveTagAlias(b))
+			cr.Command("/list", commands.HandleListTagAliases(b))
+			cr.Autocomplete("/add", commands.HandleTagListAutoComplete(b, true))
+			cr.Autocomplete("/remove", commands.HandleTagAliasAutoComplete(b))
+			cr.Autocomplete("/list", commands.HandleTagListAutoComplete(b, false))
+		})
 	})
 	cr.Command("/close-ticket", commands.HandleCloseTicket(b))
 	b.SetupBot(cr)
+	b.Client.AddEventListeners(
+		bot.NewListenerFunc(listeners.HandleEvalMessageUpdate(b)),
+		bot.NewListenerFunc(listeners.HandleEvalMessageDelete(b)),
+	)
 	b.SetupDB(*shouldSyncDBTables)
 	b.RegisterLinkedRoles()
 
@@ -104,3 +169,77 @@ func main() {
 	}
 	b.StartAndBlock()
 }
+
+// migrateTags exports or imports the tags of a guild from the command line, so tags can be seeded or moved between
+// guilds and bot instances.
+func migrateTags(b *butler.Butler) error {
+	var guildID snowflake.ID
+	if *tagsGuildID != "" {
+		id, err := snowflake.Parse(*tagsGuildID)
+		if err != nil {
+			return fmt.Errorf("invalid guild id: %w", err)
+		}
+		guildID = id
+	}
+
+	if *exportTagsFile != "" {
+		if guildID == 0 {
+			return errors.New("-tags-guild is required to export tags")
+		}
+		export, err := butler.ExportTags(b.DB, guildID, -1)
+		if err != nil {
+			return err
+		}
+		data, err := butler.EncodeTags(export, butler.TagsFormatFromFilename(*exportTagsFile))
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(*exportTagsFile, data, 0644); err != nil {
+			return err
+		}
+		b.Logger.Infof("exported %d tags to %s", len(export.Tags), *exportTagsFile)
+		return nil
+	}
+
+	conflict, err := butler.ParseTagConflict(*tagsConflict)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(*importTagsFile)
+	if err != nil {
+		return err
+	}
+	export, err := butler.DecodeTags(data, butler.TagsFormatFromFilename(*importTagsFile))
+	if err != nil {
+		return err
+	}
+	if guildID == 0 {
+		guildID = export.GuildID
+	}
+	if guildID == 0 {
+		return errors.New("-tags-guild is required as the file has no guild")
+	}
+	var ownerID snowflake.ID
+	if *tagsOwnerID != "" {
+		if ownerID, err = snowflake.Parse(*tagsOwnerID); err != nil {
+			return fmt.Errorf("invalid owner id: %w", err)
+		}
+	} else if conflict == butler.TagConflictOverwrite {
+		return errors.New("-tags-owner is required to overwrite tags")
+	} else {
+		for _, tag := range export.Tags {
+			if tag.OwnerID == 0 {
+				return fmt.Errorf("-tags-owner is required as tag %s has no owner", tag.Name)
+			}
+		}
+	}
+	result, err := butler.ImportTags(b.DB, guildID, export, conflict, ownerID)
+	if err != nil {
+		return fmt.Errorf("%w, no tags were imported", err)
+	}
+	b.Logger.Infof("imported tags from %s: %s", *importTagsFile, result)
+	for name, newName := range result.Renamed {
+		b.Logger.Infof("tag %s was imported as %s", name, newName)
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ var Commands = []discord.ApplicationCommandCreate{
 	configCommand,
 	docsCommand,
 	evalCommand,
+	evalSlashCommand,
 	infoCommand,
 	pingCommand,
 	tagCommand,
@@ -75,6 +75,11 @@ var configCommand = discord.SlashCommandCreate{
 							Description: "The role you want to ping when a new release is available.",
 							Required:    true,
 						},
+						discord.ApplicationCommandOptionString{
+							Name:        "module",
+							Description: "The go module of the repository to summarize API changes for.",
+							Required:    false,
+						},
 					},
 				},
 				{
@@ -192,6 +197,7 @@ func HandleReleasesAdd(b *butler.Butler) handler.CommandHandler {
 			WebhookID:    webhook.ID(),
 			WebhookToken: webhook.Token,
 			PingRole:     pingRoleID,
+			Module:       data.String("module"),
 		}
 		if err = butler.SaveConfig(b.Config); err != nil {
 			return common.RespondErr(e.Respond, err)
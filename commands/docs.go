@@ -2,6 +2,7 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -19,17 +20,58 @@ var docsCommand = discord.SlashCommandCreate{
 	Name:        "docs",
 	Description: "Provides info to the provided module, type, function, etc.",
 	Options: []discord.ApplicationCommandOption{
-		discord.ApplicationCommandOptionString{
-			Name:         "module",
-			Description:  "The module to lookup. Example: github.com/disgoorg/disgo/discord",
-			Required:     true,
-			Autocomplete: true,
+		discord.ApplicationCommandOptionSubCommand{
+			Name:        "lookup",
+			Description: "Provides info to the provided module, type, function, etc.",
+			Options: []discord.ApplicationCommandOption{
+				discord.ApplicationCommandOptionString{
+					Name:         "module",
+					Description:  "The module to lookup. Example: github.com/disgoorg/disgo/discord",
+					Required:     true,
+					Autocomplete: true,
+				},
+				discord.ApplicationCommandOptionString{
+					Name:         "query",
+					Description:  "The lookup query. Example: MessageCreate",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
 		},
-		discord.ApplicationCommandOptionString{
-			Name:         "query",
-			Description:  "The lookup query. Example: MessageCreate",
-			Required:     true,
-			Autocomplete: true,
+		discord.ApplicationCommandOptionSubCommand{
+			Name:        "search",
+			Description: "Searches a type, function or method in all known modules.",
+			Options: []discord.ApplicationCommandOption{
+				discord.ApplicationCommandOptionString{
+					Name:        "query",
+					Description: "The symbol to search. Example: WithIntents",
+					Required:    true,
+				},
+			},
+		},
+		discord.ApplicationCommandOptionSubCommand{
+			Name:        "diff",
+			Description: "Compares the exported API of two versions of a module.",
+			Options: []discord.ApplicationCommandOption{
+				discord.ApplicationCommandOptionString{
+					Name:         "module",
+					Description:  "The module to compare. Example: github.com/disgoorg/disgo",
+					Required:     true,
+					Autocomplete: true,
+				},
+				discord.ApplicationCommandOptionString{
+					Name:         "from",
+					Description:  "The old version. Example: v0.15.0",
+					Required:     true,
+					Autocomplete: true,
+				},
+				discord.ApplicationCommandOptionString{
+					Name:         "to",
+					Description:  "The new version. Example: v0.15.1",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
 		},
 	},
 }
@@ -40,21 +82,130 @@ func HandleDocs(b *butler.Butler) handler.CommandHandler {
 
 		ex, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		defer cancel()
-		pkg, err := b.DocClient.Search(ex, data.String("module"))
+		state := butler.NewDocsState(b.Config.Docs.Aliases, data.String("module"), data.String("query"))
+		pkg, err := b.DocClient.Search(ex, state.SearchQuery())
 		if err != nil {
 			return common.RespondErr(e.Respond, err)
 		}
+		b.DocsUsage.Increment(pkg.URL)
 
-		embed, selectMenu := butler.GetDocsEmbed(pkg, data.String("query"), false, false, false, false)
+		embed, components := b.DocsEmbed(pkg, &state)
+		b.DocsStates.Set(e.ID(), state)
 
 		return e.CreateMessage(discord.NewMessageCreateBuilder().
 			SetEmbeds(embed).
-			AddActionRow(selectMenu).
+			SetContainerComponents(components...).
 			Build(),
 		)
 	}
 }
 
+func HandleDocsSearch(b *butler.Butler) handler.CommandHandler {
+	return func(e *handler.CommandEvent) error {
+		query := e.SlashCommandInteractionData().String("query")
+
+		results := b.SearchSymbols(query, 25)
+		if len(results) == 0 {
+			return common.RespondErrMessagef(e.Respond, "No symbols found for `%s`.", query)
+		}
+
+		var (
+			description string
+			options     []discord.StringSelectMenuOption
+		)
+		for i, result := range results {
+			value := result.Package + "#" + result.Symbol
+			if len(value) > 100 {
+				continue
+			}
+			description += fmt.Sprintf("%d. [`%s.%s`](https://pkg.go.dev/%s#%s)\n", i+1, result.Name, result.Symbol, result.Package, result.Symbol)
+			options = append(options, discord.NewStringSelectMenuOption(result.Name+"."+result.Symbol, value).
+				WithDescription(result.Package),
+			)
+		}
+
+		return e.CreateMessage(discord.NewMessageCreateBuilder().
+			SetEmbeds(discord.NewEmbedBuilder().
+				SetTitlef("Results for `%s`", query).
+				SetDescription(description).
+				SetColor(common.ColorSuccess).
+				Build(),
+			).
+			AddActionRow(discord.NewStringSelectMenu("docs_search", "Select a symbol", options...)).
+			Build(),
+		)
+	}
+}
+
+func HandleDocsDiff(b *butler.Butler) handler.CommandHandler {
+	return func(e *handler.CommandEvent) error {
+		data := e.SlashCommandInteractionData()
+		module := resolveAlias(b, data.String("module"))
+
+		if err := e.DeferCreateMessage(false); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		diff, err := b.ModuleSource.Diff(ctx, module, data.String("from"), data.String("to"))
+		if err != nil {
+			_, err = e.UpdateInteractionResponse(discord.MessageUpdate{
+				Embeds: &[]discord.Embed{{
+					Description: fmt.Sprintf("Failed to compare versions: %s", err),
+					Color:       common.ColorError,
+				}},
+			})
+			return err
+		}
+
+		_, err = e.UpdateInteractionResponse(discord.MessageUpdate{
+			Embeds: &[]discord.Embed{butler.GetAPIDiffEmbed(diff, 4096)},
+		})
+		return err
+	}
+}
+
+func HandleDocsDiffAutocomplete(b *butler.Butler) handler.AutocompleteHandler {
+	return func(e *handler.AutocompleteEvent) error {
+		if option, ok := e.Data.Option("module"); ok && option.Focused {
+			return handleModuleAutocomplete(b, e, e.Data.String("module"))
+		}
+
+		var version string
+		if option, ok := e.Data.Option("from"); ok && option.Focused {
+			version = e.Data.String("from")
+		} else if option, ok = e.Data.Option("to"); ok && option.Focused {
+			version = e.Data.String("to")
+		} else {
+			return e.Result(nil)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		versions, err := b.ModuleSource.Versions(ctx, resolveAlias(b, e.Data.String("module")))
+		if err != nil {
+			return e.Result(nil)
+		}
+
+		choices := make([]discord.AutocompleteChoice, 0, 25)
+		for i := len(versions) - 1; i >= 0 && len(choices) < 25; i-- {
+			if strings.HasPrefix(versions[i], version) {
+				choices = append(choices, discord.AutocompleteChoiceString{Name: versions[i], Value: versions[i]})
+			}
+		}
+		return e.Result(choices)
+	}
+}
+
+// resolveAlias returns the module of a docs alias or the module itself if it is no alias.
+func resolveAlias(b *butler.Butler, module string) string {
+	if aliasModule, ok := b.Config.Docs.Aliases[module]; ok {
+		return aliasModule
+	}
+	return module
+}
+
 func HandleDocsAutocomplete(b *butler.Butler) handler.AutocompleteHandler {
 	return func(e *handler.AutocompleteEvent) error {
 		moduleOption, moduleOptionOk := e.Data.Option("module")
@@ -80,9 +231,8 @@ func handleModuleAutocomplete(b *butler.Butler, e *handler.AutocompleteEvent, mo
 			}
 		})
 	} else {
-		e, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-		defer cancel()
-		_, _ = b.DocClient.Search(e, module)
+		// don't wait for the module, it will show up in one of the next autocomplete requests
+		b.DocClient.Prefetch(e.User().ID.String(), module)
 		b.DocClient.WithCache(func(cache map[string]*doc.CachedPackage) {
 			var packages []string
 			for _, pkg := range cache {
@@ -110,6 +260,10 @@ func handleQueryAutocomplete(b *butler.Butler, e *handler.AutocompleteEvent, mod
 		return e.Result([]discord.AutocompleteChoice{
 			discord.AutocompleteChoiceString{Name: "module not found", Value: ""},
 		})
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		return e.Result([]discord.AutocompleteChoice{
+			discord.AutocompleteChoiceString{Name: "module is still loading, try again in a moment", Value: ""},
+		})
 	} else if err != nil {
 		return e.Result(nil)
 	}
@@ -117,24 +271,8 @@ func handleQueryAutocomplete(b *butler.Butler, e *handler.AutocompleteEvent, mod
 	if query == "" {
 		choices = append(choices, discord.AutocompleteChoiceString{Name: "<Pkg Info>", Value: butler.PkgInfo})
 	}
-	var symbols []string
-	for _, t := range pkg.Types {
-		symbols = append(symbols, t.Name)
-		for _, m := range t.Methods {
-			symbols = append(symbols, fmt.Sprintf("%s.%s", t.Name, m.Name))
-		}
-	}
-	for _, f := range pkg.Functions {
-		symbols = append(symbols, f.Name)
-	}
-	ranks := fuzzy.RankFindFold(query, symbols)
-	sort.Sort(ranks)
-
-	for _, rank := range ranks {
-		if len(choices) == 25 {
-			break
-		}
-		choices = append(choices, discord.AutocompleteChoiceString{Name: rank.Target, Value: rank.Target})
+	for _, symbol := range b.DocClient.Index(module, pkg).Lookup(query, 25-len(choices)) {
+		choices = append(choices, discord.AutocompleteChoiceString{Name: symbol, Value: symbol})
 	}
 	return e.Result(replaceAliases(b, choices))
 }
@@ -1,8 +1,11 @@
 package commands
 
 import (
-	"regexp"
+	"context"
+	"errors"
+	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/disgoorg/disgo-butler/butler"
 	"github.com/disgoorg/disgo-butler/common"
@@ -12,114 +15,286 @@ import (
 	"github.com/disgoorg/disgo/handler"
 	"github.com/disgoorg/json"
 	"github.com/disgoorg/snowflake/v2"
-	gopiston "github.com/milindmadhukar/go-piston"
 )
 
-var discordCodeblockRegex = regexp.MustCompile(`(?s)\x60\x60\x60(?P<language>\w+)\n(?P<code>.+)\x60\x60\x60`)
-
 var evalCommand = discord.MessageCommandCreate{
 	Name: "eval",
 }
 
+var evalSlashCommand = discord.SlashCommandCreate{
+	Name:        "eval",
+	Description: "Opens an editor to run code",
+	Options: []discord.ApplicationCommandOption{
+		discord.ApplicationCommandOptionString{
+			Name:         "language",
+			Description:  "The language of the code",
+			Required:     true,
+			Autocomplete: true,
+		},
+		discord.ApplicationCommandOptionString{
+			Name:         "version",
+			Description:  "The version of the language. Defaults to the latest version",
+			Required:     false,
+			Autocomplete: true,
+		},
+	},
+}
+
 func HandleEval(b *butler.Butler) handler.CommandHandler {
 	return func(e *handler.CommandEvent) error {
+		if e.Data.Type() == discord.ApplicationCommandTypeSlash {
+			data := e.SlashCommandInteractionData()
+			language, version, err := b.ResolveRuntime(data.String("language"), data.String("version"))
+			if err != nil {
+				return common.RespondErr(e.Respond, err)
+			}
+			return e.CreateModal(NewEvalModal("eval_modal/create", butler.EvalInput{Language: language, Version: version}))
+		}
 		message := e.MessageCommandInteractionData().TargetMessage()
-		return Eval(b, e.Client(), e.BaseInteraction, e.Respond, message.Content, message.ID, false)
+		return Eval(b, e.Client(), e.ApplicationCommandInteraction, e.Respond, message.Content, message.ID, false, EvalSelectionAuto, butler.EvalModeAuto)
 	}
 }
 
-func Eval(b *butler.Butler, client bot.Client, i discord.BaseInteraction, r events.InteractionResponderFunc, content string, messageID snowflake.ID, update bool) error {
-	runtimes, err := b.PistonClient.GetRuntimes()
-	if err != nil {
-		return common.RespondErr(r, err)
-	}
+func HandleEvalAutocomplete(b *butler.Butler) handler.AutocompleteHandler {
+	return func(e *handler.AutocompleteEvent) error {
+		if option, ok := e.Data.Option("version"); ok && option.Focused {
+			versions, err := b.RuntimeVersions(e.Data.String("language"))
+			if err != nil {
+				return e.Result(nil)
+			}
+			choices := make([]discord.AutocompleteChoice, 0, 25)
+			for i := len(versions) - 1; i >= 0 && len(choices) < 25; i-- {
+				if strings.HasPrefix(versions[i], e.Data.String("version")) {
+					choices = append(choices, discord.AutocompleteChoiceString{Name: versions[i], Value: versions[i]})
+				}
+			}
+			return e.Result(choices)
+		}
 
-	matches := discordCodeblockRegex.FindStringSubmatch(content)
-	if len(matches) == 0 {
-		return common.RespondErrMessagef(r, "no codeblock found")
+		runtimes, err := b.Executor.Runtimes(context.TODO())
+		if err != nil {
+			return e.Result(nil)
+		}
+		language := strings.ToLower(e.Data.String("language"))
+		choices := make([]discord.AutocompleteChoice, 0, 25)
+		seen := map[string]struct{}{}
+		for _, runtime := range runtimes {
+			if _, ok := seen[runtime.Language]; ok || len(choices) == 25 {
+				continue
+			}
+			if !strings.HasPrefix(runtime.Language, language) && !containsPrefix(runtime.Aliases, language) {
+				continue
+			}
+			seen[runtime.Language] = struct{}{}
+			choices = append(choices, discord.AutocompleteChoiceString{Name: runtime.Language, Value: runtime.Language})
+		}
+		return e.Result(choices)
 	}
-	rawLanguage := matches[discordCodeblockRegex.SubexpIndex("language")]
-	code := matches[discordCodeblockRegex.SubexpIndex("code")]
+}
 
-	var language string
-runtimeLoop:
-	for _, runtime := range *runtimes {
-		if strings.EqualFold(runtime.Language, rawLanguage) {
-			language = runtime.Language
-			break
+func containsPrefix(values []string, prefix string) bool {
+	for _, value := range values {
+		if strings.HasPrefix(value, prefix) {
+			return true
 		}
-		for _, alias := range runtime.Aliases {
-			if strings.EqualFold(alias, rawLanguage) {
-				language = runtime.Language
-				break runtimeLoop
-			}
+	}
+	return false
+}
+
+// The input of the eval modal is shown in an embed next to the result, so both have to fit into the 6000 characters
+// of the embeds of a message.
+const (
+	evalCodeMaxLength  = 4000
+	evalStdinMaxLength = 500
+	evalArgsMaxLength  = 500
+)
+
+// EvalLatestVersion stands in for an empty version in the custom ID of the eval modal, as empty route variables can't
+// be parsed.
+const EvalLatestVersion = "latest"
+
+// FitsEvalModal reports whether the input can be prefilled into the eval modal.
+func FitsEvalModal(input butler.EvalInput) bool {
+	var code string
+	if len(input.Files) > 0 {
+		code = input.Files[0].Content
+	}
+	return utf8.RuneCountInString(code) <= evalCodeMaxLength &&
+		utf8.RuneCountInString(input.Stdin) <= evalStdinMaxLength &&
+		utf8.RuneCountInString(strings.Join(input.Args, "\n")) <= evalArgsMaxLength
+}
+
+// NewEvalModal creates the modal to edit the input of an eval. The input is used to prefill the modal.
+func NewEvalModal(customID string, input butler.EvalInput) discord.ModalCreate {
+	var code string
+	if len(input.Files) > 0 {
+		code = input.Files[0].Content
+	}
+	version := input.Version
+	if version == "" {
+		version = EvalLatestVersion
+	}
+	return discord.NewModalCreateBuilder().
+		SetCustomID(customID + "/" + input.Language + "/" + version).
+		SetTitle(strings.TrimSpace("Eval " + input.Language + " " + input.Version)).
+		AddActionRow(discord.NewParagraphTextInput("code", "Code").
+			WithRequired(true).
+			WithMaxLength(evalCodeMaxLength).
+			WithValue(code),
+		).
+		AddActionRow(discord.NewParagraphTextInput("stdin", "Stdin").
+			WithRequired(false).
+			WithMaxLength(evalStdinMaxLength).
+			WithValue(input.Stdin),
+		).
+		AddActionRow(discord.NewParagraphTextInput("args", "Arguments").
+			WithRequired(false).
+			WithMaxLength(evalArgsMaxLength).
+			WithPlaceholder("One argument per line").
+			WithValue(strings.Join(input.Args, "\n")),
+		).
+		Build()
+}
+
+// Eval runs the selected code blocks of the message in the given mode and responds with the result. If the selection
+// is ambiguous, it asks which code block to run instead. Edits of the message rerun the eval for a while.
+func Eval(b *butler.Butler, client bot.Client, i discord.Interaction, r events.InteractionResponderFunc, content string, messageID snowflake.ID, update bool, selection string, mode butler.EvalMode) error {
+	input, err := EvalInputFromMessage(b, content, selection)
+	if errors.Is(err, ErrMultipleCodeBlocks) {
+		message := evalSelectMessage(content, messageID)
+		if update {
+			return r(discord.InteractionResponseTypeUpdateMessage, discord.MessageUpdate{
+				Content:    &message.Content,
+				Embeds:     &[]discord.Embed{},
+				Components: &message.Components,
+			})
 		}
+		return r(discord.InteractionResponseTypeCreateMessage, message)
 	}
-	if language == "" {
-		return common.RespondErrMessagef(r, "Language %s is not supported", rawLanguage)
+	if err != nil {
+		return common.RespondErr(r, err)
+	}
+	input.Mode = mode
+	return runEval(b, client, i, r, input, update, messageID, selection, func(message *discord.Message) {
+		// rerun the eval when its message is edited
+		b.EvalWatches.Watch(messageID, message.ChannelID, message.ID, content, selection, mode)
+	})
+}
+
+// RunEval runs the input and responds with the result embed. Evals without a source message also show their
+// input, so it can be edited and rerun.
+func RunEval(b *butler.Butler, client bot.Client, i discord.Interaction, r events.InteractionResponderFunc, input butler.EvalInput, update bool, messageID snowflake.ID) error {
+	return runEval(b, client, i, r, input, update, messageID, EvalSelectionAuto, nil)
+}
+
+// runEval acknowledges the interaction and runs the eval in the background, so the next interaction can be handled
+// while it waits in the queue. onResult is called with the result message if it was sent.
+func runEval(b *butler.Butler, client bot.Client, i discord.Interaction, r events.InteractionResponderFunc, input butler.EvalInput, update bool, messageID snowflake.ID, selection string, onResult func(message *discord.Message)) error {
+	ticket, err := b.EvalQueue.Enqueue(i.User().ID, i.ChannelID())
+	if err != nil {
+		return common.RespondErr(r, err)
 	}
 
 	if update {
-		if err = r(discord.InteractionResponseTypeUpdateMessage, discord.MessageUpdate{
+		err = r(discord.InteractionResponseTypeUpdateMessage, discord.MessageUpdate{
 			Content:    json.Ptr("Running..."),
 			Embeds:     &[]discord.Embed{},
 			Components: &[]discord.ContainerComponent{},
-		}); err != nil {
-			return err
-		}
+		})
 	} else {
-		if err = r(discord.InteractionResponseTypeDeferredCreateMessage, nil); err != nil {
-			return err
-		}
+		err = r(discord.InteractionResponseTypeDeferredCreateMessage, nil)
 	}
-
-	rs, err := b.PistonClient.Execute(language, "", []gopiston.Code{{Content: code}})
-	var output discord.Embed
 	if err != nil {
-		output = discord.Embed{
-			Title:       "Eval",
-			Description: "```\n" + err.Error() + "\n```",
-			Fields: []discord.EmbedField{
-				{
-					Name:  "Status",
-					Value: "Error",
-				},
-				{
-					Name:  "Duration",
-					Value: "0s",
-				},
-			},
+		ticket.Done()
+		return err
+	}
+
+	ticket.Go(func() {
+		if err := WaitForEval(client, i, ticket); err != nil {
+			return
 		}
-	} else {
-		output = discord.Embed{
-			Title:       "Eval",
-			Description: "```\n" + rs.GetOutput() + "\n```",
-			Fields: []discord.EmbedField{
-				{
-					Name:  "Status",
-					Value: "Success",
-				},
-				{
-					Name:  "Duration",
-					Value: "0s",
-				},
-			},
+		result := b.Eval(context.Background(), input)
+		message, err := client.Rest().UpdateInteractionResponse(i.ApplicationID(), i.Token(), EvalMessageUpdate(result, messageID, selection))
+		if err != nil {
+			client.Logger().Errorf("Failed to send eval result: %s", err)
+			return
 		}
-	}
+		if onResult != nil {
+			onResult(message)
+		}
+	})
+	return nil
+}
 
-	_, err = client.Rest().UpdateInteractionResponse(i.ApplicationID(), i.Token(), discord.MessageUpdate{
+// WaitForEval waits until the eval of the ticket may run and shows its position in the queue in the response of
+// the interaction meanwhile.
+func WaitForEval(client bot.Client, i discord.Interaction, ticket *butler.EvalTicket) error {
+	ctx, cancel := context.WithTimeout(context.Background(), butler.EvalQueueTimeout)
+	defer cancel()
+	err := ticket.Wait(ctx, func(position int) {
+		if _, err := client.Rest().UpdateInteractionResponse(i.ApplicationID(), i.Token(), discord.MessageUpdate{
+			Content: json.Ptr(fmt.Sprintf("Waiting in the queue at position %d...", position)),
+		}); err != nil {
+			client.Logger().Errorf("Failed to update eval queue position: %s", err)
+		}
+	})
+	if err == nil {
+		return nil
+	}
+	_, _ = client.Rest().UpdateInteractionResponse(i.ApplicationID(), i.Token(), discord.MessageUpdate{
 		Content: json.Ptr(""),
-		Embeds:  &[]discord.Embed{output},
-		Components: &[]discord.ContainerComponent{
-			discord.ActionRowComponent{
-				discord.NewPrimaryButton("", "eval/rerun/"+messageID.String()).WithEmoji(discord.ComponentEmoji{
-					Name: "🔁",
-				}),
-				discord.NewDangerButton("", "eval/delete").WithEmoji(discord.ComponentEmoji{
-					Name: "🗑️",
-				}),
-			},
-		},
+		Embeds: &[]discord.Embed{{
+			Description: "Timed out waiting in the eval queue, try again later.",
+			Color:       common.ColorError,
+		}},
 	})
 	return err
 }
+
+// EvalMessageUpdate creates the message with the result of an eval. It replaces the attachments of previous runs.
+// The selection of code blocks and the mode are kept in the buttons, so reruns run the same blocks the same way. Go
+// code with tests gets a button to switch between running it as tests and as a program. Evals without a source
+// message show their input next to the result, which gets the rest of the length of the embeds.
+func EvalMessageUpdate(result butler.EvalResult, messageID snowflake.ID, selection string) discord.MessageUpdate {
+	var embeds []discord.Embed
+	maxLength := common.MaxEmbedsLength
+	if messageID == 0 {
+		source := butler.GetEvalSourceEmbed(result.Input)
+		embeds = append(embeds, source)
+		maxLength -= common.EmbedLength(source)
+	}
+	embed, files := butler.GetEvalEmbed(result, maxLength)
+	embeds = append(embeds, embed)
+
+	rerunID := "eval/rerun/" + messageID.String() + "/" + selection + "/"
+	buttons := discord.ActionRowComponent{
+		discord.NewPrimaryButton("", rerunID+string(butler.ParseEvalMode(string(result.Input.Mode)))).WithEmoji(discord.ComponentEmoji{
+			Name: "🔁",
+		}),
+		discord.NewSecondaryButton("edit & rerun", "eval/edit/"+messageID.String()+"/"+selection).WithEmoji(discord.ComponentEmoji{
+			Name: "📝",
+		}),
+	}
+	if result.HasTests {
+		if result.Tests != nil {
+			buttons = append(buttons, discord.NewSecondaryButton("run as program", rerunID+string(butler.EvalModeProgram)).WithEmoji(discord.ComponentEmoji{
+				Name: "▶️",
+			}))
+		} else {
+			buttons = append(buttons, discord.NewSecondaryButton("run tests", rerunID+string(butler.EvalModeTest)).WithEmoji(discord.ComponentEmoji{
+				Name: "🧪",
+			}))
+		}
+	}
+	buttons = append(buttons, discord.NewDangerButton("", "eval/delete").WithEmoji(discord.ComponentEmoji{
+		Name: "🗑️",
+	}))
+
+	return discord.MessageUpdate{
+		Content:     json.Ptr(""),
+		Embeds:      &embeds,
+		Files:       files,
+		Attachments: &[]discord.AttachmentUpdate{},
+		Components:  &[]discord.ContainerComponent{buttons},
+	}
+}
@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/snowflake/v2"
+
+	"github.com/disgoorg/disgo-butler/butler"
+)
+
+const (
+	// EvalSelectionAuto runs the only code block of a message and asks which block to run if there are several.
+	EvalSelectionAuto = "auto"
+	// EvalSelectionAll runs all code blocks of a message as one multi file program.
+	EvalSelectionAll = "all"
+
+	// defaultEvalLanguage is used for code blocks without a language if no other block of the message has one.
+	defaultEvalLanguage = "go"
+)
+
+var ErrMultipleCodeBlocks = errors.New("the message contains multiple code blocks")
+
+// codeBlockInfoRegex matches the info string of a fence like go or go main.go. Anything else on the first line of
+// a code block is code.
+var codeBlockInfoRegex = regexp.MustCompile(`^(?P<language>[\w+#.-]+)(?:[ \t]+(?P<filename>\S+))?[ \t]*$`)
+
+// CodeBlock is a fenced code block of a message.
+type CodeBlock struct {
+	Language string
+	Filename string
+	Code     string
+}
+
+// ParseCodeBlocks returns all fenced code blocks of the message. Every fence is closed by the next fence. The
+// spelling golang is normalized to go.
+func ParseCodeBlocks(content string) []CodeBlock {
+	var blocks []CodeBlock
+	for {
+		start := strings.Index(content, "```")
+		if start < 0 {
+			return blocks
+		}
+		content = content[start+3:]
+		end := strings.Index(content, "```")
+		if end < 0 {
+			return blocks
+		}
+		body := content[:end]
+		content = content[end+3:]
+
+		var block CodeBlock
+		if info, code, ok := strings.Cut(body, "\n"); ok && codeBlockInfoRegex.MatchString(info) {
+			matches := codeBlockInfoRegex.FindStringSubmatch(info)
+			block.Language = strings.ToLower(matches[codeBlockInfoRegex.SubexpIndex("language")])
+			block.Filename = matches[codeBlockInfoRegex.SubexpIndex("filename")]
+			block.Code = code
+		} else {
+			block.Code = strings.TrimPrefix(body, "\n")
+		}
+		if block.Language == "golang" {
+			block.Language = "go"
+		}
+		blocks = append(blocks, block)
+	}
+}
+
+// evalCandidates returns the code blocks which can be run. Blocks without a language get the language of the first
+// block which has one.
+func evalCandidates(blocks []CodeBlock) []CodeBlock {
+	var (
+		candidates []CodeBlock
+		language   string
+	)
+	for _, block := range blocks {
+		if block.Language == "stdin" || block.Language == "args" {
+			continue
+		}
+		if language == "" {
+			language = block.Language
+		}
+		candidates = append(candidates, block)
+	}
+	if language == "" {
+		language = defaultEvalLanguage
+	}
+	for i := range candidates {
+		if candidates[i].Language == "" {
+			candidates[i].Language = language
+		}
+	}
+	return candidates
+}
+
+// EvalInputFromMessage extracts the code blocks of a message. The selection is either EvalSelectionAuto,
+// EvalSelectionAll or the index of the code block to run. Code blocks can be named after the language like
+// ```go main.go. Code blocks with the language stdin or args are used as stdin and arguments.
+func EvalInputFromMessage(b *butler.Butler, content string, selection string) (butler.EvalInput, error) {
+	var input butler.EvalInput
+	blocks := ParseCodeBlocks(content)
+	for _, block := range blocks {
+		switch block.Language {
+		case "stdin":
+			input.Stdin = block.Code
+		case "args":
+			input.Args = strings.Split(strings.TrimSpace(block.Code), "\n")
+		}
+	}
+
+	candidates := evalCandidates(blocks)
+	if len(candidates) == 0 {
+		return butler.EvalInput{}, errors.New("no codeblock found")
+	}
+	switch selection {
+	case EvalSelectionAuto:
+		if len(candidates) > 1 {
+			return butler.EvalInput{}, ErrMultipleCodeBlocks
+		}
+	case EvalSelectionAll:
+	default:
+		index, err := strconv.Atoi(selection)
+		if err != nil || index < 0 || index >= len(candidates) {
+			return butler.EvalInput{}, fmt.Errorf("code block %s does not exist", selection)
+		}
+		candidates = candidates[index : index+1]
+	}
+
+	for _, block := range candidates {
+		input.Files = append(input.Files, butler.EvalFile{
+			Name:    block.Filename,
+			Content: block.Code,
+		})
+	}
+
+	var err error
+	if input.Language, input.Version, err = b.ResolveRuntime(candidates[0].Language, ""); err != nil {
+		return butler.EvalInput{}, err
+	}
+	return input, nil
+}
+
+// evalSelectMessage asks which code block of the message should be run.
+func evalSelectMessage(content string, messageID snowflake.ID) discord.MessageCreate {
+	candidates := evalCandidates(ParseCodeBlocks(content))
+	options := make([]discord.StringSelectMenuOption, 0, 25)
+	for i, block := range candidates {
+		if len(options) == 24 {
+			break
+		}
+		label := fmt.Sprintf("Block %d: %s", i+1, block.Language)
+		if block.Filename != "" {
+			label += " " + block.Filename
+		}
+		option := discord.NewStringSelectMenuOption(truncate(label, 100), strconv.Itoa(i))
+		if line := firstLine(block.Code); line != "" {
+			option = option.WithDescription(truncate(line, 100))
+		}
+		options = append(options, option)
+	}
+	options = append(options, discord.NewStringSelectMenuOption("Run all blocks as one program", EvalSelectionAll).
+		WithEmoji(discord.ComponentEmoji{Name: "📦"}),
+	)
+
+	return discord.MessageCreate{
+		Content: "This message contains multiple code blocks. Which one do you want to run?",
+		Components: []discord.ContainerComponent{
+			discord.NewActionRow(
+				discord.NewStringSelectMenu("eval/select/"+messageID.String(), "Select a code block", options...),
+			),
+		},
+	}
+}
+
+func firstLine(code string) string {
+	for _, line := range strings.Split(code, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+func truncate(s string, maxLen int) string {
+	if len([]rune(s)) <= maxLen {
+		return s
+	}
+	return string([]rune(s)[:maxLen-1]) + "…"
+}
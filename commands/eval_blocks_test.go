@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCodeBlocks(t *testing.T) {
+	content := "here is my code\n```golang main.go\npackage main\n```\nand the output\n```\npanic: oops\n```\nand inline ```fmt.Println(1)```"
+	expected := []CodeBlock{
+		{Language: "go", Filename: "main.go", Code: "package main\n"},
+		{Code: "panic: oops\n"},
+		{Code: "fmt.Println(1)"},
+	}
+	if blocks := ParseCodeBlocks(content); !reflect.DeepEqual(blocks, expected) {
+		t.Errorf("expected %+v, got %+v", expected, blocks)
+	}
+
+	candidates := evalCandidates(ParseCodeBlocks("```\nx := 1\n```\n```py\nprint(1)\n```\n```stdin\nfoo\n```"))
+	if len(candidates) != 2 || candidates[0].Language != "py" || candidates[1].Language != "py" {
+		t.Errorf("expected unlabeled block to get the language of the others, got %+v", candidates)
+	}
+	if candidates = evalCandidates(ParseCodeBlocks("```\nfmt.Println(1)\n```")); candidates[0].Language != defaultEvalLanguage {
+		t.Errorf("expected default language, got %s", candidates[0].Language)
+	}
+}
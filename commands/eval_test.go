@@ -0,0 +1,401 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/disgoorg/disgo"
+	"github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/rest"
+
+	"github.com/disgoorg/disgo-butler/butler"
+	"github.com/disgoorg/disgo-butler/common"
+	"github.com/disgoorg/disgo-butler/internal/evaltest"
+)
+
+// evalTest runs the eval command against a fake executor and a fake discord api.
+type evalTest struct {
+	butler     *butler.Butler
+	executor   *evaltest.Executor
+	responses  []discord.InteractionResponseType
+	errors     []string
+	updates    []evalUpdate
+	files      []string
+	components []discord.ContainerComponent
+}
+
+// evalUpdate is the part of the message update which is checked by the tests.
+type evalUpdate struct {
+	Embeds     []discord.Embed `json:"embeds"`
+	Components json.RawMessage `json:"components"`
+}
+
+func newEvalTest(execute func(input butler.EvalInput) (*butler.ExecResult, error)) *evalTest {
+	return &evalTest{
+		executor: evaltest.NewExecutor([]butler.Runtime{
+			{Language: "go", Version: "1.16.2", Aliases: []string{"golang"}},
+			{Language: "go", Version: "1.20.0", Aliases: []string{"golang"}},
+			{Language: "python", Version: "3.10.0", Aliases: []string{"py"}},
+		}, execute),
+	}
+}
+
+func (et *evalTest) run(t *testing.T, content string) {
+	et.runSelection(t, content, EvalSelectionAuto)
+}
+
+func (et *evalTest) runSelection(t *testing.T, content string, selection string) {
+	et.runWith(t, func(client bot.Client, i discord.Interaction, r events.InteractionResponderFunc) error {
+		return Eval(et.butler, client, i, r, content, 6, false, selection, butler.EvalModeAuto)
+	})
+}
+
+// runWith calls run with a client which talks to the fake discord api and waits until the eval is done.
+func (et *evalTest) runWith(t *testing.T, run func(client bot.Client, i discord.Interaction, r events.InteractionResponderFunc) error) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || !strings.HasSuffix(r.URL.Path, "/webhooks/123456789012345678/token/messages/@original") {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var update evalUpdate
+		if err := json.Unmarshal(et.readBody(t, r), &update); err != nil {
+			t.Error(err)
+		}
+		et.updates = append(et.updates, update)
+		_, _ = w.Write([]byte(`{"id":"1","channel_id":"2","type":0,"content":""}`))
+	}))
+	defer server.Close()
+
+	client, err := disgo.New("MTIzNDU2Nzg5MDEyMzQ1Njc4.token.secret",
+		bot.WithRestClientConfigOpts(rest.WithURL(server.URL)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var interaction discord.ApplicationCommandInteraction
+	if err = json.Unmarshal([]byte(`{"id":"3","application_id":"123456789012345678","type":2,"token":"token","version":1,"channel_id":"2","user":{"id":"4","username":"test","discriminator":"0000"},"data":{"id":"5","name":"eval","type":3,"target_id":"6"}}`), &interaction); err != nil {
+		t.Fatal(err)
+	}
+
+	et.butler = &butler.Butler{
+		Executor:    et.executor,
+		EvalQueue:   butler.NewEvalQueue(butler.EvalQueueConfig{}),
+		EvalWatches: butler.NewEvalWatches(0),
+	}
+	if err = run(client, interaction, func(responseType discord.InteractionResponseType, data discord.InteractionResponseData, _ ...rest.RequestOpt) error {
+		et.responses = append(et.responses, responseType)
+		if message, ok := data.(discord.MessageCreate); ok {
+			if len(message.Embeds) > 0 {
+				et.errors = append(et.errors, message.Embeds[0].Description)
+			}
+			et.components = append(et.components, message.Components...)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	et.butler.EvalQueue.WaitIdle()
+}
+
+func (et *evalTest) readBody(t *testing.T, r *http.Request) []byte {
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		return body
+	}
+
+	var payload []byte
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return payload
+		}
+		if err != nil {
+			t.Error(err)
+			return payload
+		}
+		data, _ := io.ReadAll(part)
+		if part.FormName() == "payload_json" {
+			payload = data
+		} else {
+			et.files = append(et.files, part.FileName())
+		}
+	}
+}
+
+func (et *evalTest) resultEmbed(t *testing.T) discord.Embed {
+	if len(et.updates) != 1 || len(et.updates[0].Embeds) == 0 {
+		t.Fatalf("expected one update with the result embed, got %+v", et.updates)
+	}
+	embeds := et.updates[0].Embeds
+	return embeds[len(embeds)-1]
+}
+
+func evalField(embed discord.Embed, name string) string {
+	for _, field := range embed.Fields {
+		if field.Name == name {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+func TestEvalSuccess(t *testing.T) {
+	zero := 0
+	et := newEvalTest(func(input butler.EvalInput) (*butler.ExecResult, error) {
+		return &butler.ExecResult{
+			Language: input.Language,
+			Version:  input.Version,
+			Run:      butler.ExecStage{Stdout: "hello " + input.Stdin + " " + strings.Join(input.Args, ","), Code: &zero},
+		}, nil
+	})
+	et.run(t, "look at this\n```golang main.go\npackage main\n```\n```stdin\nworld\n```\n```args\n-a\n-b\n```")
+
+	inputs := et.executor.Inputs()
+	if len(inputs) != 1 {
+		t.Fatalf("expected one execution, got %d", len(inputs))
+	}
+	input := inputs[0]
+	if input.Language != "go" || input.Version != "1.20.0" {
+		t.Errorf("expected latest go runtime, got %s %s", input.Language, input.Version)
+	}
+	if len(input.Files) != 1 || input.Files[0].Name != "main.go" || input.Files[0].Content != "package main\n" {
+		t.Errorf("unexpected files %+v", input.Files)
+	}
+	if len(et.responses) != 1 || et.responses[0] != discord.InteractionResponseTypeDeferredCreateMessage {
+		t.Errorf("expected deferred response, got %v", et.responses)
+	}
+
+	embed := et.resultEmbed(t)
+	if status := evalField(embed, "Status"); status != "Success" {
+		t.Errorf("expected success, got %s", status)
+	}
+	if !strings.Contains(embed.Description, "hello world\n -a,-b") {
+		t.Errorf("unexpected output %s", embed.Description)
+	}
+	if len(et.updates[0].Embeds) != 1 {
+		t.Error("expected no source embed for evals of messages")
+	}
+	if watch, ok := et.butler.EvalWatches.Get(6); !ok || watch.ResultID != 1 || watch.ChannelID != 2 {
+		t.Errorf("expected the message to be watched for edits, got %+v", watch)
+	}
+}
+
+func TestEvalGoSnippet(t *testing.T) {
+	zero := 0
+	et := newEvalTest(func(input butler.EvalInput) (*butler.ExecResult, error) {
+		return &butler.ExecResult{Run: butler.ExecStage{Stdout: "3\n", Code: &zero}}, nil
+	})
+	et.run(t, "```go\nfmt.Println(len(strings.Fields(\"a b c\")))\n```")
+
+	expected := "package main\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n)\n\nfunc main() {\n\tfmt.Println(len(strings.Fields(\"a b c\")))\n}\n"
+	if inputs := et.executor.Inputs(); len(inputs) != 1 || inputs[0].Files[0].Content != expected {
+		t.Errorf("expected wrapped snippet, got %+v", inputs)
+	}
+	et.resultEmbed(t)
+	if len(et.files) != 1 || et.files[0] != "main.go" {
+		t.Errorf("expected wrapped source as file, got %v", et.files)
+	}
+}
+
+func TestEvalGoTests(t *testing.T) {
+	one := 1
+	et := newEvalTest(func(input butler.EvalInput) (*butler.ExecResult, error) {
+		return &butler.ExecResult{Run: butler.ExecStage{
+			Stdout: "=== RUN   TestSum\n    main.go:8: got 3\n--- FAIL: TestSum (0.00s)\nBenchmarkSum-8 \t 1000\t 12.5 ns/op\t 0 B/op\t 0 allocs/op\nFAIL\n",
+			Code:   &one,
+		}}, nil
+	})
+	et.run(t, "```go\nfunc TestSum(t *testing.T) {\n\tt.Log(\"got 3\")\n}\n\nfunc BenchmarkSum(b *testing.B) {}\n```")
+
+	if inputs := et.executor.Inputs(); len(inputs) != 1 || !strings.Contains(inputs[0].Files[0].Content, "testing.Main(") {
+		t.Errorf("expected tests to be run, got %+v", inputs)
+	}
+	embed := et.resultEmbed(t)
+	if tests := evalField(embed, "Tests"); tests != "0 passed, 1 failed" {
+		t.Errorf("expected test summary, got %q", tests)
+	}
+	for _, expected := range []string{"FAIL  TestSum  0.00s", "BenchmarkSum  1000  12.5", "main.go:8: got 3"} {
+		if !strings.Contains(embed.Description, expected) {
+			t.Errorf("expected %q in description %q", expected, embed.Description)
+		}
+	}
+	if !strings.Contains(string(et.updates[0].Components), "eval/rerun/6/auto/program") {
+		t.Errorf("expected button to run as program, got %s", et.updates[0].Components)
+	}
+}
+
+func TestEvalMultipleBlocks(t *testing.T) {
+	zero := 0
+	content := "```go main.go\npackage main\n```\n```go util.go\npackage main\n```"
+	execute := func(input butler.EvalInput) (*butler.ExecResult, error) {
+		return &butler.ExecResult{Run: butler.ExecStage{Code: &zero}}, nil
+	}
+
+	et := newEvalTest(execute)
+	et.run(t, content)
+	if len(et.executor.Inputs()) != 0 || len(et.components) != 1 {
+		t.Fatalf("expected a select menu instead of running, got %+v", et.components)
+	}
+	menu := et.components[0].(discord.ActionRowComponent)[0].(discord.StringSelectMenuComponent)
+	if menu.CustomID != "eval/select/6" || len(menu.Options) != 3 || menu.Options[2].Value != EvalSelectionAll {
+		t.Errorf("unexpected select menu %+v", menu)
+	}
+
+	et = newEvalTest(execute)
+	et.runSelection(t, content, "1")
+	if inputs := et.executor.Inputs(); len(inputs) != 1 || len(inputs[0].Files) != 1 || inputs[0].Files[0].Name != "util.go" {
+		t.Errorf("expected only the selected block to run, got %+v", inputs)
+	}
+
+	et = newEvalTest(execute)
+	et.runSelection(t, content, EvalSelectionAll)
+	if inputs := et.executor.Inputs(); len(inputs) != 1 || len(inputs[0].Files) != 2 {
+		t.Errorf("expected all blocks to run as one program, got %+v", inputs)
+	}
+	if watch, _ := et.butler.EvalWatches.Get(6); watch.Selection != EvalSelectionAll {
+		t.Errorf("expected the selection to be watched, got %q", watch.Selection)
+	}
+}
+
+func TestEvalCompileError(t *testing.T) {
+	two := 2
+	et := newEvalTest(func(input butler.EvalInput) (*butler.ExecResult, error) {
+		return &butler.ExecResult{
+			Compile: &butler.ExecStage{Stderr: "./main.go:1:1: expected 'package'", Code: &two},
+		}, nil
+	})
+	et.run(t, "```py\nprint(\n```")
+
+	embed := et.resultEmbed(t)
+	if status := evalField(embed, "Status"); status != "Compile error" {
+		t.Errorf("expected compile error, got %s", status)
+	}
+	if code := evalField(embed, "Exit code"); code != "2" {
+		t.Errorf("expected exit code 2, got %s", code)
+	}
+	if !strings.Contains(embed.Description, "**Compile stderr**") {
+		t.Errorf("expected compile stderr section, got %s", embed.Description)
+	}
+}
+
+func TestEvalExecutorError(t *testing.T) {
+	et := newEvalTest(func(input butler.EvalInput) (*butler.ExecResult, error) {
+		return nil, errors.New("backend unavailable")
+	})
+	et.run(t, "```go\npackage main\n```")
+
+	embed := et.resultEmbed(t)
+	if status := evalField(embed, "Status"); status != "Error" {
+		t.Errorf("expected error, got %s", status)
+	}
+	if !strings.Contains(embed.Description, "backend unavailable") {
+		t.Errorf("expected error message, got %s", embed.Description)
+	}
+}
+
+func TestEvalLongOutput(t *testing.T) {
+	zero := 0
+	et := newEvalTest(func(input butler.EvalInput) (*butler.ExecResult, error) {
+		return &butler.ExecResult{Run: butler.ExecStage{Stdout: strings.Repeat("spam\n", 5000), Code: &zero}}, nil
+	})
+	et.run(t, "```go\npackage main\n```")
+
+	et.resultEmbed(t)
+	if len(et.files) != 1 || et.files[0] != "output.txt" {
+		t.Errorf("expected full output as file, got %v", et.files)
+	}
+}
+
+func TestEvalLongSource(t *testing.T) {
+	zero := 0
+	et := newEvalTest(func(input butler.EvalInput) (*butler.ExecResult, error) {
+		return &butler.ExecResult{Run: butler.ExecStage{Stdout: strings.Repeat("spam\n", 5000), Code: &zero}}, nil
+	})
+	input := butler.EvalInput{
+		Language: "python",
+		Version:  "3.10.0",
+		Files:    []butler.EvalFile{{Content: strings.Repeat("print('spam')\n", 4000/14) + strings.Repeat("#", 4000%14)}},
+		Stdin:    strings.Repeat("s", evalStdinMaxLength),
+		Args:     []string{strings.Repeat("a", evalArgsMaxLength)},
+	}
+	if !FitsEvalModal(input) {
+		t.Fatal("expected input to fit into the eval modal")
+	}
+	et.runWith(t, func(client bot.Client, i discord.Interaction, r events.InteractionResponderFunc) error {
+		return RunEval(et.butler, client, i, r, input, false, 0)
+	})
+
+	et.resultEmbed(t)
+	embeds := et.updates[0].Embeds
+	if len(embeds) != 2 {
+		t.Fatalf("expected source and result embed, got %d embeds", len(embeds))
+	}
+	length := 0
+	for _, embed := range embeds {
+		length += common.EmbedLength(embed)
+	}
+	if length > common.MaxEmbedsLength {
+		t.Errorf("expected embeds to fit into %d characters, got %d", common.MaxEmbedsLength, length)
+	}
+	if !strings.Contains(embeds[1].Description, "spam") {
+		t.Errorf("expected part of the output in the result embed, got %q", embeds[1].Description)
+	}
+	if len(et.files) != 1 || et.files[0] != "output.txt" {
+		t.Errorf("expected full output as file, got %v", et.files)
+	}
+}
+
+func TestNewEvalModal(t *testing.T) {
+	tests := []struct {
+		input    butler.EvalInput
+		customID string
+	}{
+		{butler.EvalInput{Language: "go", Version: "1.20.0"}, "eval_modal/create/go/1.20.0"},
+		{butler.EvalInput{Language: "go"}, "eval_modal/create/go/" + EvalLatestVersion},
+	}
+	for _, tt := range tests {
+		if modal := NewEvalModal("eval_modal/create", tt.input); modal.CustomID != tt.customID {
+			t.Errorf("expected custom ID %q, got %q", tt.customID, modal.CustomID)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"no codeblock", "just text", "no codeblock found"},
+		{"unknown language", "```brainfuck\n+++\n```", "language brainfuck is not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			et := newEvalTest(func(input butler.EvalInput) (*butler.ExecResult, error) {
+				t.Error("unexpected execution")
+				return nil, nil
+			})
+			et.run(t, tt.content)
+			if len(et.errors) != 1 || !strings.Contains(et.errors[0], tt.err) {
+				t.Errorf("expected error %q, got %v", tt.err, et.errors)
+			}
+			if len(et.updates) != 0 {
+				t.Error("expected no result")
+			}
+		})
+	}
+}
@@ -2,11 +2,13 @@ package commands
 
 import (
 	"database/sql"
+	"fmt"
 
 	"github.com/disgoorg/disgo-butler/butler"
 	"github.com/disgoorg/disgo-butler/common"
 	"github.com/disgoorg/disgo/discord"
 	"github.com/disgoorg/disgo/handler"
+	"github.com/disgoorg/json"
 )
 
 var tagCommand = discord.SlashCommandCreate{
@@ -20,19 +22,57 @@ var tagCommand = discord.SlashCommandCreate{
 			Required:     true,
 			Autocomplete: true,
 		},
+		discord.ApplicationCommandOptionString{
+			Name:        "args",
+			Description: "The arguments of the tag, available as {{args}} and {{args.1}}, {{args.2}}, ...",
+			Required:    false,
+		},
 	},
 }
 
 func HandleTag(b *butler.Butler) handler.CommandHandler {
 	return func(e *handler.CommandEvent) error {
-		tag, err := b.DB.GetAndIncrement(*e.GuildID(), e.SlashCommandInteractionData().String("name"))
+		data := e.SlashCommandInteractionData()
+		tag, err := b.DB.GetAndIncrement(*e.GuildID(), data.String("name"), e.User().ID, e.ChannelID())
 		if err == sql.ErrNoRows {
 			return common.RespondErrMessage(e.Respond, "Tag not found")
 		} else if err != nil {
 			return common.RespondErr(e.Respond, err)
 		}
-		return e.CreateMessage(discord.MessageCreate{
-			Content: tag.Content,
-		})
+		attachments, err := b.DB.GetAttachments(*e.GuildID(), tag.Name)
+		if err != nil {
+			return common.RespondErr(e.Respond, err)
+		}
+		body, _ := butler.RenderTag(tag.Body(), tagContext(b, e, data.String("args")))
+		if butler.TagBodyEmpty(body) && len(attachments) == 0 {
+			return common.RespondErrMessage(e.Respond, "This tag needs arguments, add them with the `args` option.")
+		}
+		message := butler.TagMessage(body, attachments)
+		if len(message.Files) == 0 {
+			return e.CreateMessage(message)
+		}
+
+		// uploading the files can take longer than discord waits for the response
+		if err = e.DeferCreateMessage(false); err != nil {
+			return err
+		}
+		if _, err = e.CreateFollowupMessage(message); err != nil {
+			_, _ = e.UpdateInteractionResponse(discord.MessageUpdate{Content: json.Ptr(fmt.Sprintf("Failed to send tag: %s", err))})
+		}
+		return err
+	}
+}
+
+// tagContext returns what the variables of tags used in the interaction are replaced with.
+func tagContext(b *butler.Butler, e *handler.CommandEvent, args string) butler.TagContext {
+	ctx := butler.TagContext{
+		User:      e.User(),
+		ChannelID: e.ChannelID(),
+		Args:      args,
+		Version:   b.Version,
+	}
+	if guild, ok := e.Client().Caches().Guild(*e.GuildID()); ok {
+		ctx.GuildName = guild.Name
 	}
+	return ctx
 }
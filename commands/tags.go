@@ -1,17 +1,26 @@
 package commands
 
 import (
+	"bytes"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/disgoorg/disgo-butler/butler"
 	"github.com/disgoorg/disgo-butler/common"
 	"github.com/disgoorg/disgo-butler/db"
 	"github.com/disgoorg/disgo/discord"
 	"github.com/disgoorg/disgo/handler"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/disgoorg/json"
 	"github.com/disgoorg/paginator"
+	"github.com/disgoorg/snowflake/v2"
 	"github.com/lithammer/fuzzysearch/fuzzy"
 )
 
@@ -27,11 +36,12 @@ var tagsCommand = discord.SlashCommandCreate{
 					Name:        "name",
 					Description: "the name of the tag to create",
 					Required:    true,
+					MaxLength:   json.Ptr(butler.MaxTagNameLength),
 				},
 				discord.ApplicationCommandOptionString{
 					Name:        "content",
-					Description: "the content of the new tag",
-					Required:    true,
+					Description: "the content of the new tag, leave empty to open an editor with embed and buttons",
+					Required:    false,
 				},
 			},
 		},
@@ -59,8 +69,8 @@ var tagsCommand = discord.SlashCommandCreate{
 				},
 				discord.ApplicationCommandOptionString{
 					Name:        "content",
-					Description: "the new content of the new tag",
-					Required:    true,
+					Description: "the new content of the tag, leave empty to open an editor with embed and buttons",
+					Required:    false,
 				},
 			},
 		},
@@ -74,19 +84,235 @@ var tagsCommand = discord.SlashCommandCreate{
 					Required:     true,
 					Autocomplete: true,
 				},
+				discord.ApplicationCommandOptionString{
+					Name:        "args",
+					Description: "the arguments to preview the tag with",
+					Required:    false,
+				},
 			},
 		},
 		discord.ApplicationCommandOptionSubCommand{
 			Name:        "list",
 			Description: "lists all tags",
+			Options: []discord.ApplicationCommandOption{
+				discord.ApplicationCommandOptionUser{
+					Name:        "owner",
+					Description: "only list the tags of this user",
+					Required:    false,
+				},
+			},
+		},
+		discord.ApplicationCommandOptionSubCommand{
+			Name:        "transfer",
+			Description: "let's you give a tag to another user",
+			Options: []discord.ApplicationCommandOption{
+				discord.ApplicationCommandOptionString{
+					Name:         "name",
+					Description:  "the name of the tag to transfer",
+					Required:     true,
+					Autocomplete: true,
+				},
+				discord.ApplicationCommandOptionUser{
+					Name:        "user",
+					Description: "the new owner of the tag",
+					Required:    true,
+				},
+			},
+		},
+		discord.ApplicationCommandOptionSubCommand{
+			Name:        "claim",
+			Description: "let's you take over a tag whose owner left the server",
+			Options: []discord.ApplicationCommandOption{
+				discord.ApplicationCommandOptionString{
+					Name:         "name",
+					Description:  "the name of the tag to claim",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+		},
+		discord.ApplicationCommandOptionSubCommand{
+			Name:        "history",
+			Description: "lists all revisions of a tag",
+			Options: []discord.ApplicationCommandOption{
+				discord.ApplicationCommandOptionString{
+					Name:         "name",
+					Description:  "the name of the tag",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+		},
+		discord.ApplicationCommandOptionSubCommand{
+			Name:        "rollback",
+			Description: "let's you restore a previous revision of a tag",
+			Options: []discord.ApplicationCommandOption{
+				discord.ApplicationCommandOptionString{
+					Name:         "name",
+					Description:  "the name of the tag to roll back",
+					Required:     true,
+					Autocomplete: true,
+				},
+				discord.ApplicationCommandOptionInt{
+					Name:        "revision",
+					Description: "the revision to restore",
+					Required:    true,
+					MinValue:    json.Ptr(1),
+				},
+			},
+		},
+		discord.ApplicationCommandOptionSubCommand{
+			Name:        "stats",
+			Description: "shows which tags are used the most and which are not used",
+			Options: []discord.ApplicationCommandOption{
+				discord.ApplicationCommandOptionInt{
+					Name:        "days",
+					Description: "the number of days to show the stats of",
+					Required:    false,
+					MinValue:    json.Ptr(1),
+					MaxValue:    json.Ptr(365),
+				},
+			},
+		},
+		discord.ApplicationCommandOptionSubCommand{
+			Name:        "export",
+			Description: "exports all tags of the server as a file",
+			Options: []discord.ApplicationCommandOption{
+				discord.ApplicationCommandOptionString{
+					Name:        "format",
+					Description: "the format of the file",
+					Required:    false,
+					Choices: []discord.ApplicationCommandOptionChoiceString{
+						{Name: "JSON", Value: string(butler.TagsFormatJSON)},
+						{Name: "YAML", Value: string(butler.TagsFormatYAML)},
+					},
+				},
+			},
+		},
+		discord.ApplicationCommandOptionSubCommand{
+			Name:        "import",
+			Description: "imports tags from a file created by /tags export",
+			Options: []discord.ApplicationCommandOption{
+				discord.ApplicationCommandOptionAttachment{
+					Name:        "file",
+					Description: "the JSON or YAML file to import",
+					Required:    true,
+				},
+				discord.ApplicationCommandOptionString{
+					Name:        "conflict",
+					Description: "what to do with tags which already exist",
+					Required:    false,
+					Choices: []discord.ApplicationCommandOptionChoiceString{
+						{Name: "skip", Value: string(butler.TagConflictSkip)},
+						{Name: "overwrite", Value: string(butler.TagConflictOverwrite)},
+						{Name: "rename", Value: string(butler.TagConflictRename)},
+					},
+				},
+			},
+		},
+		discord.ApplicationCommandOptionSubCommandGroup{
+			Name:        "attachment",
+			Description: "let's you manage the files of a tag",
+			Options: []discord.ApplicationCommandOptionSubCommand{
+				{
+					Name:        "add",
+					Description: "let's you add a file to a tag",
+					Options: []discord.ApplicationCommandOption{
+						discord.ApplicationCommandOptionString{
+							Name:         "name",
+							Description:  "the name of the tag",
+							Required:     true,
+							Autocomplete: true,
+						},
+						discord.ApplicationCommandOptionAttachment{
+							Name:        "file",
+							Description: "the file to add, replaces a file with the same name",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Name:        "remove",
+					Description: "let's you remove a file from a tag",
+					Options: []discord.ApplicationCommandOption{
+						discord.ApplicationCommandOptionString{
+							Name:         "name",
+							Description:  "the name of the tag",
+							Required:     true,
+							Autocomplete: true,
+						},
+						discord.ApplicationCommandOptionString{
+							Name:        "filename",
+							Description: "the name of the file to remove",
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+		discord.ApplicationCommandOptionSubCommandGroup{
+			Name:        "alias",
+			Description: "let's you manage the aliases of a tag",
+			Options: []discord.ApplicationCommandOptionSubCommand{
+				{
+					Name:        "add",
+					Description: "let's you add an alias to a tag",
+					Options: []discord.ApplicationCommandOption{
+						discord.ApplicationCommandOptionString{
+							Name:         "name",
+							Description:  "the name of the tag",
+							Required:     true,
+							Autocomplete: true,
+						},
+						discord.ApplicationCommandOptionString{
+							Name:        "alias",
+							Description: "the alias to add",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Name:        "remove",
+					Description: "let's you remove an alias of a tag",
+					Options: []discord.ApplicationCommandOption{
+						discord.ApplicationCommandOptionString{
+							Name:         "alias",
+							Description:  "the alias to remove",
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Name:        "list",
+					Description: "lists all aliases of a tag",
+					Options: []discord.ApplicationCommandOption{
+						discord.ApplicationCommandOptionString{
+							Name:         "name",
+							Description:  "the name of the tag",
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+			},
 		},
 	},
 }
 
+// fitsTagModal reports whether the name can be put into the custom ID of the tag modal. Tags which were created
+// before names were limited can be too long or contain a "/", which would route the modal to another tag.
+func fitsTagModal(name string) bool {
+	return !strings.Contains(name, "/") && utf8.RuneCountInString(name) <= butler.MaxTagNameLength
+}
+
 func HandleCreateTag(b *butler.Butler) handler.CommandHandler {
 	return func(e *handler.CommandEvent) error {
 		data := e.SlashCommandInteractionData()
 		name := formatTagName(data.String("name"))
+		if strings.Contains(name, "/") {
+			return common.RespondErrMessage(e.Respond, "Tag names can't contain `/`.")
+		}
 
 		if _, err := b.DB.Get(*e.GuildID(), name); err == nil {
 			return common.RespondErrMessage(e.Respond, "Tag already exists.")
@@ -94,7 +320,11 @@ func HandleCreateTag(b *butler.Butler) handler.CommandHandler {
 			return common.RespondMessageErr(e.Respond, "Failed to edit tag: %s", err)
 		}
 
-		if err := b.DB.Create(*e.GuildID(), e.User().ID, name, data.String("content")); err != nil {
+		content, ok := data.OptString("content")
+		if !ok {
+			return e.CreateModal(NewTagModal("tags/create/"+name, "Create tag "+name, db.TagBody{}))
+		}
+		if err := b.DB.Create(*e.GuildID(), e.User().ID, name, db.TagBody{Content: content}); err != nil {
 			return common.RespondMessageErr(e.Respond, "Failed to create tag: %s", err)
 		}
 		return common.Respond(e.Respond, "Tag created!")
@@ -116,13 +346,62 @@ func HandleEditTag(b *butler.Butler) handler.CommandHandler {
 			return common.RespondErrMessage(e.Respond, "You do not have permission to edit this tag.")
 		}
 
-		if err = b.DB.Edit(*e.GuildID(), name, data.String("content")); err != nil {
+		content, ok := data.OptString("content")
+		if !ok {
+			if !fitsTagModal(tag.Name) {
+				return common.RespondErrMessage(e.Respond, "The name of this tag doesn't work with the editor, use the `content` option to edit it.")
+			}
+			return e.CreateModal(NewTagModal("tags/edit/"+tag.Name, "Edit tag "+tag.Name, tag.Body()))
+		}
+		body := tag.Body()
+		body.Content = content
+		if err = b.DB.Edit(*e.GuildID(), tag.Name, body, e.User().ID); err != nil {
 			return common.RespondMessageErr(e.Respond, "Failed to edit tag: %s", err)
 		}
 		return common.Respond(e.Respond, "Tag edited.")
 	}
 }
 
+// NewTagModal creates the modal to write the message of a tag. The body is used to prefill the modal.
+func NewTagModal(customID string, title string, body db.TagBody) discord.ModalCreate {
+	var embed db.TagEmbed
+	if body.Embed != nil {
+		embed = *body.Embed
+	}
+	return discord.NewModalCreateBuilder().
+		SetCustomID(customID).
+		SetTitle(truncate(title, 45)).
+		AddActionRow(discord.NewParagraphTextInput("content", "Content").
+			WithRequired(false).
+			WithMaxLength(2000).
+			WithPlaceholder("Supports {{user}}, {{channel}}, {{args}}, {{args.1}} and {{args.1|fallback}}").
+			WithValue(body.Content),
+		).
+		AddActionRow(discord.NewShortTextInput("title", "Embed title").
+			WithRequired(false).
+			WithMaxLength(256).
+			WithValue(embed.Title),
+		).
+		AddActionRow(discord.NewParagraphTextInput("description", "Embed description").
+			WithRequired(false).
+			WithMaxLength(4000).
+			WithValue(embed.Description),
+		).
+		AddActionRow(discord.NewParagraphTextInput("options", "Embed color, link, image and fields").
+			WithRequired(false).
+			WithMaxLength(4000).
+			WithPlaceholder("color: #5865f2\nimage: https://...\nfield: Name | Value\ninline: Name | Value").
+			WithValue(butler.FormatTagEmbedOptions(body.Embed)),
+		).
+		AddActionRow(discord.NewParagraphTextInput("buttons", "Link buttons").
+			WithRequired(false).
+			WithMaxLength(1000).
+			WithPlaceholder("Label | https://...").
+			WithValue(butler.FormatTagButtons(body.Buttons)),
+		).
+		Build()
+}
+
 func HandleDeleteTag(b *butler.Butler) handler.CommandHandler {
 	return func(e *handler.CommandEvent) error {
 		data := e.SlashCommandInteractionData()
@@ -138,7 +417,7 @@ func HandleDeleteTag(b *butler.Butler) handler.CommandHandler {
 			return common.RespondErrMessage(e.Respond, "You do not have permission to delete this tag.")
 		}
 
-		if err = b.DB.Delete(*e.GuildID(), name); err != nil {
+		if err = b.DB.Delete(*e.GuildID(), tag.Name); err != nil {
 			return common.RespondMessageErr(e.Respond, "Failed to delete tag: %s", err)
 		}
 		return common.Respond(e.Respond, "Tag deleted.")
@@ -155,36 +434,101 @@ func HandleTagInfo(b *butler.Butler) handler.CommandHandler {
 		} else if err != nil {
 			return common.RespondMessageErr(e.Respond, "Failed to get tag info: ", err)
 		}
-		return e.CreateMessage(discord.MessageCreate{
-			Embeds: []discord.Embed{
-				{
-					Title:       fmt.Sprintf("Tag `%s`", name),
-					Description: tag.Content,
-					Fields: []discord.EmbedField{
-						{
-							Name:  "Created by",
-							Value: discord.UserMention(tag.OwnerID),
-						},
-						{
-							Name:  "Uses",
-							Value: strconv.Itoa(tag.Uses),
-						},
-						{
-							Name:  "Created at",
-							Value: fmt.Sprintf("%s (%s)", discord.NewTimestamp(discord.TimestampStyleNone, tag.CreatedAt), discord.NewTimestamp(discord.TimestampStyleRelative, tag.CreatedAt)),
-						},
-					},
-				},
+		aliases, err := b.DB.GetAliases(*e.GuildID(), tag.Name)
+		if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to get tag info: %s", err)
+		}
+		fields := []discord.EmbedField{
+			{
+				Name:  "Created by",
+				Value: discord.UserMention(tag.OwnerID),
+			},
+			{
+				Name:  "Uses",
+				Value: strconv.Itoa(tag.Uses),
 			},
+			{
+				Name:  "Created at",
+				Value: fmt.Sprintf("%s (%s)", discord.NewTimestamp(discord.TimestampStyleNone, tag.CreatedAt), discord.NewTimestamp(discord.TimestampStyleRelative, tag.CreatedAt)),
+			},
+		}
+		if len(aliases) > 0 {
+			fields = append(fields, discord.EmbedField{
+				Name:  "Aliases",
+				Value: formatTagAliases(aliases),
+			})
+		}
+		dailyUses, err := b.DB.GetTagDailyUses(*e.GuildID(), tag.Name, time.Now().Add(-tagInfoDays*24*time.Hour))
+		if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to get tag info: %s", err)
+		}
+		if uses := butler.DailyTagUses(dailyUses, tagInfoDays, time.Now()); sum(uses) > 0 {
+			fields = append(fields, discord.EmbedField{
+				Name:  fmt.Sprintf("Uses in the last %d days", tagInfoDays),
+				Value: fmt.Sprintf("`%s` %d", butler.Sparkline(uses), sum(uses)),
+			})
+		}
+
+		// preview tags with variables the way they would be sent by the user
+		preview, unknown := butler.RenderTag(tag.Body(), tagContext(b, e, data.String("args")))
+		if len(unknown) > 0 {
+			fields = append(fields, discord.EmbedField{
+				Name:  "Unknown variables",
+				Value: truncate("`"+strings.Join(unknown, "`, `")+"`", 1024),
+			})
+		}
+		// all embeds of a message share one length limit, the body is shortened to fit next to the fields and the
+		// previews are only sent if they fit into what is left
+		info := discord.Embed{
+			Title:  fmt.Sprintf("Tag `%s`", tag.Name),
+			Fields: fields,
+		}
+		maxDescription := common.MaxEmbedsLength - common.EmbedLength(info)
+		if maxDescription > 4096 {
+			maxDescription = 4096
+		}
+		info.Description = truncate(butler.FormatTagBody(tag.Body()), maxDescription)
+		embeds := []discord.Embed{info}
+		remaining := common.MaxEmbedsLength - common.EmbedLength(info)
+
+		if butler.FormatTagBody(preview) != butler.FormatTagBody(tag.Body()) {
+			var previews []discord.Embed
+			if preview.Content != "" {
+				previews = append(previews, discord.Embed{
+					Title:       "Preview",
+					Description: truncate(preview.Content, 4096),
+				})
+			}
+			if preview.Embed != nil {
+				previews = append(previews, butler.TagMessage(preview, nil).Embeds...)
+			}
+			for _, embed := range previews {
+				if length := common.EmbedLength(embed); length <= remaining {
+					embeds = append(embeds, embed)
+					remaining -= length
+				}
+			}
+		}
+		return e.CreateMessage(discord.MessageCreate{
+			Embeds:          embeds,
+			AllowedMentions: &discord.AllowedMentions{},
 		})
 	}
 }
 
 func HandleListTags(b *butler.Butler) handler.CommandHandler {
 	return func(e *handler.CommandEvent) error {
-		tags, err := b.DB.GetAll(*e.GuildID())
+		var (
+			tags []db.Tag
+			err  error
+		)
+		if owner, ok := e.SlashCommandInteractionData().OptUser("owner"); ok {
+			tags, err = b.DB.GetAllUser(*e.GuildID(), owner.ID)
+		} else {
+			tags, err = b.DB.GetAll(*e.GuildID())
+		}
 		if err != nil {
-			return common.RespondMessageErr(e.Respond, "Failed to list tags: ", err)
+			return common.RespondMessageErr(e.Respond, "Failed to list tags: %s", err)
 		}
 		if len(tags) == 0 {
 			return common.Respond(e.Respond, "No tags found.")
@@ -215,6 +559,133 @@ func HandleListTags(b *butler.Butler) handler.CommandHandler {
 	}
 }
 
+// tagInfoDays is the number of days /tags info shows the uses of.
+const tagInfoDays = 30
+
+func HandleTagStats(b *butler.Butler) handler.CommandHandler {
+	return func(e *handler.CommandEvent) error {
+		days, ok := e.SlashCommandInteractionData().OptInt("days")
+		if !ok {
+			days = 30
+		}
+		stats, err := butler.GetTagStats(b.DB, *e.GuildID(), days, 10)
+		if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to get tag stats: %s", err)
+		}
+
+		top := make([]string, len(stats.Top))
+		for i, count := range stats.Top {
+			top[i] = fmt.Sprintf("%d. **%s** - %d uses", i+1, count.TagName, count.Uses)
+		}
+		trending := make([]string, len(stats.Trending))
+		for i, trend := range stats.Trending {
+			trending[i] = fmt.Sprintf("**%s** - %d uses (+%d)", trend.TagName, trend.Uses, trend.Uses-trend.PreviousUses)
+		}
+		unused := make([]string, len(stats.Unused))
+		for i, tag := range stats.Unused {
+			unused[i] = fmt.Sprintf("`%s`", tag.Name)
+		}
+		topUsers := make([]string, len(stats.TopUsers))
+		for i, count := range stats.TopUsers {
+			topUsers[i] = fmt.Sprintf("%d. %s - %d uses", i+1, discord.UserMention(count.UserID), count.Uses)
+		}
+
+		return e.CreateMessage(discord.MessageCreate{
+			Embeds: []discord.Embed{{
+				Title: fmt.Sprintf("Tag stats of the last %d days", days),
+				Fields: []discord.EmbedField{
+					{Name: "Top tags", Value: formatTagStats(top, "\n")},
+					{Name: "Trending tags", Value: formatTagStats(trending, "\n")},
+					{Name: fmt.Sprintf("Unused tags (%d)", len(stats.Unused)), Value: formatTagStats(unused, ", ")},
+					{Name: "Top users", Value: formatTagStats(topUsers, "\n")},
+				},
+				Color: common.ColorSuccess,
+			}},
+			AllowedMentions: &discord.AllowedMentions{},
+		})
+	}
+}
+
+// formatTagStats joins the lines of a stats field and cuts them to fit into the field.
+func formatTagStats(lines []string, sep string) string {
+	if len(lines) == 0 {
+		return "None"
+	}
+	value := strings.Join(lines, sep)
+	if len(value) > 1024 {
+		if end := strings.LastIndex(value[:1020], sep); end != -1 {
+			value = value[:end] + sep + "..."
+		} else {
+			value = truncate(value, 1020)
+		}
+	}
+	return value
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, value := range values {
+		total += value
+	}
+	return total
+}
+
+func HandleTransferTag(b *butler.Butler) handler.CommandHandler {
+	return func(e *handler.CommandEvent) error {
+		data := e.SlashCommandInteractionData()
+		tag, err := b.DB.Get(*e.GuildID(), formatTagName(data.String("name")))
+		if err == sql.ErrNoRows {
+			return common.RespondErrMessage(e.Respond, "Tag not found.")
+		} else if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to transfer tag: %s", err)
+		}
+		if e.User().ID != tag.OwnerID && e.Member().Permissions.Missing(discord.PermissionManageServer) {
+			return common.RespondErrMessage(e.Respond, "You do not have permission to transfer this tag.")
+		}
+
+		user := data.User("user")
+		if user.Bot {
+			return common.RespondErrMessage(e.Respond, "Tags can't be owned by bots.")
+		}
+		if user.ID == tag.OwnerID {
+			return common.RespondErrMessagef(e.Respond, "%s already owns this tag.", discord.UserMention(user.ID))
+		}
+		if err = b.DB.Transfer(*e.GuildID(), tag.Name, user.ID); err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to transfer tag: %s", err)
+		}
+		b.Logger.Infof("tag %s in guild %s was transferred from %s to %s by %s", tag.Name, *e.GuildID(), tag.OwnerID, user.ID, e.User().ID)
+		return common.Respondf(e.Respond, "Tag `%s` transferred to %s.", tag.Name, discord.UserMention(user.ID))
+	}
+}
+
+func HandleClaimTag(b *butler.Butler) handler.CommandHandler {
+	return func(e *handler.CommandEvent) error {
+		tag, err := b.DB.Get(*e.GuildID(), formatTagName(e.SlashCommandInteractionData().String("name")))
+		if err == sql.ErrNoRows {
+			return common.RespondErrMessage(e.Respond, "Tag not found.")
+		} else if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to claim tag: %s", err)
+		}
+		if tag.OwnerID == e.User().ID {
+			return common.RespondErrMessage(e.Respond, "You already own this tag.")
+		}
+
+		if _, err = e.Client().Rest().GetMember(*e.GuildID(), tag.OwnerID); err == nil {
+			return common.RespondErrMessagef(e.Respond, "The owner of this tag is still in the server, ask %s to transfer it to you.", discord.UserMention(tag.OwnerID))
+		}
+		var restErr *rest.Error
+		if !errors.As(err, &restErr) || restErr.Response == nil || restErr.Response.StatusCode != http.StatusNotFound {
+			return common.RespondMessageErr(e.Respond, "Failed to claim tag: %s", err)
+		}
+
+		if err = b.DB.Transfer(*e.GuildID(), tag.Name, e.User().ID); err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to claim tag: %s", err)
+		}
+		b.Logger.Infof("tag %s in guild %s was claimed by %s from %s, who left the guild", tag.Name, *e.GuildID(), e.User().ID, tag.OwnerID)
+		return common.Respondf(e.Respond, "%s claimed tag `%s` from %s, who left the server.", discord.UserMention(e.User().ID), tag.Name, discord.UserMention(tag.OwnerID))
+	}
+}
+
 func HandleTagListAutoComplete(b *butler.Butler, filterTags bool) handler.AutocompleteHandler {
 	return func(e *handler.AutocompleteEvent) error {
 		name := formatTagName(e.Data.String("name"))
@@ -229,14 +700,26 @@ func HandleTagListAutoComplete(b *butler.Butler, filterTags bool) handler.Autoco
 			tags, err = b.DB.GetAll(*e.GuildID())
 		}
 
+		if err != nil {
+			return e.Result(nil)
+		}
+		aliases, err := b.DB.GetAllAliases(*e.GuildID())
 		if err != nil {
 			return e.Result(nil)
 		}
 		var response []discord.AutocompleteChoice
 
 		options := make([]string, len(tags))
+		tagNames := make(map[string]struct{}, len(tags))
 		for i := range tags {
 			options[i] = tags[i].Name
+			tagNames[tags[i].Name] = struct{}{}
+		}
+		// aliases of the listed tags resolve to their tag, so they can be used like the tag name
+		for _, alias := range aliases {
+			if _, ok := tagNames[alias.TagName]; ok {
+				options = append(options, alias.Name)
+			}
 		}
 		options = fuzzy.FindFold(name, options)
 		for _, option := range options {
@@ -252,6 +735,415 @@ func HandleTagListAutoComplete(b *butler.Butler, filterTags bool) handler.Autoco
 	}
 }
 
+func HandleTagHistory(b *butler.Butler) handler.CommandHandler {
+	return func(e *handler.CommandEvent) error {
+		name := formatTagName(e.SlashCommandInteractionData().String("name"))
+
+		tag, err := b.DB.Get(*e.GuildID(), name)
+		if err == sql.ErrNoRows {
+			return common.RespondErrMessage(e.Respond, "Tag not found.")
+		} else if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to get tag history: %s", err)
+		}
+		revisions, err := b.DB.GetRevisions(*e.GuildID(), tag.Name)
+		if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to get tag history: %s", err)
+		}
+		if len(revisions) == 0 {
+			return common.Respondf(e.Respond, "Tag `%s` has not been edited since revisions are tracked.", tag.Name)
+		}
+
+		return b.Paginator.Create(e.Respond, paginator.Pages{
+			ID: e.ID().String(),
+			PageFunc: func(page int, embed *discord.EmbedBuilder) {
+				revision := revisions[page]
+				var previous string
+				if page+1 < len(revisions) {
+					previous = butler.FormatTagBody(revisions[page+1].Body())
+				}
+				header := fmt.Sprintf("Revision **%d** by %s %s\n", revision.Revision, discord.UserMention(revision.EditorID), discord.NewTimestamp(discord.TimestampStyleRelative, revision.CreatedAt))
+				embed.SetTitle(fmt.Sprintf("History of tag `%s`", tag.Name))
+				embed.SetDescription(header + tagDiffBlock(common.LineDiff(previous, butler.FormatTagBody(revision.Body())), 4096-len(header)))
+			},
+			Pages:      len(revisions),
+			ExpireMode: paginator.ExpireModeAfterLastUsage,
+		}, false)
+	}
+}
+
+func HandleTagRollback(b *butler.Butler) handler.CommandHandler {
+	return func(e *handler.CommandEvent) error {
+		data := e.SlashCommandInteractionData()
+		name := formatTagName(data.String("name"))
+
+		tag, err := b.DB.Get(*e.GuildID(), name)
+		if err == sql.ErrNoRows {
+			return common.RespondErrMessage(e.Respond, "Tag not found.")
+		} else if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to roll back tag: %s", err)
+		}
+		if e.User().ID != tag.OwnerID && e.Member().Permissions.Missing(discord.PermissionManageServer) {
+			return common.RespondErrMessage(e.Respond, "You do not have permission to roll back this tag.")
+		}
+
+		revision, err := b.DB.GetRevision(*e.GuildID(), tag.Name, data.Int("revision"))
+		if err == sql.ErrNoRows {
+			return common.RespondErrMessage(e.Respond, "Revision not found.")
+		} else if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to roll back tag: %s", err)
+		}
+		if butler.FormatTagBody(revision.Body()) == butler.FormatTagBody(tag.Body()) {
+			return common.RespondErrMessagef(e.Respond, "Tag `%s` already has the content of revision %d.", tag.Name, revision.Revision)
+		}
+
+		// the rollback is a new revision, so it can be undone as well
+		if err = b.DB.Edit(*e.GuildID(), tag.Name, revision.Body(), e.User().ID); err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to roll back tag: %s", err)
+		}
+		return common.Respondf(e.Respond, "Tag `%s` rolled back to revision %d.", tag.Name, revision.Revision)
+	}
+}
+
+// tagDiffBlock renders the diff as a code block of at most maxLen characters. Lines which don't fit are cut off.
+func tagDiffBlock(diff string, maxLen int) string {
+	const prefix, suffix, cut = "```diff\n", "```", "...\n"
+	diff = strings.ReplaceAll(diff, "```", "`\u200b``")
+	if len(prefix)+len(diff)+len(suffix) > maxLen {
+		diff = diff[:strings.LastIndex(diff[:maxLen-len(prefix)-len(suffix)-len(cut)], "\n")+1] + cut
+	}
+	return prefix + diff + suffix
+}
+
+func HandleAddTagAttachment(b *butler.Butler) handler.CommandHandler {
+	return func(e *handler.CommandEvent) error {
+		data := e.SlashCommandInteractionData()
+		tag, err := b.DB.Get(*e.GuildID(), formatTagName(data.String("name")))
+		if err == sql.ErrNoRows {
+			return common.RespondErrMessage(e.Respond, "Tag not found.")
+		} else if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to add file: %s", err)
+		}
+		if e.User().ID != tag.OwnerID && e.Member().Permissions.Missing(discord.PermissionManageServer) {
+			return common.RespondErrMessage(e.Respond, "You do not have permission to edit this tag.")
+		}
+
+		file := data.Attachment("file")
+		if file.Size > butler.MaxTagAttachmentSize {
+			return common.RespondErrMessagef(e.Respond, "Files of tags can be at most %d MiB.", butler.MaxTagAttachmentSize/1024/1024)
+		}
+		attachments, err := b.DB.GetAttachments(*e.GuildID(), tag.Name)
+		if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to add file: %s", err)
+		}
+		if len(attachments) >= butler.MaxTagAttachments && !hasTagAttachment(attachments, file.Filename) {
+			return common.RespondErrMessagef(e.Respond, "Tags can have at most %d files.", butler.MaxTagAttachments)
+		}
+		size := file.Size
+		for _, attachment := range attachments {
+			// a file with the same name is replaced
+			if attachment.Filename != file.Filename {
+				size += len(attachment.Data)
+			}
+		}
+		if size > butler.MaxTagAttachmentsSize {
+			return common.RespondErrMessagef(e.Respond, "The files of a tag can be at most %d MiB together.", butler.MaxTagAttachmentsSize/1024/1024)
+		}
+
+		if err = e.DeferCreateMessage(true); err != nil {
+			return err
+		}
+		// the attachment is downloaded, as discord removes attachments of interactions after a while
+		content, err := downloadAttachment(e.Client().Rest().HTTPClient(), file, butler.MaxTagAttachmentSize)
+		if err == nil {
+			err = b.DB.SetAttachment(db.TagAttachment{
+				GuildID:  *e.GuildID(),
+				TagName:  tag.Name,
+				Filename: file.Filename,
+				Data:     content,
+			})
+		}
+		message := fmt.Sprintf("File `%s` added to tag `%s`.", file.Filename, tag.Name)
+		if err != nil {
+			message = fmt.Sprintf("Failed to add file: %s", err)
+		}
+		_, err = e.UpdateInteractionResponse(discord.MessageUpdate{Content: &message})
+		return err
+	}
+}
+
+func HandleRemoveTagAttachment(b *butler.Butler) handler.CommandHandler {
+	return func(e *handler.CommandEvent) error {
+		data := e.SlashCommandInteractionData()
+		tag, err := b.DB.Get(*e.GuildID(), formatTagName(data.String("name")))
+		if err == sql.ErrNoRows {
+			return common.RespondErrMessage(e.Respond, "Tag not found.")
+		} else if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to remove file: %s", err)
+		}
+		if e.User().ID != tag.OwnerID && e.Member().Permissions.Missing(discord.PermissionManageServer) {
+			return common.RespondErrMessage(e.Respond, "You do not have permission to edit this tag.")
+		}
+
+		attachments, err := b.DB.GetAttachments(*e.GuildID(), tag.Name)
+		if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to remove file: %s", err)
+		}
+		filename := data.String("filename")
+		if !hasTagAttachment(attachments, filename) {
+			return common.RespondErrMessagef(e.Respond, "Tag `%s` has no file named `%s`.", tag.Name, filename)
+		}
+		if err = b.DB.DeleteAttachment(*e.GuildID(), tag.Name, filename); err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to remove file: %s", err)
+		}
+		return common.Respondf(e.Respond, "File `%s` removed from tag `%s`.", filename, tag.Name)
+	}
+}
+
+func hasTagAttachment(attachments []db.TagAttachment, filename string) bool {
+	for _, attachment := range attachments {
+		if attachment.Filename == filename {
+			return true
+		}
+	}
+	return false
+}
+
+func downloadAttachment(client *http.Client, attachment discord.Attachment, maxSize int64) ([]byte, error) {
+	rs, err := client.Get(attachment.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Body.Close()
+	if rs.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", rs.Status)
+	}
+	return io.ReadAll(io.LimitReader(rs.Body, maxSize))
+}
+
+func HandleExportTags(b *butler.Butler) handler.CommandHandler {
+	return func(e *handler.CommandEvent) error {
+		if e.Member().Permissions.Missing(discord.PermissionManageServer) {
+			return common.RespondErrMessage(e.Respond, "You need the Manage Server permission to export tags.")
+		}
+		format := butler.TagsFormat(e.SlashCommandInteractionData().String("format"))
+		if format == "" {
+			format = butler.TagsFormatJSON
+		}
+
+		if err := e.DeferCreateMessage(true); err != nil {
+			return err
+		}
+		var update discord.MessageUpdate
+		data, export, err := exportTags(b, *e.GuildID(), format)
+		if err != nil {
+			update.Content = json.Ptr(fmt.Sprintf("Failed to export tags: %s", err))
+		} else {
+			message := fmt.Sprintf("Exported %d tags.", len(export.Tags))
+			if omitted := export.OmittedFiles(); omitted > 0 {
+				message += fmt.Sprintf(" %d files were exported without their data to stay below %d MiB.", omitted, butler.MaxTagsExportSize/1024/1024)
+			}
+			update.Content = &message
+			update.Files = []*discord.File{discord.NewFile("tags."+string(format), "", bytes.NewReader(data))}
+		}
+		_, err = e.UpdateInteractionResponse(update)
+		return err
+	}
+}
+
+// exportTags encodes the tags of the guild so the file fits into a message. Files are left out once they don't fit
+// anymore and if the tags are still too large, all files are exported without their data.
+func exportTags(b *butler.Butler, guildID snowflake.ID, format butler.TagsFormat) ([]byte, butler.TagsExport, error) {
+	// files are base64 encoded in JSON
+	for _, maxFilesSize := range []int{butler.MaxTagsExportSize * 3 / 4, 0} {
+		export, err := butler.ExportTags(b.DB, guildID, maxFilesSize)
+		if err != nil {
+			return nil, butler.TagsExport{}, err
+		}
+		data, err := butler.EncodeTags(export, format)
+		if err != nil {
+			return nil, butler.TagsExport{}, err
+		}
+		if len(data) <= butler.MaxTagsExportSize {
+			return data, export, nil
+		}
+	}
+	return nil, butler.TagsExport{}, fmt.Errorf("the tags are larger than %d MiB, export them with the -export-tags flag instead", butler.MaxTagsExportSize/1024/1024)
+}
+
+func HandleImportTags(b *butler.Butler) handler.CommandHandler {
+	return func(e *handler.CommandEvent) error {
+		if e.Member().Permissions.Missing(discord.PermissionManageServer) {
+			return common.RespondErrMessage(e.Respond, "You need the Manage Server permission to import tags.")
+		}
+		data := e.SlashCommandInteractionData()
+		conflict := butler.TagConflictSkip
+		if name, ok := data.OptString("conflict"); ok {
+			var err error
+			if conflict, err = butler.ParseTagConflict(name); err != nil {
+				return common.RespondErrMessage(e.Respond, err.Error())
+			}
+		}
+		file := data.Attachment("file")
+		if file.Size > butler.MaxTagsImportSize {
+			return common.RespondErrMessagef(e.Respond, "Tag files can be at most %d MiB.", butler.MaxTagsImportSize/1024/1024)
+		}
+
+		if err := e.DeferCreateMessage(true); err != nil {
+			return err
+		}
+		var message string
+		content, err := downloadAttachment(e.Client().Rest().HTTPClient(), file, butler.MaxTagsImportSize)
+		if err != nil {
+			message = fmt.Sprintf("Failed to download file: %s", err)
+		} else if export, err := butler.DecodeTags(content, butler.TagsFormatFromFilename(file.Filename)); err != nil {
+			message = fmt.Sprintf("Failed to import tags: %s", err)
+		} else if result, err := butler.ImportTags(b.DB, *e.GuildID(), export, conflict, e.User().ID); err != nil {
+			message = fmt.Sprintf("Failed to import tags, no tags were imported: %s", err)
+		} else {
+			message = fmt.Sprintf("Tags imported: %s.", result)
+			for name, newName := range result.Renamed {
+				message += fmt.Sprintf("\n`%s` was imported as `%s`", name, newName)
+			}
+		}
+		if len(message) > 2000 {
+			if end := strings.LastIndex(message[:1996], "\n"); end != -1 {
+				message = message[:end] + "\n..."
+			} else {
+				message = truncate(message, 2000)
+			}
+		}
+		_, err = e.UpdateInteractionResponse(discord.MessageUpdate{Content: &message})
+		return err
+	}
+}
+
+func HandleAddTagAlias(b *butler.Butler) handler.CommandHandler {
+	return func(e *handler.CommandEvent) error {
+		data := e.SlashCommandInteractionData()
+		name := formatTagName(data.String("name"))
+		aliasName := formatTagName(data.String("alias"))
+
+		tag, err := b.DB.Get(*e.GuildID(), name)
+		if err == sql.ErrNoRows {
+			return common.RespondErrMessage(e.Respond, "Tag not found.")
+		} else if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to add alias: %s", err)
+		}
+		if e.User().ID != tag.OwnerID && e.Member().Permissions.Missing(discord.PermissionManageServer) {
+			return common.RespondErrMessage(e.Respond, "You do not have permission to add aliases to this tag.")
+		}
+
+		// the alias must neither be a tag nor an alias already
+		if _, err = b.DB.Get(*e.GuildID(), aliasName); err == nil {
+			return common.RespondErrMessagef(e.Respond, "A tag or alias named `%s` already exists.", aliasName)
+		} else if err != sql.ErrNoRows {
+			return common.RespondMessageErr(e.Respond, "Failed to add alias: %s", err)
+		}
+
+		if err = b.DB.CreateAlias(*e.GuildID(), aliasName, tag.Name); err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to add alias: %s", err)
+		}
+		return common.Respondf(e.Respond, "Alias `%s` added to tag `%s`.", aliasName, tag.Name)
+	}
+}
+
+func HandleRemoveTagAlias(b *butler.Butler) handler.CommandHandler {
+	return func(e *handler.CommandEvent) error {
+		aliasName := formatTagName(e.SlashCommandInteractionData().String("alias"))
+
+		alias, err := b.DB.GetAlias(*e.GuildID(), aliasName)
+		if err == sql.ErrNoRows {
+			return common.RespondErrMessage(e.Respond, "Alias not found.")
+		} else if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to remove alias: %s", err)
+		}
+		tag, err := b.DB.Get(*e.GuildID(), alias.TagName)
+		if err != nil && err != sql.ErrNoRows {
+			return common.RespondMessageErr(e.Respond, "Failed to remove alias: %s", err)
+		}
+		if e.User().ID != tag.OwnerID && e.Member().Permissions.Missing(discord.PermissionManageServer) {
+			return common.RespondErrMessage(e.Respond, "You do not have permission to remove aliases of this tag.")
+		}
+
+		if err = b.DB.DeleteAlias(*e.GuildID(), alias.Name); err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to remove alias: %s", err)
+		}
+		return common.Respondf(e.Respond, "Alias `%s` removed from tag `%s`.", alias.Name, alias.TagName)
+	}
+}
+
+func HandleListTagAliases(b *butler.Butler) handler.CommandHandler {
+	return func(e *handler.CommandEvent) error {
+		name := formatTagName(e.SlashCommandInteractionData().String("name"))
+
+		tag, err := b.DB.Get(*e.GuildID(), name)
+		if err == sql.ErrNoRows {
+			return common.RespondErrMessage(e.Respond, "Tag not found.")
+		} else if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to list aliases: %s", err)
+		}
+		aliases, err := b.DB.GetAliases(*e.GuildID(), tag.Name)
+		if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to list aliases: %s", err)
+		}
+		if len(aliases) == 0 {
+			return common.Respondf(e.Respond, "Tag `%s` has no aliases.", tag.Name)
+		}
+		return common.Respondf(e.Respond, "Aliases of tag `%s`: %s", tag.Name, formatTagAliases(aliases))
+	}
+}
+
+// HandleTagAliasAutoComplete suggests the aliases which the user may remove.
+func HandleTagAliasAutoComplete(b *butler.Butler) handler.AutocompleteHandler {
+	return func(e *handler.AutocompleteEvent) error {
+		aliases, err := b.DB.GetAllAliases(*e.GuildID())
+		if err != nil {
+			return e.Result(nil)
+		}
+		if e.Member().Permissions.Missing(discord.PermissionManageServer) {
+			tags, err := b.DB.GetAllUser(*e.GuildID(), e.User().ID)
+			if err != nil {
+				return e.Result(nil)
+			}
+			owned := make(map[string]struct{}, len(tags))
+			for _, tag := range tags {
+				owned[tag.Name] = struct{}{}
+			}
+			filtered := aliases[:0]
+			for _, alias := range aliases {
+				if _, ok := owned[alias.TagName]; ok {
+					filtered = append(filtered, alias)
+				}
+			}
+			aliases = filtered
+		}
+
+		options := make([]string, len(aliases))
+		for i := range aliases {
+			options[i] = aliases[i].Name
+		}
+		var response []discord.AutocompleteChoice
+		for _, option := range fuzzy.FindFold(formatTagName(e.Data.String("alias")), options) {
+			if len(response) >= 25 {
+				break
+			}
+			response = append(response, discord.AutocompleteChoiceString{
+				Name:  option,
+				Value: option,
+			})
+		}
+		return e.Result(response)
+	}
+}
+
+func formatTagAliases(aliases []db.TagAlias) string {
+	names := make([]string, len(aliases))
+	for i, alias := range aliases {
+		names[i] = "`" + alias.Name + "`"
+	}
+	return strings.Join(names, ", ")
+}
+
 func formatTagName(name string) string {
 	return strings.ToLower(name)
 }
@@ -0,0 +1,86 @@
+package common
+
+import "strings"
+
+// maxDiffCells limits the size of the table LineDiff compares the changed lines with. Texts which differ in more
+// lines are shown as completely replaced.
+const maxDiffCells = 1 << 20
+
+// LineDiff compares the lines of two texts and returns them in the format of a diff code block. Removed lines are
+// prefixed with "-", added lines with "+" and unchanged lines with a space.
+func LineDiff(oldText string, newText string) string {
+	oldLines := splitLines(oldText)
+	newLines := splitLines(newText)
+
+	var prefix, suffix []string
+	for len(oldLines) > 0 && len(newLines) > 0 && oldLines[0] == newLines[0] {
+		prefix = append(prefix, oldLines[0])
+		oldLines, newLines = oldLines[1:], newLines[1:]
+	}
+	for len(oldLines) > 0 && len(newLines) > 0 && oldLines[len(oldLines)-1] == newLines[len(newLines)-1] {
+		suffix = append(suffix, oldLines[len(oldLines)-1])
+		oldLines, newLines = oldLines[:len(oldLines)-1], newLines[:len(newLines)-1]
+	}
+
+	var sb strings.Builder
+	for _, line := range prefix {
+		sb.WriteString("  " + line + "\n")
+	}
+	if (len(oldLines)+1)*(len(newLines)+1) > maxDiffCells {
+		for _, line := range oldLines {
+			sb.WriteString("- " + line + "\n")
+		}
+		for _, line := range newLines {
+			sb.WriteString("+ " + line + "\n")
+		}
+	} else {
+		writeLineDiff(&sb, oldLines, newLines)
+	}
+	for i := len(suffix) - 1; i >= 0; i-- {
+		sb.WriteString("  " + suffix[i] + "\n")
+	}
+	return sb.String()
+}
+
+// writeLineDiff writes the lines of both texts in the order of their longest common subsequence.
+func writeLineDiff(sb *strings.Builder, oldLines []string, newLines []string) {
+	// lcs[i][j] is the length of the longest common subsequence of oldLines[i:] and newLines[j:]
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			sb.WriteString("  " + oldLines[i] + "\n")
+			i++
+			j++
+		case i < len(oldLines) && (j == len(newLines) || lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("- " + oldLines[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+ " + newLines[j] + "\n")
+			j++
+		}
+	}
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
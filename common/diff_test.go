@@ -0,0 +1,43 @@
+package common
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		oldText  string
+		newText  string
+		expected string
+	}{
+		{"", "a\nb", "+ a\n+ b\n"},
+		{"a\nb\nc", "a\nc", "  a\n- b\n  c\n"},
+		{"a\nb", "a\nx\nb\n", "  a\n+ x\n  b\n"},
+		{"a\nb", "a\nc", "  a\n- b\n+ c\n"},
+	}
+	for _, tt := range tests {
+		if actual := LineDiff(tt.oldText, tt.newText); actual != tt.expected {
+			t.Errorf("LineDiff(%q, %q) = %q, expected %q", tt.oldText, tt.newText, actual, tt.expected)
+		}
+	}
+}
+
+func TestLineDiffLarge(t *testing.T) {
+	var oldLines, newLines []string
+	for i := 0; i < 2000; i++ {
+		oldLines = append(oldLines, "old "+strconv.Itoa(i))
+		newLines = append(newLines, "new "+strconv.Itoa(i))
+	}
+	oldText := "a\n" + strings.Join(oldLines, "\n") + "\nz"
+	newText := "a\n" + strings.Join(newLines, "\n") + "\nz"
+
+	diff := LineDiff(oldText, newText)
+	if !strings.HasPrefix(diff, "  a\n- old 0\n") || !strings.HasSuffix(diff, "+ new 1999\n  z\n") {
+		t.Errorf("expected unchanged lines around the replaced lines, got %q...%q", diff[:20], diff[len(diff)-20:])
+	}
+	if lines := strings.Count(diff, "\n"); lines != 4002 {
+		t.Errorf("expected 4002 lines, got %d", lines)
+	}
+}
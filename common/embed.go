@@ -0,0 +1,25 @@
+package common
+
+import (
+	"unicode/utf8"
+
+	"github.com/disgoorg/disgo/discord"
+)
+
+// MaxEmbedsLength is the maximum length of all embeds of a message together.
+const MaxEmbedsLength = 6000
+
+// EmbedLength returns the length of the embed the way discord counts it against MaxEmbedsLength.
+func EmbedLength(embed discord.Embed) int {
+	length := utf8.RuneCountInString(embed.Title) + utf8.RuneCountInString(embed.Description)
+	for _, field := range embed.Fields {
+		length += utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+	}
+	if embed.Footer != nil {
+		length += utf8.RuneCountInString(embed.Footer.Text)
+	}
+	if embed.Author != nil {
+		length += utf8.RuneCountInString(embed.Author.Name)
+	}
+	return length
+}
@@ -0,0 +1,20 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/disgoorg/disgo/discord"
+)
+
+func TestEmbedLength(t *testing.T) {
+	embed := discord.Embed{
+		Title:       "Tag",
+		Description: "äöü",
+		Fields:      []discord.EmbedField{{Name: "Uses", Value: "12"}},
+		Footer:      &discord.EmbedFooter{Text: "footer"},
+		Author:      &discord.EmbedAuthor{Name: "author"},
+	}
+	if length := EmbedLength(embed); length != 24 {
+		t.Errorf("expected length 24, got %d", length)
+	}
+}
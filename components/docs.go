@@ -2,12 +2,15 @@ package components
 
 import (
 	"context"
+	"strconv"
 	"strings"
 
 	"github.com/disgoorg/disgo-butler/butler"
+	"github.com/disgoorg/disgo-butler/commands"
 	"github.com/disgoorg/disgo-butler/common"
 	"github.com/disgoorg/disgo/discord"
 	"github.com/disgoorg/disgo/handler"
+	"github.com/disgoorg/snowflake/v2"
 )
 
 func HandleDocsAction(b *butler.Butler) handler.ComponentHandler {
@@ -17,44 +20,149 @@ func HandleDocsAction(b *butler.Butler) handler.ComponentHandler {
 			if e.Message.Interaction.User.ID != e.User().ID && e.Member().Permissions.Missing(discord.PermissionManageMessages) {
 				return common.RespondErrMessage(e.Respond, "You don't have permission to delete this message.")
 			}
+			b.DocsStates.Delete(docsStateID(e))
 			_ = e.DeferUpdateMessage()
 			return e.Client().Rest().DeleteInteractionResponse(e.ApplicationID(), e.Token())
 		}
-		values := strings.SplitN(e.Message.Embeds[0].Title, ": ", 2)
-		pkg, err := b.DocClient.Search(context.Background(), values[0])
-		if err != nil {
-			return common.RespondErrMessagef(e.Respond, "Error while fetching package: %s", err)
-		}
 
-		var (
-			expandSignature bool
-			expandComment   bool
-			expandMethods   bool
-			expandExamples  bool
-		)
-		if strings.HasPrefix(action, "expand:") {
-			switch strings.TrimPrefix(action, "expand:") {
-			case "signature":
-				expandSignature = true
-			case "methods":
-				expandMethods = true
-			case "comment":
-				expandComment = true
-			case "examples":
-				expandExamples = true
-			}
-		} else if !strings.HasPrefix(action, "collapse:") {
+		state := getDocsState(b, e)
+		name, value, _ := strings.Cut(action, ":")
+		if name == "run" {
+			return runDocsExample(b, e, state, value)
+		}
+		section, ok := butler.ParseDocsSection(value)
+		if !ok {
+			return common.RespondErrMessagef(e.Respond, "Unknown action: %s", action)
+		}
+		switch name {
+		case "expand":
+			state.Expand(section)
+		case "collapse":
+			state.Collapse(section)
+		default:
 			return common.RespondErrMessagef(e.Respond, "Unknown action: %s", action)
 		}
+		return updateDocs(b, e, state)
+	}
+}
 
-		var query string
-		if len(values) > 1 {
-			query = values[1]
+func HandleDocsPage(b *butler.Butler) handler.ComponentHandler {
+	return func(e *handler.ComponentEvent) error {
+		state := getDocsState(b, e)
+		switch e.Variables["direction"] {
+		case "previous":
+			state.Page--
+		case "next":
+			state.Page++
 		}
-		embed, selectMenu := butler.GetDocsEmbed(pkg, query, expandSignature, expandComment, expandMethods, expandExamples)
-		if e.Message.Interaction.User.ID != e.User().ID && e.Member().Permissions.Missing(discord.PermissionManageMessages) {
-			return e.CreateMessage(discord.MessageCreate{Embeds: []discord.Embed{embed}, Flags: discord.MessageFlagEphemeral})
+		return updateDocs(b, e, state)
+	}
+}
+
+func HandleDocsRelated(b *butler.Butler) handler.ComponentHandler {
+	return func(e *handler.ComponentEvent) error {
+		pkgPath, symbol, _ := strings.Cut(e.StringSelectMenuInteractionData().Values[0], "#")
+		state := getDocsState(b, e)
+		sameModule := b.ModulePath(pkgPath, state.Version) == b.ModulePath(state.Package, state.Version)
+		return updateDocs(b, e, state.Navigate(butler.DocsRef{Package: pkgPath, Symbol: symbol}, sameModule))
+	}
+}
+
+func HandleDocsBack(b *butler.Butler) handler.ComponentHandler {
+	return func(e *handler.ComponentEvent) error {
+		state, ok := getDocsState(b, e).Back()
+		if !ok {
+			return common.RespondErrMessage(e.Respond, "There is nothing to go back to.")
 		}
-		return e.UpdateMessage(discord.MessageUpdate{Embeds: &[]discord.Embed{embed}, Components: &[]discord.ContainerComponent{discord.NewActionRow(selectMenu)}})
+		return updateDocs(b, e, state)
 	}
 }
+
+func HandleDocsSearch(b *butler.Butler) handler.ComponentHandler {
+	return func(e *handler.ComponentEvent) error {
+		if e.Message.Interaction.User.ID != e.User().ID {
+			return common.RespondErrMessage(e.Respond, "You can only select results of your own searches.")
+		}
+		pkgPath, query, _ := strings.Cut(e.StringSelectMenuInteractionData().Values[0], "#")
+
+		pkg, err := b.DocClient.Search(context.Background(), pkgPath)
+		if err != nil {
+			return common.RespondErrMessagef(e.Respond, "Error while fetching package: %s", err)
+		}
+		b.DocsUsage.Increment(pkg.URL)
+
+		state := butler.NewDocsState(b.Config.Docs.Aliases, pkgPath, query)
+		embed, components := b.DocsEmbed(pkg, &state)
+		b.DocsStates.Set(docsStateID(e), state)
+		return e.UpdateMessage(discord.MessageUpdate{Embeds: &[]discord.Embed{embed}, Components: &components})
+	}
+}
+
+// docsStateID returns the id the state of the docs message is stored with.
+func docsStateID(e *handler.ComponentEvent) snowflake.ID {
+	if e.Message.Interaction != nil {
+		return e.Message.Interaction.ID
+	}
+	return e.Message.ID
+}
+
+// getDocsState returns the stored state of the docs message or restores it from the embed title if it has expired.
+func getDocsState(b *butler.Butler, e *handler.ComponentEvent) butler.DocsState {
+	if state, ok := b.DocsStates.Get(docsStateID(e)); ok {
+		return state
+	}
+	return butler.DocsStateFromTitle(e.Message.Embeds[0].Title)
+}
+
+// updateDocs renders the docs message for the new state. Users which did not create the message get an ephemeral
+// copy instead, so they don't change the message for everyone else.
+func updateDocs(b *butler.Butler, e *handler.ComponentEvent, state butler.DocsState) error {
+	pkg, err := b.DocClient.Search(context.Background(), state.SearchQuery())
+	if err != nil {
+		return common.RespondErrMessagef(e.Respond, "Error while fetching package: %s", err)
+	}
+
+	embed, components := b.DocsEmbed(pkg, &state)
+	if e.Message.Interaction.User.ID != e.User().ID && e.Member().Permissions.Missing(discord.PermissionManageMessages) {
+		return e.CreateMessage(discord.MessageCreate{Embeds: []discord.Embed{embed}, Flags: discord.MessageFlagEphemeral})
+	}
+	b.DocsStates.Set(docsStateID(e), state)
+	return e.UpdateMessage(discord.MessageUpdate{Embeds: &[]discord.Embed{embed}, Components: &components})
+}
+
+// runDocsExample runs the example with the given index and responds with its output in a new message.
+func runDocsExample(b *butler.Butler, e *handler.ComponentEvent, state butler.DocsState, index string) error {
+	pkg, err := b.DocClient.Search(context.Background(), state.SearchQuery())
+	if err != nil {
+		return common.RespondErrMessagef(e.Respond, "Error while fetching package: %s", err)
+	}
+	examples := butler.DocsExamples(pkg, state.Query)
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i >= len(examples) {
+		return common.RespondErrMessage(e.Respond, "This example does not exist anymore.")
+	}
+
+	ticket, err := b.EvalQueue.Enqueue(e.User().ID, e.ChannelID())
+	if err != nil {
+		return common.RespondErr(e.Respond, err)
+	}
+	if err = e.DeferCreateMessage(false); err != nil {
+		ticket.Done()
+		return err
+	}
+
+	// wait in the background, so the next interaction can be handled while the example waits in the queue
+	client, interaction := e.Client(), e.ComponentInteraction
+	ticket.Go(func() {
+		if err := commands.WaitForEval(client, interaction, ticket); err != nil {
+			return
+		}
+		output, err := b.RunExample(pkg, examples[i])
+		if _, err = client.Rest().UpdateInteractionResponse(interaction.ApplicationID(), interaction.Token(), discord.MessageUpdate{
+			Embeds: &[]discord.Embed{butler.GetExampleEmbed(pkg, examples[i], output, err)},
+		}); err != nil {
+			client.Logger().Errorf("Failed to send example result: %s", err)
+		}
+	})
+	return nil
+}
@@ -2,9 +2,11 @@ package components
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/disgoorg/disgo-butler/butler"
 	"github.com/disgoorg/disgo-butler/commands"
+	"github.com/disgoorg/disgo-butler/common"
 	"github.com/disgoorg/disgo/discord"
 	"github.com/disgoorg/disgo/handler"
 	"github.com/disgoorg/snowflake/v2"
@@ -16,13 +18,85 @@ func HandleEvalRerunAction(b *butler.Butler) handler.ComponentHandler {
 		if e.Message.Interaction.User.ID != e.User().ID {
 			return e.CreateMessage(discord.MessageCreate{Content: "You can only rerun your own evals", Flags: discord.MessageFlagEphemeral})
 		}
-		message, err := e.Client().Rest().GetMessage(e.ChannelID(), snowflake.MustParse(e.Variables["message_id"]))
+		messageID := snowflake.MustParse(e.Variables["message_id"])
+		if messageID == 0 {
+			input, ok := butler.EvalInputFromEmbed(e.Message.Embeds[0])
+			if !ok {
+				return common.RespondErrMessage(e.Respond, "Failed to find the code of this eval.")
+			}
+			input.Mode = evalMode(e)
+			return commands.RunEval(b, e.Client(), e.ComponentInteraction, e.Respond, input, true, 0)
+		}
+		message, err := e.Client().Rest().GetMessage(e.ChannelID(), messageID)
 		if err != nil {
 			return err
 		}
 
 		fmt.Printf("HandleEvalRerunAction: %#v\n", e.Message)
-		return commands.Eval(b, e.Client(), e.BaseInteraction, e.Respond, message.Content, message.ID, true)
+		return commands.Eval(b, e.Client(), e.ComponentInteraction, e.Respond, message.Content, message.ID, true, evalSelection(e), evalMode(e))
+	}
+}
+
+// HandleEvalSelectAction runs the code block which was selected from a message with multiple code blocks.
+func HandleEvalSelectAction(b *butler.Butler) handler.ComponentHandler {
+	return func(e *handler.ComponentEvent) error {
+		if e.Message.Interaction.User.ID != e.User().ID {
+			return e.CreateMessage(discord.MessageCreate{Content: "You can only select code blocks of your own evals", Flags: discord.MessageFlagEphemeral})
+		}
+		messageID := snowflake.MustParse(e.Variables["message_id"])
+		message, err := e.Client().Rest().GetMessage(e.ChannelID(), messageID)
+		if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to get the message of this eval: %s", err)
+		}
+		return commands.Eval(b, e.Client(), e.ComponentInteraction, e.Respond, message.Content, message.ID, true, e.StringSelectMenuInteractionData().Values[0], butler.EvalModeAuto)
+	}
+}
+
+// evalSelection returns the selection of code blocks from custom IDs like eval/rerun/{message_id}/{selection}.
+// Buttons of older evals don't have a selection. The handler router panics on paths which are shorter than the
+// pattern, so the selection is not part of the route.
+func evalSelection(e *handler.ComponentEvent) string {
+	if parts := strings.Split(e.Data.CustomID(), "/"); len(parts) > 3 {
+		return parts[3]
+	}
+	return commands.EvalSelectionAuto
+}
+
+// evalMode returns the mode from custom IDs like eval/rerun/{message_id}/{selection}/{mode}.
+func evalMode(e *handler.ComponentEvent) butler.EvalMode {
+	if parts := strings.Split(e.Data.CustomID(), "/"); len(parts) > 4 {
+		return butler.ParseEvalMode(parts[4])
+	}
+	return butler.EvalModeAuto
+}
+
+func HandleEvalEditAction(b *butler.Butler) handler.ComponentHandler {
+	return func(e *handler.ComponentEvent) error {
+		if e.Message.Interaction.User.ID != e.User().ID {
+			return e.CreateMessage(discord.MessageCreate{Content: "You can only edit your own evals", Flags: discord.MessageFlagEphemeral})
+		}
+		var input butler.EvalInput
+		if messageID := snowflake.MustParse(e.Variables["message_id"]); messageID == 0 {
+			var ok bool
+			if input, ok = butler.EvalInputFromEmbed(e.Message.Embeds[0]); !ok {
+				return common.RespondErrMessage(e.Respond, "Failed to find the code of this eval.")
+			}
+		} else {
+			message, err := e.Client().Rest().GetMessage(e.ChannelID(), messageID)
+			if err != nil {
+				return common.RespondMessageErr(e.Respond, "Failed to get the message of this eval: %s", err)
+			}
+			if input, err = commands.EvalInputFromMessage(b, message.Content, evalSelection(e)); err != nil {
+				return common.RespondErr(e.Respond, err)
+			}
+			if len(input.Files) > 1 {
+				return common.RespondErrMessage(e.Respond, "Evals with multiple files can only be edited in their message.")
+			}
+			if !commands.FitsEvalModal(input) {
+				return common.RespondErrMessage(e.Respond, "This eval is too long for the editor, edit it in its message instead.")
+			}
+		}
+		return e.CreateModal(commands.NewEvalModal("eval_modal/update", input))
 	}
 }
 
@@ -10,6 +10,13 @@ import (
 	"github.com/uptrace/bun/extra/bundebug"
 )
 
+// migrations add the columns of new features to tables which were created by older versions. They run on every
+// start, so every statement has to be safe to run again.
+var migrations = []string{
+	"ALTER TABLE IF EXISTS tags ADD COLUMN IF NOT EXISTS embed jsonb, ADD COLUMN IF NOT EXISTS buttons jsonb",
+	"ALTER TABLE IF EXISTS tag_revisions ADD COLUMN IF NOT EXISTS embed jsonb, ADD COLUMN IF NOT EXISTS buttons jsonb",
+}
+
 type Config struct {
 	Address  string `json:"address"`
 	User     string `json:"user"`
@@ -31,10 +38,25 @@ func SetupDatabase(shouldSyncDBTables bool, config Config) (DB, error) {
 	db.AddQueryHook(bundebug.NewQueryHook(bundebug.WithVerbose(config.Verbose)))
 
 	if shouldSyncDBTables {
-		if _, err := db.NewCreateTable().Model((*Tag)(nil)).Exec(context.TODO()); err != nil {
+		// tables which already exist are kept, so syncing adds the tables of new features to an existing database
+		for _, model := range []any{
+			(*Tag)(nil),
+			(*TagRevision)(nil),
+			(*TagAttachment)(nil),
+			(*TagAlias)(nil),
+			(*TagUse)(nil),
+			(*Contributor)(nil),
+		} {
+			if _, err := db.NewCreateTable().Model(model).IfNotExists().Exec(context.TODO()); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := db.NewCreateIndex().Model((*TagUse)(nil)).Index("tag_uses_guild_id_used_at_idx").Column("guild_id", "used_at").IfNotExists().Exec(context.TODO()); err != nil {
 			return nil, err
 		}
-		if _, err := db.NewCreateTable().Model((*Contributor)(nil)).Exec(context.TODO()); err != nil {
+	}
+	for _, migration := range migrations {
+		if _, err := db.ExecContext(context.TODO(), migration); err != nil {
 			return nil, err
 		}
 	}
@@ -44,14 +66,18 @@ func SetupDatabase(shouldSyncDBTables bool, config Config) (DB, error) {
 
 type DB interface {
 	TagsDB
+	TagUsesDB
 	ContributorsDB
 	Close()
 }
 
+// sqlDB runs the queries on db, which is either the database or a transaction.
 type sqlDB struct {
-	db *bun.DB
+	db bun.IDB
 }
 
 func (s *sqlDB) Close() {
-	s.db.Close()
+	if db, ok := s.db.(*bun.DB); ok {
+		db.Close()
+	}
 }
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/disgoorg/snowflake/v2"
+)
+
+// TagUse is a single time a tag was sent.
+type TagUse struct {
+	ID        int64        `bun:"id,pk,autoincrement"`
+	GuildID   snowflake.ID `bun:"guild_id,notnull"`
+	TagName   string       `bun:"tag_name,notnull"`
+	UserID    snowflake.ID `bun:"user_id,notnull"`
+	ChannelID snowflake.ID `bun:"channel_id,notnull"`
+	UsedAt    time.Time    `bun:"used_at,notnull,default:current_timestamp"`
+}
+
+// TagUseCount is how often a tag was used.
+type TagUseCount struct {
+	TagName string `bun:"tag_name"`
+	Uses    int    `bun:"uses"`
+}
+
+// TagUserUseCount is how often a user used tags.
+type TagUserUseCount struct {
+	UserID snowflake.ID `bun:"user_id"`
+	Uses   int          `bun:"uses"`
+}
+
+// TagDailyUses is how often a tag was used on a day in UTC.
+type TagDailyUses struct {
+	Day  time.Time `bun:"day"`
+	Uses int       `bun:"uses"`
+}
+
+type TagUsesDB interface {
+	GetTagUseCounts(guildID snowflake.ID, from time.Time, to time.Time) ([]TagUseCount, error)
+	GetTagUserUseCounts(guildID snowflake.ID, from time.Time, to time.Time, limit int) ([]TagUserUseCount, error)
+	GetTagDailyUses(guildID snowflake.ID, tagName string, since time.Time) ([]TagDailyUses, error)
+	GetUnusedTags(guildID snowflake.ID, since time.Time) ([]Tag, error)
+}
+
+// GetTagUseCounts returns how often each tag was used between from and to, starting with the most used tag.
+func (s *sqlDB) GetTagUseCounts(guildID snowflake.ID, from time.Time, to time.Time) (counts []TagUseCount, err error) {
+	err = s.db.NewSelect().
+		Model((*TagUse)(nil)).
+		Column("tag_name").
+		ColumnExpr("COUNT(*) AS uses").
+		Where("guild_id = ? AND used_at >= ? AND used_at < ?", guildID, from, to).
+		Group("tag_name").
+		Order("uses DESC", "tag_name").
+		Scan(context.TODO(), &counts)
+	return
+}
+
+// GetTagUserUseCounts returns the users which used the most tags between from and to.
+func (s *sqlDB) GetTagUserUseCounts(guildID snowflake.ID, from time.Time, to time.Time, limit int) (counts []TagUserUseCount, err error) {
+	err = s.db.NewSelect().
+		Model((*TagUse)(nil)).
+		Column("user_id").
+		ColumnExpr("COUNT(*) AS uses").
+		Where("guild_id = ? AND used_at >= ? AND used_at < ?", guildID, from, to).
+		Group("user_id").
+		Order("uses DESC").
+		Limit(limit).
+		Scan(context.TODO(), &counts)
+	return
+}
+
+// GetTagDailyUses returns the uses of the tag per day since the time. Days without uses are left out.
+func (s *sqlDB) GetTagDailyUses(guildID snowflake.ID, tagName string, since time.Time) (uses []TagDailyUses, err error) {
+	err = s.db.NewSelect().
+		Model((*TagUse)(nil)).
+		ColumnExpr("date_trunc('day', used_at AT TIME ZONE 'UTC') AS day").
+		ColumnExpr("COUNT(*) AS uses").
+		Where("guild_id = ? AND tag_name = ? AND used_at >= ?", guildID, tagName, since).
+		Group("day").
+		Order("day").
+		Scan(context.TODO(), &uses)
+	return
+}
+
+// GetUnusedTags returns the tags which were created before the time and have not been used since.
+func (s *sqlDB) GetUnusedTags(guildID snowflake.ID, since time.Time) (tags []Tag, err error) {
+	err = s.db.NewSelect().
+		Model(&tags).
+		Where("guild_id = ? AND created_at < ?", guildID, since).
+		Where("NOT EXISTS (SELECT 1 FROM tag_uses WHERE tag_uses.guild_id = tag.guild_id AND tag_uses.tag_name = tag.name AND tag_uses.used_at >= ?)", since).
+		Order("name").
+		Scan(context.TODO())
+	return
+}
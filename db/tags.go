@@ -5,45 +5,148 @@ import (
 	"time"
 
 	"github.com/disgoorg/snowflake/v2"
+	"github.com/uptrace/bun"
 )
 
 type TagsDB interface {
 	Get(guildID snowflake.ID, name string) (Tag, error)
-	GetAndIncrement(guildID snowflake.ID, name string) (Tag, error)
+	GetAndIncrement(guildID snowflake.ID, name string, userID snowflake.ID, channelID snowflake.ID) (Tag, error)
 	GetAll(guildID snowflake.ID) ([]Tag, error)
 	GetAllUser(guildID snowflake.ID, userID snowflake.ID) ([]Tag, error)
-	Create(guildID snowflake.ID, ownerID snowflake.ID, name string, content string) error
-	Edit(guildID snowflake.ID, name string, content string) error
+	Create(guildID snowflake.ID, ownerID snowflake.ID, name string, body TagBody) error
+	Edit(guildID snowflake.ID, name string, body TagBody, editorID snowflake.ID) error
 	Delete(guildID snowflake.ID, name string) error
+	Transfer(guildID snowflake.ID, name string, ownerID snowflake.ID) error
+
+	GetAttachments(guildID snowflake.ID, tagName string) ([]TagAttachment, error)
+	SetAttachment(attachment TagAttachment) error
+	DeleteAttachment(guildID snowflake.ID, tagName string, filename string) error
+
+	GetRevisions(guildID snowflake.ID, name string) ([]TagRevision, error)
+	GetRevision(guildID snowflake.ID, name string, revision int) (TagRevision, error)
+
+	GetAlias(guildID snowflake.ID, name string) (TagAlias, error)
+	GetAliases(guildID snowflake.ID, tagName string) ([]TagAlias, error)
+	GetAllAliases(guildID snowflake.ID) ([]TagAlias, error)
+	CreateAlias(guildID snowflake.ID, name string, tagName string) error
+	DeleteAlias(guildID snowflake.ID, name string) error
+
+	// RunInTx runs f in a transaction, which is rolled back if f returns an error. The TagsDB passed to f has to be
+	// used for every query of the transaction.
+	RunInTx(f func(tagsDB TagsDB) error) error
 }
 
 type Tag struct {
 	GuildID   snowflake.ID `bun:"guild_id,pk"`
 	Name      string       `bun:"name,pk"`
 	Content   string       `bun:"content,notnull"`
+	Embed     *TagEmbed    `bun:"embed,type:jsonb"`
+	Buttons   []TagButton  `bun:"buttons,type:jsonb"`
 	OwnerID   snowflake.ID `bun:"owner_id,notnull"`
 	Uses      int          `bun:"uses,notnull"`
 	CreatedAt time.Time    `bun:"created_at,notnull,default:current_timestamp"`
 	UpdatedAt time.Time    `bun:"updated_at,notnull,default:current_timestamp"`
 }
 
+func (t Tag) Body() TagBody {
+	return TagBody{Content: t.Content, Embed: t.Embed, Buttons: t.Buttons}
+}
+
+// TagBody is the editable message of a tag.
+type TagBody struct {
+	Content string
+	Embed   *TagEmbed
+	Buttons []TagButton
+}
+
+// TagEmbed is the optional embed of a tag.
+type TagEmbed struct {
+	Title       string          `json:"title,omitempty" yaml:"title,omitempty"`
+	URL         string          `json:"url,omitempty" yaml:"url,omitempty"`
+	Description string          `json:"description,omitempty" yaml:"description,omitempty"`
+	Color       int             `json:"color,omitempty" yaml:"color,omitempty"`
+	Image       string          `json:"image,omitempty" yaml:"image,omitempty"`
+	Fields      []TagEmbedField `json:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+type TagEmbedField struct {
+	Name   string `json:"name" yaml:"name"`
+	Value  string `json:"value" yaml:"value"`
+	Inline bool   `json:"inline,omitempty" yaml:"inline,omitempty"`
+}
+
+// TagButton is a link button of a tag.
+type TagButton struct {
+	Label string `json:"label" yaml:"label"`
+	URL   string `json:"url" yaml:"url"`
+}
+
+// TagAttachment is a file which is sent with a tag. The file is stored, so it doesn't depend on the message it was
+// uploaded with.
+type TagAttachment struct {
+	GuildID  snowflake.ID `bun:"guild_id,pk"`
+	TagName  string       `bun:"tag_name,pk"`
+	Filename string       `bun:"filename,pk"`
+	Data     []byte       `bun:"data,notnull"`
+}
+
+// TagRevision is a version of the message of a tag. Revisions are numbered from 1 for every tag.
+type TagRevision struct {
+	GuildID   snowflake.ID `bun:"guild_id,pk"`
+	TagName   string       `bun:"tag_name,pk"`
+	Revision  int          `bun:"revision,pk"`
+	Content   string       `bun:"content,notnull"`
+	Embed     *TagEmbed    `bun:"embed,type:jsonb"`
+	Buttons   []TagButton  `bun:"buttons,type:jsonb"`
+	EditorID  snowflake.ID `bun:"editor_id,notnull"`
+	CreatedAt time.Time    `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+func (r TagRevision) Body() TagBody {
+	return TagBody{Content: r.Content, Embed: r.Embed, Buttons: r.Buttons}
+}
+
+// TagAlias is an additional name of a tag.
+type TagAlias struct {
+	GuildID snowflake.ID `bun:"guild_id,pk"`
+	Name    string       `bun:"name,pk"`
+	TagName string       `bun:"tag_name,notnull"`
+}
+
+// resolvedName is the condition to find a tag by its name or one of its aliases.
+const resolvedName = "name = COALESCE((SELECT tag_name FROM tag_aliases WHERE guild_id = ? AND name = ?), ?)"
+
+// Get returns the tag with the given name or alias.
 func (s *sqlDB) Get(guildID snowflake.ID, name string) (tag Tag, err error) {
 	err = s.db.NewSelect().
 		Model(&tag).
 		Where("guild_id = ?", guildID).
-		Where("name = ?", name).
+		Where(resolvedName, guildID, name, name).
 		Scan(context.TODO())
 	return
 }
 
-func (s *sqlDB) GetAndIncrement(guildID snowflake.ID, name string) (tag Tag, err error) {
-	_, err = s.db.NewUpdate().
-		Model(&tag).
-		Set("uses = uses+?", 1).
-		Where("guild_id = ?", guildID).
-		Where("name = ?", name).
-		Returning("content").
-		Exec(context.TODO())
+// GetAndIncrement returns the tag with the given name or alias, counts the use against the tag and records who used
+// it where.
+func (s *sqlDB) GetAndIncrement(guildID snowflake.ID, name string, userID snowflake.ID, channelID snowflake.ID) (tag Tag, err error) {
+	err = s.db.RunInTx(context.TODO(), nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewUpdate().
+			Model(&tag).
+			Set("uses = uses+?", 1).
+			Where("guild_id = ?", guildID).
+			Where(resolvedName, guildID, name, name).
+			Returning("*").
+			Exec(ctx); err != nil {
+			return err
+		}
+		_, err := tx.NewInsert().Model(&TagUse{
+			GuildID:   guildID,
+			TagName:   tag.Name,
+			UserID:    userID,
+			ChannelID: channelID,
+		}).Exec(ctx)
+		return err
+	})
 	return
 }
 
@@ -63,22 +166,199 @@ func (s *sqlDB) GetAllUser(guildID snowflake.ID, userID snowflake.ID) (tags []Ta
 	return
 }
 
-func (s *sqlDB) Create(guildID snowflake.ID, ownerID snowflake.ID, name string, content string) (err error) {
-	_, err = s.db.NewInsert().Model(&Tag{
+// Create creates the tag and stores its message as the first revision.
+func (s *sqlDB) Create(guildID snowflake.ID, ownerID snowflake.ID, name string, body TagBody) (err error) {
+	return s.db.RunInTx(context.TODO(), nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(&Tag{
+			GuildID: guildID,
+			Name:    name,
+			OwnerID: ownerID,
+			Content: body.Content,
+			Embed:   body.Embed,
+			Buttons: body.Buttons,
+		}).Exec(ctx); err != nil {
+			return err
+		}
+		_, err := tx.NewInsert().Model(&TagRevision{
+			GuildID:  guildID,
+			TagName:  name,
+			Revision: 1,
+			Content:  body.Content,
+			Embed:    body.Embed,
+			Buttons:  body.Buttons,
+			EditorID: ownerID,
+		}).Exec(ctx)
+		return err
+	})
+}
+
+// Edit replaces the message of the tag and stores it as a new revision. Tags which were created before revisions
+// existed get their previous message stored as the first revision.
+func (s *sqlDB) Edit(guildID snowflake.ID, name string, body TagBody, editorID snowflake.ID) (err error) {
+	return s.db.RunInTx(context.TODO(), nil, func(ctx context.Context, tx bun.Tx) error {
+		var tag Tag
+		if err := tx.NewSelect().Model(&tag).Where("guild_id = ? AND name = ?", guildID, name).For("UPDATE").Scan(ctx); err != nil {
+			return err
+		}
+		var revision int
+		if err := tx.NewSelect().Model((*TagRevision)(nil)).ColumnExpr("COALESCE(MAX(revision), 0)").Where("guild_id = ? AND tag_name = ?", guildID, name).Scan(ctx, &revision); err != nil {
+			return err
+		}
+		if revision == 0 {
+			revision++
+			if _, err := tx.NewInsert().Model(&TagRevision{
+				GuildID:   guildID,
+				TagName:   name,
+				Revision:  revision,
+				Content:   tag.Content,
+				Embed:     tag.Embed,
+				Buttons:   tag.Buttons,
+				EditorID:  tag.OwnerID,
+				CreatedAt: tag.UpdatedAt,
+			}).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.NewInsert().Model(&TagRevision{
+			GuildID:  guildID,
+			TagName:  name,
+			Revision: revision + 1,
+			Content:  body.Content,
+			Embed:    body.Embed,
+			Buttons:  body.Buttons,
+			EditorID: editorID,
+		}).Exec(ctx); err != nil {
+			return err
+		}
+		_, err := tx.NewUpdate().
+			Model(&Tag{
+				GuildID:   guildID,
+				Name:      name,
+				Content:   body.Content,
+				Embed:     body.Embed,
+				Buttons:   body.Buttons,
+				UpdatedAt: time.Now(),
+			}).
+			Column("content", "embed", "buttons", "updated_at").
+			WherePK().
+			Exec(ctx)
+		return err
+	})
+}
+
+// GetRevisions returns all revisions of the tag, the newest first.
+func (s *sqlDB) GetRevisions(guildID snowflake.ID, name string) (revisions []TagRevision, err error) {
+	err = s.db.NewSelect().
+		Model(&revisions).
+		Where("guild_id = ? AND tag_name = ?", guildID, name).
+		Order("revision DESC").
+		Scan(context.TODO())
+	return
+}
+
+func (s *sqlDB) GetRevision(guildID snowflake.ID, name string, revision int) (tagRevision TagRevision, err error) {
+	err = s.db.NewSelect().
+		Model(&tagRevision).
+		Where("guild_id = ? AND tag_name = ? AND revision = ?", guildID, name, revision).
+		Scan(context.TODO())
+	return
+}
+
+// Delete deletes the tag with all of its aliases and revisions.
+func (s *sqlDB) Delete(guildID snowflake.ID, name string) (err error) {
+	return s.db.RunInTx(context.TODO(), nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Model((*TagAlias)(nil)).Where("guild_id = ? AND tag_name = ?", guildID, name).Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := tx.NewDelete().Model((*TagRevision)(nil)).Where("guild_id = ? AND tag_name = ?", guildID, name).Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := tx.NewDelete().Model((*TagAttachment)(nil)).Where("guild_id = ? AND tag_name = ?", guildID, name).Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := tx.NewDelete().Model((*TagUse)(nil)).Where("guild_id = ? AND tag_name = ?", guildID, name).Exec(ctx); err != nil {
+			return err
+		}
+		_, err := tx.NewDelete().Model((*Tag)(nil)).Where(" guild_id = ? AND name = ?", guildID, name).Exec(ctx)
+		return err
+	})
+}
+
+// Transfer makes the user the owner of the tag.
+func (s *sqlDB) Transfer(guildID snowflake.ID, name string, ownerID snowflake.ID) (err error) {
+	_, err = s.db.NewUpdate().
+		Model(&Tag{GuildID: guildID, Name: name, OwnerID: ownerID}).
+		Column("owner_id").
+		WherePK().
+		Exec(context.TODO())
+	return
+}
+
+func (s *sqlDB) GetAttachments(guildID snowflake.ID, tagName string) (attachments []TagAttachment, err error) {
+	err = s.db.NewSelect().
+		Model(&attachments).
+		Where("guild_id = ? AND tag_name = ?", guildID, tagName).
+		Order("filename").
+		Scan(context.TODO())
+	return
+}
+
+// SetAttachment adds the attachment to its tag or replaces the attachment with the same filename.
+func (s *sqlDB) SetAttachment(attachment TagAttachment) (err error) {
+	_, err = s.db.NewInsert().
+		Model(&attachment).
+		On("CONFLICT (guild_id, tag_name, filename) DO UPDATE").
+		Set("data = EXCLUDED.data").
+		Exec(context.TODO())
+	return
+}
+
+func (s *sqlDB) DeleteAttachment(guildID snowflake.ID, tagName string, filename string) (err error) {
+	_, err = s.db.NewDelete().Model((*TagAttachment)(nil)).Where("guild_id = ? AND tag_name = ? AND filename = ?", guildID, tagName, filename).Exec(context.TODO())
+	return
+}
+
+func (s *sqlDB) GetAlias(guildID snowflake.ID, name string) (alias TagAlias, err error) {
+	err = s.db.NewSelect().
+		Model(&alias).
+		Where("guild_id = ? AND name = ?", guildID, name).
+		Scan(context.TODO())
+	return
+}
+
+func (s *sqlDB) GetAliases(guildID snowflake.ID, tagName string) (aliases []TagAlias, err error) {
+	err = s.db.NewSelect().
+		Model(&aliases).
+		Where("guild_id = ? AND tag_name = ?", guildID, tagName).
+		Order("name").
+		Scan(context.TODO())
+	return
+}
+
+func (s *sqlDB) GetAllAliases(guildID snowflake.ID) (aliases []TagAlias, err error) {
+	err = s.db.NewSelect().
+		Model(&aliases).
+		Where("guild_id = ?", guildID).
+		Scan(context.TODO())
+	return
+}
+
+func (s *sqlDB) CreateAlias(guildID snowflake.ID, name string, tagName string) (err error) {
+	_, err = s.db.NewInsert().Model(&TagAlias{
 		GuildID: guildID,
 		Name:    name,
-		OwnerID: ownerID,
-		Content: content,
+		TagName: tagName,
 	}).Exec(context.TODO())
 	return
 }
 
-func (s *sqlDB) Edit(guildID snowflake.ID, name string, content string) (err error) {
-	_, err = s.db.NewUpdate().Model((*Tag)(nil)).Set("content = ?", content).Where(" guild_id = ? AND name = ?", guildID, name).Exec(context.TODO())
+func (s *sqlDB) DeleteAlias(guildID snowflake.ID, name string) (err error) {
+	_, err = s.db.NewDelete().Model((*TagAlias)(nil)).Where("guild_id = ? AND name = ?", guildID, name).Exec(context.TODO())
 	return
 }
 
-func (s *sqlDB) Delete(guildID snowflake.ID, name string) (err error) {
-	_, err = s.db.NewDelete().Model((*Tag)(nil)).Where(" guild_id = ? AND name = ?", guildID, name).Exec(context.TODO())
-	return
+func (s *sqlDB) RunInTx(f func(tagsDB TagsDB) error) error {
+	return s.db.RunInTx(context.TODO(), nil, func(ctx context.Context, tx bun.Tx) error {
+		return f(&sqlDB{db: tx})
+	})
 }
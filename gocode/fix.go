@@ -0,0 +1,180 @@
+package gocode
+
+import (
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// versionRegex matches the major version suffix of module paths like v2.
+var versionRegex = regexp.MustCompile(`^v[0-9]+$`)
+
+// Fix turns a snippet into a program. Type and function declarations stay at the top level while all other
+// statements are wrapped in a main function. Missing standard library imports are added and unused imports are
+// removed.
+func Fix(code string) (string, error) {
+	if !IsProgram(code) {
+		decls, stmts := SplitDecls(code)
+		var sb strings.Builder
+		sb.WriteString("package main\n\n")
+		sb.WriteString(decls)
+		if strings.TrimSpace(stmts) != "" || !hasMain(decls) {
+			sb.WriteString("\nfunc main() {\n" + stmts + "\n}\n")
+		}
+		code = sb.String()
+	}
+	return FixImports(code, nil)
+}
+
+// SplitDecls separates the import, type and function declarations of a snippet from its statements. Declarations
+// are recognized by starting at the beginning of a line and end with the first line which closes them.
+func SplitDecls(code string) (string, string) {
+	var decls, stmts strings.Builder
+	lines := strings.Split(code, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if !isDeclStart(line) {
+			stmts.WriteString(line + "\n")
+			continue
+		}
+		decls.WriteString(line + "\n")
+		end := declEnd(line)
+		if end == "" {
+			continue
+		}
+		for i+1 < len(lines) {
+			i++
+			decls.WriteString(lines[i] + "\n")
+			if strings.TrimRight(lines[i], " \t\r") == end {
+				break
+			}
+		}
+	}
+	return decls.String(), strings.Trim(stmts.String(), "\n")
+}
+
+func isDeclStart(line string) bool {
+	for _, keyword := range []string{"func ", "type ", "import ", "import("} {
+		if strings.HasPrefix(line, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// declEnd returns the line which closes the declaration starting with line or "" if it is a single line.
+func declEnd(line string) string {
+	line = strings.TrimRight(line, " \t\r")
+	switch {
+	case strings.HasSuffix(line, "{"):
+		return "}"
+	case strings.HasSuffix(line, "("):
+		return ")"
+	}
+	return ""
+}
+
+func hasMain(decls string) bool {
+	for _, line := range strings.Split(decls, "\n") {
+		if strings.HasPrefix(line, "func main()") {
+			return true
+		}
+	}
+	return false
+}
+
+// FixImports adds the imports of all packages the file uses but doesn't import and removes unused imports. imports
+// maps package names to import paths and takes precedence over the standard library.
+func FixImports(code string, imports map[string]string) (string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "main.go", code, parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+
+	used := map[string]struct{}{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Obj == nil {
+				used[x.Name] = struct{}{}
+			}
+		}
+		return true
+	})
+
+	var specs []string
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		name := ImportName(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if _, ok := used[name]; !ok && name != "_" && name != "." {
+			continue
+		}
+		if spec.Name != nil {
+			specs = append(specs, spec.Name.Name+" "+spec.Path.Value)
+		} else {
+			specs = append(specs, spec.Path.Value)
+		}
+	}
+	for _, name := range Qualifiers(file) {
+		importPath, ok := imports[name]
+		if !ok {
+			if importPath, ok = stdImportPath(name); !ok {
+				continue
+			}
+		}
+		specs = append(specs, strconv.Quote(importPath))
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		return specPath(specs[i]) < specPath(specs[j])
+	})
+
+	// replace the import declarations with a single sorted one
+	var sb strings.Builder
+	offset := 0
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			sb.WriteString(code[offset:fset.Position(gen.Pos()).Offset])
+			offset = fset.Position(gen.End()).Offset
+		}
+	}
+	sb.WriteString(code[offset:])
+	src := sb.String()
+
+	packageEnd := fset.Position(file.Name.End()).Offset
+	switch len(specs) {
+	case 0:
+	case 1:
+		src = src[:packageEnd] + "\n\nimport " + specs[0] + "\n" + src[packageEnd:]
+	default:
+		src = src[:packageEnd] + "\n\nimport (\n\t" + strings.Join(specs, "\n\t") + "\n)\n" + src[packageEnd:]
+	}
+
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		return "", err
+	}
+	return string(formatted), nil
+}
+
+// ImportName guesses the package name of an import path from its last element.
+func ImportName(importPath string) string {
+	elements := strings.Split(importPath, "/")
+	name := elements[len(elements)-1]
+	if versionRegex.MatchString(name) && len(elements) > 1 {
+		name = elements[len(elements)-2]
+	}
+	return strings.TrimPrefix(name, "go-")
+}
+
+// specPath returns the quoted path of an import spec which may be named.
+func specPath(spec string) string {
+	return spec[strings.Index(spec, `"`):]
+}
@@ -0,0 +1,139 @@
+package gocode
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestFix(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected string
+	}{
+		{
+			name: "statements",
+			code: `fmt.Println(strings.Repeat("a", 3))`,
+			expected: `package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func main() {
+	fmt.Println(strings.Repeat("a", 3))
+}
+`,
+		},
+		{
+			name: "declarations and statements",
+			code: `type point struct {
+	x, y int
+}
+
+func (p point) String() string {
+	return fmt.Sprintf("(%d, %d)", p.x, p.y)
+}
+
+p := point{1, 2}
+fmt.Println(p)`,
+			expected: `package main
+
+import "fmt"
+
+type point struct {
+	x, y int
+}
+
+func (p point) String() string {
+	return fmt.Sprintf("(%d, %d)", p.x, p.y)
+}
+
+func main() {
+	p := point{1, 2}
+	fmt.Println(p)
+}
+`,
+		},
+		{
+			name: "program with unused and missing imports",
+			code: `package main
+
+import (
+	"os"
+	_ "embed"
+)
+
+func main() {
+	fmt.Println(time.Now().IsZero())
+}`,
+			expected: `package main
+
+import (
+	_ "embed"
+	"fmt"
+	"time"
+)
+
+func main() {
+	fmt.Println(time.Now().IsZero())
+}
+`,
+		},
+		{
+			name: "main without package",
+			code: `import "fmt"
+
+func main() {
+	fmt.Println("hi")
+}`,
+			expected: `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hi")
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixed, err := Fix(tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fixed != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, fixed)
+			}
+		})
+	}
+}
+
+func TestImportName(t *testing.T) {
+	for importPath, expected := range map[string]string{
+		"fmt":                                 "fmt",
+		"github.com/disgoorg/snowflake/v2":    "snowflake",
+		"github.com/milindmadhukar/go-piston": "piston",
+	} {
+		if name := ImportName(importPath); name != expected {
+			t.Errorf("expected %s for %s, got %s", expected, importPath, name)
+		}
+	}
+}
+
+func TestVet(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go tool not available")
+	}
+	diagnostics, err := Vet(context.Background(), "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Printf(\"%d\\n\", \"a\")\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diagnostics) != 1 || !strings.HasPrefix(diagnostics[0], "main.go:6:") || !strings.Contains(diagnostics[0], "fmt.Printf format %d has arg") {
+		t.Errorf("unexpected diagnostics %q", diagnostics)
+	}
+}
@@ -0,0 +1,226 @@
+package gocode
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BenchTime is how long each benchmark runs. It is shorter than the default of go test to stay within the run
+// timeout of evals.
+const BenchTime = "250ms"
+
+// TestFuncs returns the names of the test and benchmark functions declared in the code. The code may be a snippet
+// without package clause.
+func TestFuncs(code string) ([]string, []string) {
+	file, _, err := parseTests(code)
+	if err != nil {
+		return nil, nil
+	}
+	return testFuncs(file)
+}
+
+// OnlyTests reports whether the code declares tests or benchmarks but no main function or statements, so it is
+// meant to be run as tests.
+func OnlyTests(code string) bool {
+	if !IsProgram(code) {
+		if _, stmts := SplitDecls(code); strings.TrimSpace(stmts) != "" {
+			return false
+		}
+	}
+	file, _, err := parseTests(code)
+	if err != nil {
+		return false
+	}
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+			return false
+		}
+	}
+	tests, benchmarks := testFuncs(file)
+	return len(tests)+len(benchmarks) > 0
+}
+
+// WrapTests turns code with test and benchmark functions into a program which runs all of them like
+// go test -v -bench . -benchmem. A main function of the code is renamed to _ so it doesn't clash with the generated
+// one.
+func WrapTests(code string) (string, error) {
+	file, fset, err := parseTests(code)
+	if err != nil {
+		return "", err
+	}
+	tests, benchmarks := testFuncs(file)
+	if len(tests)+len(benchmarks) == 0 {
+		return "", fmt.Errorf("no test or benchmark functions found")
+	}
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+			fn.Name.Name = "_"
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = format.Node(&buf, fset, file); err != nil {
+		return "", err
+	}
+	buf.WriteString("\nfunc main() {\n")
+	buf.WriteString("\tos.Args = append(os.Args[:1], \"-test.v\", \"-test.bench=.\", \"-test.benchmem\", \"-test.benchtime=" + BenchTime + "\")\n")
+	buf.WriteString("\ttesting.Main(func(string, string) (bool, error) { return true, nil },\n")
+	buf.WriteString("\t\t[]testing.InternalTest{\n")
+	for _, name := range tests {
+		buf.WriteString("\t\t\t{Name: " + strconv.Quote(name) + ", F: " + name + "},\n")
+	}
+	buf.WriteString("\t\t},\n\t\t[]testing.InternalBenchmark{\n")
+	for _, name := range benchmarks {
+		buf.WriteString("\t\t\t{Name: " + strconv.Quote(name) + ", F: " + name + "},\n")
+	}
+	buf.WriteString("\t\t},\n\t\tnil,\n\t)\n}\n")
+	return FixImports(buf.String(), nil)
+}
+
+// parseTests parses the code as a file. Only the declarations of snippets are used, as their statements would
+// not be run anyway.
+func parseTests(code string) (*ast.File, *token.FileSet, error) {
+	if !IsProgram(code) {
+		if decls, stmts := SplitDecls(code); strings.TrimSpace(stmts) != "" {
+			code = decls
+		}
+		code = "package main\n\n" + code
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "main.go", code, parser.ParseComments)
+	return file, fset, err
+}
+
+func testFuncs(file *ast.File) ([]string, []string) {
+	var tests, benchmarks []string
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Type.Params == nil || len(fn.Type.Params.List) != 1 {
+			continue
+		}
+		switch name := fn.Name.Name; {
+		case isTestName(name, "Test"):
+			tests = append(tests, name)
+		case isTestName(name, "Benchmark"):
+			benchmarks = append(benchmarks, name)
+		}
+	}
+	return tests, benchmarks
+}
+
+// isTestName reports whether the name is the prefix followed by nothing or a character which isn't lower case, the
+// same way go test recognizes tests.
+func isTestName(name string, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if len(name) == len(prefix) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return !unicode.IsLower(r)
+}
+
+var (
+	testResultRegex = regexp.MustCompile(`^--- (PASS|FAIL|SKIP): (\S+) \(([0-9.]+s)\)$`)
+	benchmarkRegex  = regexp.MustCompile(`^(Benchmark\S*?)(?:-[0-9]+)?\s+([0-9]+)\s+([0-9.]+) ns/op(?:\s+([0-9]+) B/op)?(?:\s+([0-9]+) allocs/op)?`)
+	// benchmarkNameRegex matches the name of a benchmark which is printed on its own line if it logs something.
+	benchmarkNameRegex = regexp.MustCompile(`^Benchmark\S*$`)
+)
+
+// TestReport is the parsed output of the program created by WrapTests.
+type TestReport struct {
+	Tests      []TestResult
+	Benchmarks []BenchmarkResult
+	// Output is everything else which was printed, like the logs of failed tests.
+	Output string
+}
+
+// TestResult is the outcome of a test or subtest.
+type TestResult struct {
+	Name string
+	// Status is PASS, FAIL or SKIP.
+	Status   string
+	Duration string
+}
+
+// BenchmarkResult is the measurement of a benchmark. The memory statistics are empty if they were not reported.
+type BenchmarkResult struct {
+	Name        string
+	N           string
+	NsPerOp     string
+	BytesPerOp  string
+	AllocsPerOp string
+}
+
+// Passed returns the number of tests which passed and failed.
+func (r TestReport) Passed() (int, int) {
+	var passed, failed int
+	for _, test := range r.Tests {
+		switch test.Status {
+		case "PASS":
+			passed++
+		case "FAIL":
+			failed++
+		}
+	}
+	return passed, failed
+}
+
+// ParseTestOutput parses the verbose output of tests and benchmarks.
+func ParseTestOutput(output string) TestReport {
+	var (
+		report TestReport
+		other  strings.Builder
+	)
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if matches := testResultRegex.FindStringSubmatch(trimmed); matches != nil {
+			report.Tests = append(report.Tests, TestResult{
+				Name:     matches[2],
+				Status:   matches[1],
+				Duration: matches[3],
+			})
+			continue
+		}
+		if matches := benchmarkRegex.FindStringSubmatch(trimmed); matches != nil {
+			report.Benchmarks = append(report.Benchmarks, BenchmarkResult{
+				Name:        matches[1],
+				N:           matches[2],
+				NsPerOp:     matches[3],
+				BytesPerOp:  matches[4],
+				AllocsPerOp: matches[5],
+			})
+			continue
+		}
+		if isTestNoise(trimmed) {
+			continue
+		}
+		other.WriteString(line + "\n")
+	}
+	report.Output = strings.Trim(other.String(), "\n")
+	return report
+}
+
+// isTestNoise reports whether the line is printed by the testing package itself and is already part of the report.
+func isTestNoise(line string) bool {
+	switch line {
+	case "PASS", "FAIL", "testing: warning: no tests to run":
+		return true
+	}
+	for _, prefix := range []string{"=== RUN", "=== PAUSE", "=== CONT", "=== NAME", "goos: ", "goarch: ", "pkg: ", "cpu: "} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return benchmarkNameRegex.MatchString(line)
+}
@@ -0,0 +1,75 @@
+package gocode
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOnlyTests(t *testing.T) {
+	tests := map[string]bool{
+		"func TestFoo(t *testing.T) {}\n":                              true,
+		"func BenchmarkFoo(b *testing.B) {}\n":                         true,
+		"func Testing(t *testing.T) {}\n":                              false,
+		"func TestFoo(t *testing.T) {}\n\nfunc main() {}\n":            false,
+		"func TestFoo(t *testing.T) {}\nfmt.Println(1)":                false,
+		"package main\n\nfunc Test_foo(t *testing.T) {}\n":             true,
+		"package main\n\nfunc (s) TestFoo(t *testing.T) {}\n":          false,
+		"func TestFoo(t *testing.T) {}\n\nfunc TestBar(x, y int) {}\n": true,
+	}
+	for code, expected := range tests {
+		if actual := OnlyTests(code); actual != expected {
+			t.Errorf("OnlyTests(%q) = %t, expected %t", code, actual, expected)
+		}
+	}
+}
+
+func TestWrapTests(t *testing.T) {
+	code, err := WrapTests("func TestFoo(t *testing.T) {}\n\nfunc BenchmarkFoo(b *testing.B) {}\n\nfunc main() {}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`"os"`, `"testing"`, "func _() {}", `{Name: "TestFoo", F: TestFoo}`, `{Name: "BenchmarkFoo", F: BenchmarkFoo}`} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("expected %q in\n%s", expected, code)
+		}
+	}
+	if _, err = WrapTests("func foo() {}\n"); err == nil {
+		t.Error("expected error for code without tests")
+	}
+}
+
+func TestParseTestOutput(t *testing.T) {
+	output := `=== RUN   TestA
+    main.go:10: hi
+=== RUN   TestA/sub
+--- PASS: TestA (0.00s)
+    --- PASS: TestA/sub (0.00s)
+=== RUN   TestB
+--- FAIL: TestB (0.12s)
+goos: linux
+goarch: amd64
+cpu: Intel(R) Xeon(R) Processor
+BenchmarkJoin
+BenchmarkJoin-8 	 3536437	        85.72 ns/op	      16 B/op	       1 allocs/op
+FAIL
+`
+	expected := TestReport{
+		Tests: []TestResult{
+			{Name: "TestA", Status: "PASS", Duration: "0.00s"},
+			{Name: "TestA/sub", Status: "PASS", Duration: "0.00s"},
+			{Name: "TestB", Status: "FAIL", Duration: "0.12s"},
+		},
+		Benchmarks: []BenchmarkResult{
+			{Name: "BenchmarkJoin", N: "3536437", NsPerOp: "85.72", BytesPerOp: "16", AllocsPerOp: "1"},
+		},
+		Output: "    main.go:10: hi",
+	}
+	report := ParseTestOutput(output)
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("expected %+v, got %+v", expected, report)
+	}
+	if passed, failed := report.Passed(); passed != 2 || failed != 1 {
+		t.Errorf("expected 2 passed and 1 failed, got %d and %d", passed, failed)
+	}
+}
@@ -0,0 +1,68 @@
+package gocode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// vetTimeout is the maximum duration of go vet.
+const vetTimeout = 20 * time.Second
+
+// Vet runs go vet on the program and returns its diagnostics. It requires the go tool and only supports programs
+// which import nothing but the standard library.
+func Vet(ctx context.Context, code string) ([]string, error) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "butler-vet-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err = os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module main\n"), 0644); err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(filepath.Join(dir, "main.go"), []byte(code), 0644); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, vetTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, goBin, "vet", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, err
+	}
+	return vetDiagnostics(stderr.String()), nil
+}
+
+// vetDiagnostics extracts the diagnostics from the output of go vet and strips the temporary directory from them.
+func vetDiagnostics(output string) []string {
+	var diagnostics []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.Index(line, "main.go:"); i >= 0 {
+			line = line[i:]
+		}
+		diagnostics = append(diagnostics, line)
+	}
+	return diagnostics
+}
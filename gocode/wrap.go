@@ -0,0 +1,58 @@
+// Package gocode prepares Go snippets so they can be run as a program.
+package gocode
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// IsProgram reports whether the code already is a complete program with a package clause.
+func IsProgram(code string) bool {
+	file, err := parser.ParseFile(token.NewFileSet(), "", code, parser.PackageClauseOnly)
+	return err == nil && file.Name != nil
+}
+
+// WrapMain wraps the statements in a main function and imports all packages they use. imports maps package names
+// to import paths and takes precedence over the standard library.
+func WrapMain(code string, imports map[string]string) (string, error) {
+	return FixImports("package main\n\nfunc main() {\n"+code+"\n}\n", imports)
+}
+
+// Qualifiers returns the names of all packages which are referenced but not declared or imported in the file.
+func Qualifiers(file *ast.File) []string {
+	imported := map[string]struct{}{}
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		name := importPath[strings.LastIndex(importPath, "/")+1:]
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imported[name] = struct{}{}
+	}
+
+	var names []string
+	seen := map[string]struct{}{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		x, ok := sel.X.(*ast.Ident)
+		// identifiers declared in the file are resolved by the parser
+		if !ok || x.Obj != nil {
+			return true
+		}
+		if _, ok = imported[x.Name]; ok {
+			return true
+		}
+		if _, ok = seen[x.Name]; !ok {
+			seen[x.Name] = struct{}{}
+			names = append(names, x.Name)
+		}
+		return true
+	})
+	return names
+}
@@ -0,0 +1,43 @@
+package gocode
+
+import "testing"
+
+func TestWrapMain(t *testing.T) {
+	code := `s := strings.ToUpper("hello")
+	fmt.Println(s, mod.Value)
+	var b bytes.Buffer
+	b.WriteString(s)`
+
+	expected := `package main
+
+import (
+	"bytes"
+	"example.com/mod"
+	"fmt"
+	"strings"
+)
+
+func main() {
+	s := strings.ToUpper("hello")
+	fmt.Println(s, mod.Value)
+	var b bytes.Buffer
+	b.WriteString(s)
+}
+`
+	wrapped, err := WrapMain(code, map[string]string{"mod": "example.com/mod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wrapped != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, wrapped)
+	}
+}
+
+func TestIsProgram(t *testing.T) {
+	if !IsProgram("package main\n\nfunc main() {}\n") {
+		t.Error("expected program")
+	}
+	if IsProgram(`fmt.Println("hello")`) {
+		t.Error("expected no program")
+	}
+}
@@ -0,0 +1,330 @@
+package godoc
+
+import (
+	"bytes"
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strings"
+
+	"github.com/hhhapz/doc"
+)
+
+// ChangeKind is the kind of change of a symbol between two versions.
+type ChangeKind int
+
+const (
+	ChangeAdded ChangeKind = iota
+	ChangeRemoved
+	ChangeChanged
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeChanged:
+		return "changed"
+	}
+	return "unknown"
+}
+
+// Change is a single change of the exported API of a module.
+type Change struct {
+	Kind     ChangeKind
+	Package  string
+	Symbol   string
+	Old      string
+	New      string
+	Breaking bool
+}
+
+// APIDiff is the difference between the exported API of two versions of a module.
+type APIDiff struct {
+	Module  string
+	From    string
+	To      string
+	Changes []Change
+}
+
+// Breaking returns all breaking changes.
+func (d APIDiff) Breaking() []Change {
+	var changes []Change
+	for _, change := range d.Changes {
+		if change.Breaking {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// Kind returns all changes of the given kind.
+func (d APIDiff) Kind(kind ChangeKind) []Change {
+	var changes []Change
+	for _, change := range d.Changes {
+		if change.Kind == kind {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// Diff compares the exported API of two versions of a module. Internal packages are ignored.
+func (s *Searcher) Diff(ctx context.Context, module string, from string, to string) (*APIDiff, error) {
+	oldModule, err := s.Module(ctx, module, from)
+	if err != nil {
+		return nil, err
+	}
+	newModule, err := s.Module(ctx, module, to)
+	if err != nil {
+		return nil, err
+	}
+
+	oldPackages, err := oldModule.publicPackages()
+	if err != nil {
+		return nil, err
+	}
+	newPackages, err := newModule.publicPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	return &APIDiff{
+		Module:  oldModule.Path,
+		From:    oldModule.Version,
+		To:      newModule.Version,
+		Changes: DiffPackages(oldPackages, newPackages),
+	}, nil
+}
+
+// publicPackages returns all non-internal packages of the module keyed by their import path.
+func (m *Module) publicPackages() (map[string]doc.Package, error) {
+	pkgPaths, err := m.Packages()
+	if err != nil {
+		return nil, err
+	}
+	packages := map[string]doc.Package{}
+	for _, pkgPath := range pkgPaths {
+		if isInternal(pkgPath) {
+			continue
+		}
+		pkg, err := m.Package(pkgPath)
+		if err != nil {
+			continue
+		}
+		packages[pkgPath] = pkg
+	}
+	return packages, nil
+}
+
+func isInternal(pkgPath string) bool {
+	for _, elem := range strings.Split(pkgPath, "/") {
+		if elem == "internal" {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffPackages compares two sets of packages keyed by their import path.
+func DiffPackages(oldPackages map[string]doc.Package, newPackages map[string]doc.Package) []Change {
+	var changes []Change
+	for pkgPath, oldPkg := range oldPackages {
+		newPkg, ok := newPackages[pkgPath]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeRemoved, Package: pkgPath, Breaking: true})
+			continue
+		}
+		changes = append(changes, diffPackage(oldPkg, newPkg)...)
+	}
+	for pkgPath := range newPackages {
+		if _, ok := oldPackages[pkgPath]; !ok {
+			changes = append(changes, Change{Kind: ChangeAdded, Package: pkgPath})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Package != changes[j].Package {
+			return changes[i].Package < changes[j].Package
+		}
+		return changes[i].Symbol < changes[j].Symbol
+	})
+	return changes
+}
+
+func diffPackage(oldPkg doc.Package, newPkg doc.Package) []Change {
+	oldSymbols := packageSymbols(oldPkg)
+	newSymbols := packageSymbols(newPkg)
+
+	var changes []Change
+	for name, oldSymbol := range oldSymbols {
+		newSymbol, ok := newSymbols[name]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeRemoved, Package: oldPkg.URL, Symbol: name, Old: oldSymbol.signature, Breaking: true})
+			continue
+		}
+		if oldSymbol.key != newSymbol.key {
+			changes = append(changes, Change{Kind: ChangeChanged, Package: oldPkg.URL, Symbol: name, Old: oldSymbol.signature, New: newSymbol.signature, Breaking: true})
+		}
+	}
+	for name, newSymbol := range newSymbols {
+		if _, ok := oldSymbols[name]; !ok {
+			// adding methods to an interface breaks all implementations outside the module
+			changes = append(changes, Change{Kind: ChangeAdded, Package: newPkg.URL, Symbol: name, New: newSymbol.signature, Breaking: newSymbol.interfaceMethod})
+		}
+	}
+	return changes
+}
+
+type symbol struct {
+	// signature is the human-readable signature of the symbol.
+	signature string
+	// key is the normalized signature which is compared between versions.
+	key             string
+	interfaceMethod bool
+}
+
+// packageSymbols returns all exported functions, types, methods and fields of the package.
+func packageSymbols(pkg doc.Package) map[string]symbol {
+	symbols := map[string]symbol{}
+	for _, f := range pkg.Functions {
+		symbols[f.Name] = symbol{signature: f.Signature, key: funcKey(f.Signature)}
+	}
+	for _, t := range pkg.Types {
+		spec := parseTypeSpec(t.Signature)
+		if spec == nil {
+			symbols[t.Name] = symbol{signature: t.Signature, key: t.Signature}
+			continue
+		}
+		symbols[t.Name] = symbol{signature: "type " + t.Name, key: typeKey(spec)}
+
+		switch typ := spec.Type.(type) {
+		case *ast.StructType:
+			for _, field := range typ.Fields.List {
+				fieldType := exprString(field.Type)
+				names := field.Names
+				if len(names) == 0 {
+					names = []*ast.Ident{{Name: embeddedName(field.Type)}}
+				}
+				for _, name := range names {
+					if !ast.IsExported(name.Name) {
+						continue
+					}
+					symbols[t.Name+"."+name.Name] = symbol{signature: name.Name + " " + fieldType, key: fieldType}
+				}
+			}
+		case *ast.InterfaceType:
+			for _, method := range typ.Methods.List {
+				for _, name := range method.Names {
+					signature := name.Name + strings.TrimPrefix(exprString(method.Type), "func")
+					symbols[t.Name+"."+name.Name] = symbol{signature: signature, key: signature, interfaceMethod: true}
+				}
+				if len(method.Names) == 0 {
+					// embedded interface
+					embedded := exprString(method.Type)
+					symbols[t.Name+"."+embedded] = symbol{signature: embedded, key: embedded, interfaceMethod: true}
+				}
+			}
+		}
+
+		for _, m := range t.Methods {
+			symbols[t.Name+"."+m.Name] = symbol{signature: m.Signature, key: funcKey(m.Signature)}
+		}
+	}
+	return symbols
+}
+
+// typeKey returns the normalized declaration of a type. The members of structs and interfaces are compared
+// separately, so only their kind is part of the key.
+func typeKey(spec *ast.TypeSpec) string {
+	var params string
+	if spec.TypeParams != nil {
+		params = exprString(&ast.FuncType{Params: spec.TypeParams})
+	}
+	var assign string
+	if spec.Assign.IsValid() {
+		assign = "= "
+	}
+	switch spec.Type.(type) {
+	case *ast.StructType:
+		return params + assign + "struct"
+	case *ast.InterfaceType:
+		return params + assign + "interface"
+	}
+	return params + assign + exprString(spec.Type)
+}
+
+// funcKey returns the signature of a function or method without parameter and receiver names.
+func funcKey(signature string) string {
+	file, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+signature, 0)
+	if err != nil || len(file.Decls) == 0 {
+		return signature
+	}
+	decl, ok := file.Decls[0].(*ast.FuncDecl)
+	if !ok {
+		return signature
+	}
+	stripNames(decl.Type.TypeParams)
+	stripNames(decl.Type.Params)
+	stripNames(decl.Type.Results)
+	return exprString(decl.Type)
+}
+
+func stripNames(fields *ast.FieldList) {
+	if fields == nil {
+		return
+	}
+	var list []*ast.Field
+	for _, field := range fields.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			list = append(list, &ast.Field{Type: field.Type})
+		}
+	}
+	fields.List = list
+}
+
+func embeddedName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(e.X)
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	case *ast.Ident:
+		return e.Name
+	case *ast.IndexExpr:
+		return embeddedName(e.X)
+	case *ast.IndexListExpr:
+		return embeddedName(e.X)
+	}
+	return exprString(expr)
+}
+
+func exprString(node ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, token.NewFileSet(), node); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+func parseTypeSpec(signature string) *ast.TypeSpec {
+	file, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+signature, 0)
+	if err != nil || len(file.Decls) == 0 {
+		return nil
+	}
+	decl, ok := file.Decls[0].(*ast.GenDecl)
+	if !ok || len(decl.Specs) == 0 {
+		return nil
+	}
+	spec, _ := decl.Specs[0].(*ast.TypeSpec)
+	return spec
+}
@@ -0,0 +1,112 @@
+package godoc
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestDiffPackages(t *testing.T) {
+	oldModule := &Module{
+		Path:    "example.com/mod",
+		Version: "v1.0.0",
+		FS: fstest.MapFS{
+			"mod.go": {Data: []byte(`package mod
+
+type Client struct {
+	Token   string
+	Timeout int
+}
+
+func (c *Client) Do(path string) error { return nil }
+
+func (c *Client) Close() {}
+
+type Handler interface {
+	Handle(event string)
+}
+
+type ID int
+
+func New(token string) *Client { return nil }
+`)},
+			"removed/removed.go":     {Data: []byte("package removed\n\nfunc Removed() {}\n")},
+			"internal/x/x.go":        {Data: []byte("package x\n\nfunc Internal() {}\n")},
+			"unchanged/unchanged.go": {Data: []byte("package unchanged\n\nfunc Same(a, b int) {}\n")},
+		},
+	}
+	newModule := &Module{
+		Path:    "example.com/mod",
+		Version: "v2.0.0",
+		FS: fstest.MapFS{
+			"mod.go": {Data: []byte(`package mod
+
+type Client struct {
+	Token   string
+	Timeout int64
+	Retries int
+}
+
+func (client *Client) Do(p string) error { return nil }
+
+type Handler interface {
+	Handle(event string)
+	Close()
+}
+
+type ID string
+
+func New(token string, opts ...string) *Client { return nil }
+
+func Added() {}
+`)},
+			"added/added.go":         {Data: []byte("package added\n\nfunc Added() {}\n")},
+			"internal/y/y.go":        {Data: []byte("package y\n\nfunc Internal() {}\n")},
+			"unchanged/unchanged.go": {Data: []byte("package unchanged\n\nfunc Same(x int, y int) {}\n")},
+		},
+	}
+
+	oldPackages, err := oldModule.publicPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPackages, err := newModule.publicPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		kind     ChangeKind
+		pkg      string
+		symbol   string
+		breaking bool
+	}{
+		{ChangeChanged, "example.com/mod", "Client.Timeout", true},
+		{ChangeRemoved, "example.com/mod", "Client.Close", true},
+		{ChangeAdded, "example.com/mod", "Client.Retries", false},
+		{ChangeAdded, "example.com/mod", "Handler.Close", true},
+		{ChangeChanged, "example.com/mod", "ID", true},
+		{ChangeChanged, "example.com/mod", "New", true},
+		{ChangeAdded, "example.com/mod", "Added", false},
+		{ChangeAdded, "example.com/mod/added", "", false},
+		{ChangeRemoved, "example.com/mod/removed", "", true},
+	}
+
+	changes := DiffPackages(oldPackages, newPackages)
+	if len(changes) != len(expected) {
+		t.Errorf("expected %d changes, got %d: %+v", len(expected), len(changes), changes)
+	}
+	for _, e := range expected {
+		var found bool
+		for _, change := range changes {
+			if change.Package == e.pkg && change.Symbol == e.symbol {
+				found = true
+				if change.Kind != e.kind || change.Breaking != e.breaking {
+					t.Errorf("unexpected change for %s.%s: %s (breaking: %t)", e.pkg, e.symbol, change.Kind, change.Breaking)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("missing change for %s.%s", e.pkg, e.symbol)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package godoc
+
+import (
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/hhhapz/doc"
+)
+
+var _ doc.Note = List{}
+
+// List is a doc.Note for lists in doc comments, which the doc package has no representation for.
+type List struct {
+	Ordered bool
+	Items   []string
+}
+
+func (l List) Text() string {
+	lines := make([]string, len(l.Items))
+	for i, item := range l.Items {
+		lines[i] = "  " + l.marker(i) + " " + item
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (l List) HTML() string {
+	tag := "ul"
+	if l.Ordered {
+		tag = "ol"
+	}
+	s := "<" + tag + ">"
+	for _, item := range l.Items {
+		s += "<li>" + html.EscapeString(item) + "</li>"
+	}
+	return s + "</" + tag + ">"
+}
+
+func (l List) Markdown() string {
+	lines := make([]string, len(l.Items))
+	for i, item := range l.Items {
+		lines[i] = l.marker(i) + " " + item
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (l List) marker(i int) string {
+	if l.Ordered {
+		return strconv.Itoa(i+1) + "."
+	}
+	return "-"
+}
@@ -0,0 +1,407 @@
+package godoc
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/build"
+	astdoc "go/doc"
+	"go/doc/comment"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hhhapz/doc"
+	"golang.org/x/mod/modfile"
+)
+
+var outputPrefixRegex = regexp.MustCompile(`(?i)^[[:space:]]*(unordered )?output:`)
+
+// UnorderedOutputComment ends the code of examples whose output may be printed in any order. doc.Example has no
+// field for it, so it is kept in the code like in the example source.
+const UnorderedOutputComment = "// Unordered output:"
+
+// Package builds the documentation of the package with the given import path in the module.
+func (m *Module) Package(pkgPath string) (doc.Package, error) {
+	dir := m.dir(pkgPath)
+	if dir == "" {
+		return doc.Package{}, doc.InvalidStatusError(http.StatusNotFound)
+	}
+
+	fset := token.NewFileSet()
+	files, err := parseDir(m.FS, fset, dir)
+	if err != nil {
+		return doc.Package{}, err
+	}
+	if len(files) == 0 {
+		return doc.Package{}, doc.InvalidStatusError(http.StatusNotFound)
+	}
+
+	p, err := astdoc.NewFromFiles(fset, files, pkgPath)
+	if err != nil {
+		return doc.Package{}, err
+	}
+
+	subpackages, err := m.subpackages(dir)
+	if err != nil {
+		return doc.Package{}, err
+	}
+
+	return convertPackage(fset, p, pkgPath, subpackages), nil
+}
+
+// Packages returns the import paths of all packages in the module.
+func (m *Module) Packages() ([]string, error) {
+	pkgPaths, err := m.subpackages(".")
+	if err != nil {
+		return nil, err
+	}
+	if hasGoFiles(m.FS, ".") {
+		pkgPaths = append([]string{m.Path}, pkgPaths...)
+	}
+	return pkgPaths, nil
+}
+
+// modulePath returns the module path declared in the go.mod of the module. Modules without a go.mod, like the
+// standard library or modules which predate go modules, use the path they were found at.
+func (m *Module) modulePath() string {
+	if m.Path == stdModule {
+		return m.Path
+	}
+	data, err := fs.ReadFile(m.FS, "go.mod")
+	if err != nil {
+		return m.Path
+	}
+	if modulePath := modfile.ModulePath(data); modulePath != "" {
+		return modulePath
+	}
+	return m.Path
+}
+
+// dir returns the directory of the package inside the module or an empty string if the package is not part of it.
+func (m *Module) dir(pkgPath string) string {
+	if m.Path == stdModule {
+		return pkgPath
+	}
+	if pkgPath == m.Path {
+		return "."
+	}
+	if !strings.HasPrefix(pkgPath, m.Path+"/") {
+		return ""
+	}
+	return strings.TrimPrefix(pkgPath, m.Path+"/")
+}
+
+// importPath returns the import path of a directory inside the module.
+func (m *Module) importPath(dir string) string {
+	if m.Path == stdModule {
+		return dir
+	}
+	if dir == "." {
+		return m.Path
+	}
+	return m.Path + "/" + dir
+}
+
+// subpackages returns the import paths of all packages below the given directory, skipping nested modules.
+func (m *Module) subpackages(dir string) ([]string, error) {
+	var subpackages []string
+	err := fs.WalkDir(m.FS, dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || name == dir {
+			return nil
+		}
+		base := path.Base(name)
+		if base == "testdata" || base == "vendor" || strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_") {
+			return fs.SkipDir
+		}
+		if _, err = fs.Stat(m.FS, path.Join(name, "go.mod")); err == nil {
+			return fs.SkipDir
+		}
+		if hasGoFiles(m.FS, name) {
+			subpackages = append(subpackages, m.importPath(name))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return subpackages, nil
+}
+
+func hasGoFiles(fsys fs.FS, dir string) bool {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".go") && !strings.HasSuffix(entry.Name(), "_test.go") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDir parses all go files of the directory which match the linux/amd64 build context.
+// Test files are included so examples can be extracted from them.
+func parseDir(fsys fs.FS, fset *token.FileSet, dir string) ([]*ast.File, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	ctx := build.Default
+	ctx.GOOS = "linux"
+	ctx.GOARCH = "amd64"
+	ctx.JoinPath = path.Join
+	ctx.OpenFile = func(name string) (io.ReadCloser, error) {
+		return fsys.Open(name)
+	}
+
+	var (
+		files    []*ast.File
+		pkgNames = map[string]int{}
+	)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+		if ok, err := ctx.MatchFile(dir, entry.Name()); err != nil || !ok {
+			continue
+		}
+		src, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(fset, path.Join(dir, entry.Name()), src, parser.ParseComments)
+		if err != nil {
+			// skip files we can't parse instead of failing the whole package
+			continue
+		}
+		if !strings.HasSuffix(entry.Name(), "_test.go") {
+			pkgNames[file.Name.Name]++
+		}
+		files = append(files, file)
+	}
+
+	var pkgName string
+	for name, count := range pkgNames {
+		if count > pkgNames[pkgName] || (count == pkgNames[pkgName] && name < pkgName) {
+			pkgName = name
+		}
+	}
+	if pkgName == "" {
+		return nil, nil
+	}
+
+	filtered := files[:0]
+	for _, file := range files {
+		if file.Name.Name == pkgName || file.Name.Name == pkgName+"_test" {
+			filtered = append(filtered, file)
+		}
+	}
+	return filtered, nil
+}
+
+func convertPackage(fset *token.FileSet, p *astdoc.Package, pkgPath string, subpackages []string) doc.Package {
+	pkg := doc.Package{
+		URL:         pkgPath,
+		Name:        p.Name,
+		Overview:    convertComment(p, p.Doc),
+		Examples:    convertExamples(fset, p.Examples),
+		Functions:   map[string]doc.Function{},
+		Types:       map[string]doc.Type{},
+		Subpackages: subpackages,
+	}
+
+	for _, f := range p.Funcs {
+		pkg.Functions[strings.ToLower(f.Name)] = convertFunc(fset, p, f)
+	}
+	for _, t := range p.Types {
+		typ := doc.Type{
+			Name:          t.Name,
+			Signature:     typeSignature(fset, t),
+			Comment:       convertComment(p, t.Doc),
+			Examples:      convertExamples(fset, t.Examples),
+			TypeFunctions: map[string]doc.Function{},
+			Methods:       map[string]doc.Method{},
+		}
+		for _, f := range t.Funcs {
+			fn := convertFunc(fset, p, f)
+			typ.TypeFunctions[strings.ToLower(f.Name)] = fn
+			pkg.Functions[strings.ToLower(f.Name)] = fn
+		}
+		for _, m := range t.Methods {
+			typ.Methods[strings.ToLower(m.Name)] = doc.Method{
+				For:      t.Name,
+				Function: convertFunc(fset, p, m),
+			}
+		}
+		pkg.Types[strings.ToLower(t.Name)] = typ
+	}
+	return pkg
+}
+
+func convertFunc(fset *token.FileSet, p *astdoc.Package, f *astdoc.Func) doc.Function {
+	decl := *f.Decl
+	decl.Doc = nil
+	decl.Body = nil
+	return doc.Function{
+		Name:      f.Name,
+		Signature: printNode(fset, &decl),
+		Comment:   convertComment(p, f.Doc),
+		Examples:  convertExamples(fset, f.Examples),
+	}
+}
+
+// typeSignature prints the declaration of the type including the comments of its fields and methods.
+func typeSignature(fset *token.FileSet, t *astdoc.Type) string {
+	var spec *ast.TypeSpec
+	for _, s := range t.Decl.Specs {
+		if ts, ok := s.(*ast.TypeSpec); ok && ts.Name.Name == t.Name {
+			spec = ts
+			break
+		}
+	}
+	if spec == nil {
+		return "type " + t.Name
+	}
+
+	specCopy := *spec
+	specCopy.Doc = nil
+	decl := &ast.GenDecl{
+		TokPos: spec.Pos(),
+		Tok:    token.TYPE,
+		Specs:  []ast.Spec{&specCopy},
+	}
+
+	var comments []*ast.CommentGroup
+	ast.Inspect(spec.Type, func(node ast.Node) bool {
+		if field, ok := node.(*ast.Field); ok {
+			if field.Doc != nil {
+				comments = append(comments, field.Doc)
+			}
+			if field.Comment != nil {
+				comments = append(comments, field.Comment)
+			}
+		}
+		return true
+	})
+	if spec.Comment != nil {
+		comments = append(comments, spec.Comment)
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		return comments[i].Pos() < comments[j].Pos()
+	})
+
+	return printNode(fset, &printer.CommentedNode{Node: decl, Comments: comments})
+}
+
+func convertExamples(fset *token.FileSet, examples []*astdoc.Example) []doc.Example {
+	converted := make([]doc.Example, 0, len(examples))
+	for _, e := range examples {
+		name := "Example"
+		if e.Suffix != "" {
+			name += " (" + e.Suffix + ")"
+		}
+		code := exampleCode(fset, e)
+		if e.Unordered {
+			code += "\n\n" + UnorderedOutputComment
+		}
+		converted = append(converted, doc.Example{
+			Name:   name,
+			Code:   code,
+			Output: e.Output,
+		})
+	}
+	return converted
+}
+
+// exampleCode prints the body of the example without the surrounding braces and output comment.
+func exampleCode(fset *token.FileSet, e *astdoc.Example) string {
+	comments := make([]*ast.CommentGroup, 0, len(e.Comments))
+	for _, c := range e.Comments {
+		if !outputPrefixRegex.MatchString(c.Text()) {
+			comments = append(comments, c)
+		}
+	}
+	code := printNode(fset, &printer.CommentedNode{Node: e.Code, Comments: comments})
+	if _, ok := e.Code.(*ast.BlockStmt); !ok {
+		return code
+	}
+	code = strings.TrimSuffix(strings.TrimPrefix(code, "{"), "}")
+	lines := strings.Split(strings.Trim(code, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, "\t")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func printNode(fset *token.FileSet, node any) string {
+	var buf bytes.Buffer
+	config := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	if err := config.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// convertComment parses a doc comment and converts it into doc.Comment notes. Doc links are kept in their
+// [Name] form so they can be resolved when rendering.
+func convertComment(p *astdoc.Package, text string) doc.Comment {
+	parsed := p.Parser().Parse(text)
+
+	notes := make(doc.Comment, 0, len(parsed.Content))
+	for _, block := range parsed.Content {
+		switch b := block.(type) {
+		case *comment.Heading:
+			notes = append(notes, doc.Heading(inlineText(b.Text)))
+		case *comment.Paragraph:
+			notes = append(notes, doc.Paragraph(inlineText(b.Text)))
+		case *comment.Code:
+			notes = append(notes, doc.Pre(strings.TrimSuffix(b.Text, "\n")))
+		case *comment.List:
+			list := List{Ordered: b.Items[0].Number != ""}
+			for _, item := range b.Items {
+				var texts []string
+				for _, content := range item.Content {
+					if paragraph, ok := content.(*comment.Paragraph); ok {
+						texts = append(texts, inlineText(paragraph.Text))
+					}
+				}
+				list.Items = append(list.Items, strings.Join(texts, " "))
+			}
+			notes = append(notes, list)
+		}
+	}
+	return notes
+}
+
+func inlineText(texts []comment.Text) string {
+	var sb strings.Builder
+	for _, text := range texts {
+		switch t := text.(type) {
+		case comment.Plain:
+			sb.WriteString(string(t))
+		case comment.Italic:
+			sb.WriteString(string(t))
+		case *comment.Link:
+			sb.WriteString(inlineText(t.Text))
+		case *comment.DocLink:
+			sb.WriteString("[" + inlineText(t.Text) + "]")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,145 @@
+package godoc
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/hhhapz/doc"
+	"golang.org/x/mod/semver"
+)
+
+// maxModuleZipSize is the maximum size of a module zip as defined by the go command.
+const maxModuleZipSize = 500 << 20
+
+type versionInfo struct {
+	Version string `json:"Version"`
+}
+
+// latestVersion resolves the latest version of the module using the proxy.
+func (s *Searcher) latestVersion(ctx context.Context, module string) (string, error) {
+	body, err := s.proxyRequest(ctx, module, "@latest")
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	var info versionInfo
+	if err = json.NewDecoder(body).Decode(&info); err != nil {
+		return "", err
+	}
+	return info.Version, nil
+}
+
+// versionExists checks whether the proxy knows the given version of the module.
+func (s *Searcher) versionExists(ctx context.Context, module string, version string) (string, error) {
+	escapedVersion, err := escapePath(version)
+	if err != nil {
+		return "", err
+	}
+	body, err := s.proxyRequest(ctx, module, "@v/"+escapedVersion+".info")
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	var info versionInfo
+	if err = json.NewDecoder(body).Decode(&info); err != nil {
+		return "", err
+	}
+	return info.Version, nil
+}
+
+// Versions returns all tagged versions of the module known to the proxy in ascending order.
+func (s *Searcher) Versions(ctx context.Context, module string) ([]string, error) {
+	body, err := s.proxyRequest(ctx, module, "@v/list")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	versions := strings.Fields(string(data))
+	semver.Sort(versions)
+	return versions, nil
+}
+
+// downloadModule downloads the zip of the module version and returns its content rooted at the module root and the
+// size of the zip.
+func (s *Searcher) downloadModule(ctx context.Context, module string, version string) (fs.FS, int64, error) {
+	escapedVersion, err := escapePath(version)
+	if err != nil {
+		return nil, 0, err
+	}
+	body, err := s.proxyRequest(ctx, module, "@v/"+escapedVersion+".zip")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, maxModuleZipSize+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(data) > maxModuleZipSize {
+		return nil, 0, fmt.Errorf("module zip of %s@%s exceeds %d bytes", module, version, maxModuleZipSize)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, 0, err
+	}
+	fsys, err := fs.Sub(zr, module+"@"+version)
+	return fsys, int64(len(data)), err
+}
+
+func (s *Searcher) proxyRequest(ctx context.Context, module string, suffix string) (io.ReadCloser, error) {
+	escapedModule, err := escapePath(module)
+	if err != nil {
+		return nil, err
+	}
+	rq, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(s.proxy, "/")+"/"+escapedModule+"/"+suffix, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := s.client.Do(rq)
+	if err != nil {
+		return nil, err
+	}
+	if rs.StatusCode != http.StatusOK {
+		_ = rs.Body.Close()
+		if rs.StatusCode == http.StatusGone {
+			return nil, doc.InvalidStatusError(http.StatusNotFound)
+		}
+		return nil, doc.InvalidStatusError(rs.StatusCode)
+	}
+	return rs.Body, nil
+}
+
+// escapePath escapes a module path or version as described in https://go.dev/ref/mod#goproxy-protocol.
+func escapePath(path string) (string, error) {
+	var sb strings.Builder
+	for _, r := range path {
+		if r >= unicode.MaxASCII {
+			return "", fmt.Errorf("invalid character %q in %q", r, path)
+		}
+		if 'A' <= r && r <= 'Z' {
+			sb.WriteByte('!')
+			sb.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,267 @@
+package godoc
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/hhhapz/doc"
+)
+
+const (
+	// DefaultProxy is the default GOPROXY used to download modules.
+	DefaultProxy = "https://proxy.golang.org"
+
+	// VersionLocal is the version reported for modules loaded from a local directory.
+	VersionLocal = "local"
+
+	// DefaultCacheSize is the default maximum size of the module zips kept in memory.
+	DefaultCacheSize = 1 << 30
+
+	stdModule = "std"
+)
+
+var _ doc.Searcher = (*Searcher)(nil)
+
+// ConfigOpt is used to configure a Searcher.
+type ConfigOpt func(s *Searcher)
+
+// WithProxy sets the GOPROXY base URL modules are downloaded from.
+func WithProxy(proxy string) ConfigOpt {
+	return func(s *Searcher) {
+		s.proxy = proxy
+	}
+}
+
+// WithLocalModules maps module paths to local directories which are used instead of the proxy.
+func WithLocalModules(modules map[string]string) ConfigOpt {
+	return func(s *Searcher) {
+		for module, dir := range modules {
+			s.localModules[module] = dir
+		}
+	}
+}
+
+// WithGoRoot sets the GOROOT the standard library is read from.
+func WithGoRoot(goRoot string) ConfigOpt {
+	return func(s *Searcher) {
+		s.goRoot = goRoot
+	}
+}
+
+// WithCacheSize sets the maximum size of the module zips kept in memory. The least recently used modules are
+// dropped first.
+func WithCacheSize(size int64) ConfigOpt {
+	return func(s *Searcher) {
+		s.maxCacheSize = size
+	}
+}
+
+// New returns a Searcher which builds package documentation from module sources using go/doc
+// instead of scraping a documentation site.
+func New(client *http.Client, opts ...ConfigOpt) *Searcher {
+	s := &Searcher{
+		client:       client,
+		proxy:        DefaultProxy,
+		localModules: map[string]string{},
+		maxCacheSize: DefaultCacheSize,
+		modules:      map[string]*list.Element{},
+		lru:          list.New(),
+		modulePaths:  map[string]string{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Searcher implements doc.Searcher by downloading module zips from a GOPROXY or reading local module directories.
+// Packages can be looked up at a specific version by appending @version to the package path.
+type Searcher struct {
+	client       *http.Client
+	proxy        string
+	goRoot       string
+	localModules map[string]string
+	maxCacheSize int64
+
+	mu        sync.Mutex
+	modules   map[string]*list.Element
+	lru       *list.List
+	cacheSize int64
+	// modulePaths maps the queries of previous searches to the module path of the package.
+	modulePaths map[string]string
+}
+
+// Module is the source of a single module version.
+type Module struct {
+	Path    string
+	Version string
+	FS      fs.FS
+
+	// size is the size of the module zip, it is 0 for modules which are not cached.
+	size int64
+}
+
+// Search returns the documentation of the package with the given import path.
+func (s *Searcher) Search(ctx context.Context, query string) (doc.Package, error) {
+	pkgPath, version := SplitVersion(query)
+	module, err := s.Module(ctx, pkgPath, version)
+	if err != nil {
+		return doc.Package{}, err
+	}
+	pkg, err := module.Package(pkgPath)
+	if err != nil {
+		return doc.Package{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.modulePaths[query] = module.modulePath()
+	return pkg, nil
+}
+
+// ModulePath returns the path of the module which provided the package in a previous search as declared in its
+// go.mod. Packages which were not searched at the version are looked up at their latest version.
+func (s *Searcher) ModulePath(pkgPath string, version string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if version != "" {
+		if modulePath, ok := s.modulePaths[pkgPath+"@"+version]; ok {
+			return modulePath, true
+		}
+	}
+	modulePath, ok := s.modulePaths[pkgPath]
+	return modulePath, ok
+}
+
+// Module resolves the module which provides the given package path at the given version.
+// An empty version resolves to the latest version. Local modules only have a single version, so modules which are
+// mapped to a local directory are downloaded from the proxy if a specific version is requested.
+func (s *Searcher) Module(ctx context.Context, pkgPath string, version string) (*Module, error) {
+	if version == "" || version == VersionLocal {
+		if module := s.localModule(pkgPath); module != nil {
+			return module, nil
+		}
+	}
+	if isStd(pkgPath) {
+		if s.goRoot == "" {
+			return nil, doc.InvalidStatusError(http.StatusNotFound)
+		}
+		return &Module{
+			Path:    stdModule,
+			Version: VersionLocal,
+			FS:      os.DirFS(path.Join(s.goRoot, "src")),
+		}, nil
+	}
+
+	for modulePath := pkgPath; modulePath != "." && modulePath != ""; modulePath = path.Dir(modulePath) {
+		module, err := s.proxyModule(ctx, modulePath, version)
+		if errors.Is(err, doc.InvalidStatusError(http.StatusNotFound)) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return module, nil
+	}
+	return nil, doc.InvalidStatusError(http.StatusNotFound)
+}
+
+func (s *Searcher) localModule(pkgPath string) *Module {
+	var modulePath, dir string
+	for localPath, localDir := range s.localModules {
+		if (pkgPath == localPath || strings.HasPrefix(pkgPath, localPath+"/")) && len(localPath) > len(modulePath) {
+			modulePath, dir = localPath, localDir
+		}
+	}
+	if modulePath == "" {
+		return nil
+	}
+	return &Module{
+		Path:    modulePath,
+		Version: VersionLocal,
+		FS:      os.DirFS(dir),
+	}
+}
+
+func (s *Searcher) proxyModule(ctx context.Context, modulePath string, version string) (*Module, error) {
+	var err error
+	if version == "" {
+		version, err = s.latestVersion(ctx, modulePath)
+	} else {
+		version, err = s.versionExists(ctx, modulePath, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := modulePath + "@" + version
+	if module := s.cachedModule(key); module != nil {
+		return module, nil
+	}
+
+	fsys, size, err := s.downloadModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	module := &Module{
+		Path:    modulePath,
+		Version: version,
+		FS:      fsys,
+		size:    size,
+	}
+	s.cacheModule(key, module)
+	return module, nil
+}
+
+func (s *Searcher) cachedModule(key string) *Module {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	element, ok := s.modules[key]
+	if !ok {
+		return nil
+	}
+	s.lru.MoveToFront(element)
+	return element.Value.(*Module)
+}
+
+// cacheModule keeps the module in memory and drops the least recently used modules until the cache fits into its
+// maximum size. Modules larger than the cache are not kept.
+func (s *Searcher) cacheModule(key string, module *Module) {
+	if module.size > s.maxCacheSize {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.modules[key]; ok {
+		return
+	}
+	s.modules[key] = s.lru.PushFront(module)
+	s.cacheSize += module.size
+	for s.cacheSize > s.maxCacheSize {
+		oldest := s.lru.Back()
+		oldModule := s.lru.Remove(oldest).(*Module)
+		delete(s.modules, oldModule.Path+"@"+oldModule.Version)
+		s.cacheSize -= oldModule.size
+	}
+}
+
+// SplitVersion splits a query in the form of path@version into its path and version.
+func SplitVersion(query string) (string, string) {
+	if i := strings.LastIndex(query, "@"); i != -1 {
+		return query[:i], query[i+1:]
+	}
+	return query, ""
+}
+
+// isStd reports whether the package path belongs to the standard library, which is the case if
+// the first path element does not contain a dot.
+func isStd(pkgPath string) bool {
+	elem, _, _ := strings.Cut(pkgPath, "/")
+	return !strings.Contains(elem, ".")
+}
@@ -0,0 +1,278 @@
+package godoc
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hhhapz/doc"
+)
+
+var testModuleFiles = map[string]string{
+	"go.mod": "module example.com/Mod\n\ngo 1.19\n",
+	"client.go": `// Package mod is a test module.
+//
+// # Usage
+//
+// Create a [Client] with [New]:
+//
+//	c := mod.New("token")
+package mod
+
+// Client talks to the API.
+type Client struct {
+	// Token is the token used for requests.
+	Token string
+	secret string
+}
+
+// New returns a new [Client].
+func New(token string) *Client {
+	return &Client{Token: token}
+}
+
+// Do does something.
+func (c *Client) Do() error {
+	return nil
+}
+
+func unexported() {}
+`,
+	"client_windows.go": "package mod\n\nfunc Windows() {}\n",
+	"example_test.go": `package mod_test
+
+import "fmt"
+
+func ExampleNew() {
+	fmt.Println("hello")
+	// Output: hello
+}
+
+func ExampleClient_Do() {
+	fmt.Println("a")
+	fmt.Println("b")
+	// Unordered output:
+	// b
+	// a
+}
+`,
+	"sub/sub.go":           "// Package sub is a subpackage.\npackage sub\n\n// Hello says hello.\nfunc Hello() string { return \"hello\" }\n",
+	"internal/x/x.go":      "package x\n",
+	"testdata/ignored.go":  "package ignored\n",
+	"nested/go.mod":        "module example.com/Mod/nested\n",
+	"nested/nested.go":     "package nested\n",
+	"docs/readme.txt":      "not a package",
+	"sub/sub_linux.go":     "package sub\n\n// Linux is only built on linux.\nfunc Linux() {}\n",
+	"sub/sub_darwin.go":    "package sub\n\nfunc Darwin() {}\n",
+	"sub/sub_test.go":      "package sub\n",
+	"sub/sub_ignore_me.go": "//go:build ignore\n\npackage main\n",
+}
+
+func newTestProxy(t *testing.T) *httptest.Server {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range testModuleFiles {
+		w, err := zw.Create("example.com/Mod@v1.0.0/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/example.com/!mod/@latest", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"Version":"v1.0.0"}`))
+	})
+	mux.HandleFunc("/example.com/!mod/@v/v1.0.0.info", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"Version":"v1.0.0"}`))
+	})
+	mux.HandleFunc("/example.com/!mod/@v/list", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("v0.9.0\nv1.0.0\n"))
+	})
+	mux.HandleFunc("/example.com/!mod/@v/v1.0.0.zip", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(buf.Bytes())
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSearcherProxy(t *testing.T) {
+	server := newTestProxy(t)
+	s := New(server.Client(), WithProxy(server.URL))
+
+	pkg, err := s.Search(context.Background(), "example.com/Mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.URL != "example.com/Mod" || pkg.Name != "mod" {
+		t.Fatalf("unexpected package %s (%s)", pkg.URL, pkg.Name)
+	}
+	if !strings.Contains(pkg.Overview.Text(), "Create a [Client] with [New]:") {
+		t.Errorf("doc links were not preserved in overview: %q", pkg.Overview.Text())
+	}
+	if len(pkg.Overview) != 4 {
+		t.Errorf("expected 4 overview notes, got %d", len(pkg.Overview))
+	}
+	if _, ok := pkg.Overview[1].(doc.Heading); !ok {
+		t.Errorf("expected heading, got %T", pkg.Overview[1])
+	}
+
+	client, ok := pkg.Types["client"]
+	if !ok {
+		t.Fatal("type Client not found")
+	}
+	if strings.Contains(client.Signature, "secret") {
+		t.Errorf("unexported field in signature: %s", client.Signature)
+	}
+	if !strings.Contains(client.Signature, "// Token is the token used for requests.") {
+		t.Errorf("field comment missing in signature: %s", client.Signature)
+	}
+	if _, ok = client.Methods["do"]; !ok {
+		t.Error("method Client.Do not found")
+	}
+	if client.Methods["do"].For != "Client" {
+		t.Errorf("unexpected method receiver %s", client.Methods["do"].For)
+	}
+
+	newFunc, ok := pkg.Functions["new"]
+	if !ok {
+		t.Fatal("func New not found")
+	}
+	if newFunc.Signature != "func New(token string) *Client" {
+		t.Errorf("unexpected signature %q", newFunc.Signature)
+	}
+	if _, ok = client.TypeFunctions["new"]; !ok {
+		t.Error("func New not associated with Client")
+	}
+	if len(newFunc.Examples) != 1 || newFunc.Examples[0].Code != `fmt.Println("hello")` || newFunc.Examples[0].Output != "hello\n" {
+		t.Errorf("unexpected examples %#v", newFunc.Examples)
+	}
+	if examples := client.Methods["do"].Examples; len(examples) != 1 || !strings.HasSuffix(examples[0].Code, "\n\n"+UnorderedOutputComment) {
+		t.Errorf("expected unordered example, got %#v", examples)
+	}
+	if _, ok = pkg.Functions["unexported"]; ok {
+		t.Error("unexported func documented")
+	}
+	if _, ok = pkg.Functions["windows"]; ok {
+		t.Error("windows only func documented")
+	}
+
+	expected := []string{"example.com/Mod/internal/x", "example.com/Mod/sub"}
+	if strings.Join(pkg.Subpackages, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected subpackages %v", pkg.Subpackages)
+	}
+}
+
+func TestSearcherProxyVersion(t *testing.T) {
+	server := newTestProxy(t)
+	s := New(server.Client(), WithProxy(server.URL))
+
+	pkg, err := s.Search(context.Background(), "example.com/Mod/sub@v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pkg.Functions["linux"]; !ok {
+		t.Error("func Linux not found")
+	}
+	if _, ok := pkg.Functions["darwin"]; ok {
+		t.Error("darwin only func documented")
+	}
+	if modulePath, ok := s.ModulePath("example.com/Mod/sub", "v1.0.0"); !ok || modulePath != "example.com/Mod" {
+		t.Errorf("expected module path example.com/Mod, got %q, %t", modulePath, ok)
+	}
+	if _, ok := s.ModulePath("example.com/Mod/sub", "v0.9.0"); ok {
+		t.Error("expected module path of a package which was not searched to be unknown")
+	}
+
+	if _, err = s.Search(context.Background(), "example.com/Mod/missing"); err != doc.InvalidStatusError(http.StatusNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if _, err = s.Search(context.Background(), "example.com/Other"); err != doc.InvalidStatusError(http.StatusNotFound) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	versions, err := s.Versions(context.Background(), "example.com/Mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(versions, ",") != "v0.9.0,v1.0.0" {
+		t.Errorf("unexpected versions %v", versions)
+	}
+}
+
+func TestSearcherLocalModule(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range testModuleFiles {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := newTestProxy(t)
+	s := New(server.Client(), WithProxy(server.URL), WithLocalModules(map[string]string{"example.com/Mod": dir}))
+	pkg, err := s.Search(context.Background(), "example.com/Mod/sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Functions["hello"].Comment.Text() != "Hello says hello." {
+		t.Errorf("unexpected comment %q", pkg.Functions["hello"].Comment.Text())
+	}
+
+	// specific versions of local modules are downloaded from the proxy
+	module, err := s.Module(context.Background(), "example.com/Mod", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if module.Version != "v1.0.0" {
+		t.Errorf("expected proxy module, got version %s", module.Version)
+	}
+}
+
+func TestSearcherCacheSize(t *testing.T) {
+	server := newTestProxy(t)
+	s := New(server.Client(), WithProxy(server.URL), WithCacheSize(1))
+	if _, err := s.Module(context.Background(), "example.com/Mod", "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if s.lru.Len() != 0 || s.cacheSize != 0 {
+		t.Errorf("expected module larger than the cache to not be cached, got %d modules", s.lru.Len())
+	}
+
+	s = New(server.Client(), WithProxy(server.URL))
+	if _, err := s.Module(context.Background(), "example.com/Mod", "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if s.lru.Len() != 1 || s.cacheSize == 0 {
+		t.Errorf("expected module to be cached, got %d modules", s.lru.Len())
+	}
+	s.maxCacheSize = s.cacheSize
+	s.cacheModule("example.com/Other@v1.0.0", &Module{Path: "example.com/Other", Version: "v1.0.0", size: 1})
+	if _, ok := s.modules["example.com/Mod@v1.0.0"]; ok || s.lru.Len() != 1 {
+		t.Error("expected least recently used module to be dropped")
+	}
+}
+
+func TestEscapePath(t *testing.T) {
+	escaped, err := escapePath("github.com/BurntSushi/toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if escaped != "github.com/!burnt!sushi/toml" {
+		t.Errorf("unexpected escaped path %q", escaped)
+	}
+}
@@ -0,0 +1,13 @@
+package godoc
+
+import "github.com/disgoorg/disgo-butler/gocode"
+
+// StdPackagePath returns the import path of the standard library package with the given name. Names which are used
+// by multiple packages like rand or template are ambiguous and are not resolved.
+func StdPackagePath(name string) (string, bool) {
+	paths := gocode.StdPackagePaths(name)
+	if len(paths) != 1 {
+		return "", false
+	}
+	return paths[0], true
+}
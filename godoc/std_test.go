@@ -0,0 +1,19 @@
+package godoc
+
+import "testing"
+
+func TestStdPackagePath(t *testing.T) {
+	tests := map[string]string{
+		"http": "net/http",
+		"json": "encoding/json",
+		"fmt":  "fmt",
+		"rand": "",
+		"mod":  "",
+	}
+	for name, want := range tests {
+		got, ok := StdPackagePath(name)
+		if got != want || ok != (want != "") {
+			t.Errorf("StdPackagePath(%q) = %q, %t, want %q", name, got, ok, want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Package evaltest provides an in-process butler.Executor for tests.
+package evaltest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/disgoorg/disgo-butler/butler"
+)
+
+var _ butler.Executor = (*Executor)(nil)
+
+// NewExecutor creates an in-process executor for tests. It offers the runtimes and answers every execution with the
+// result of execute.
+func NewExecutor(runtimes []butler.Runtime, execute func(input butler.EvalInput) (*butler.ExecResult, error)) *Executor {
+	return &Executor{
+		runtimes: runtimes,
+		execute:  execute,
+	}
+}
+
+// Executor is an executor which doesn't run any code. See NewExecutor.
+type Executor struct {
+	runtimes []butler.Runtime
+	execute  func(input butler.EvalInput) (*butler.ExecResult, error)
+
+	mu     sync.Mutex
+	inputs []butler.EvalInput
+}
+
+func (e *Executor) Runtimes(_ context.Context) ([]butler.Runtime, error) {
+	return e.runtimes, nil
+}
+
+func (e *Executor) Execute(_ context.Context, input butler.EvalInput) (*butler.ExecResult, error) {
+	e.mu.Lock()
+	e.inputs = append(e.inputs, input)
+	e.mu.Unlock()
+	return e.execute(input)
+}
+
+// Inputs returns all inputs executed so far.
+func (e *Executor) Inputs() []butler.EvalInput {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]butler.EvalInput(nil), e.inputs...)
+}
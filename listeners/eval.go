@@ -0,0 +1,86 @@
+package listeners
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/disgoorg/json"
+
+	"github.com/disgoorg/disgo-butler/butler"
+	"github.com/disgoorg/disgo-butler/commands"
+	"github.com/disgoorg/disgo-butler/common"
+)
+
+// HandleEvalMessageUpdate reruns evals when their message is edited and updates the result in place. The eval runs
+// in the background, so waiting in the eval queue doesn't block the other event listeners.
+func HandleEvalMessageUpdate(b *butler.Butler) func(e *events.MessageUpdate) {
+	return func(e *events.MessageUpdate) {
+		watch, ok := b.EvalWatches.Get(e.MessageID)
+		if !ok || watch.Content == e.Message.Content {
+			return
+		}
+		// remember the content right away, so the same content isn't run twice and the result of an older edit which
+		// finishes later doesn't replace the result of the latest edit
+		b.EvalWatches.SetContent(e.MessageID, e.Message.Content)
+
+		go func() {
+			update, err := rerunEval(b, e, watch)
+			if err != nil {
+				update = discord.MessageUpdate{
+					Content: json.Ptr(""),
+					Embeds: &[]discord.Embed{{
+						Description: fmt.Sprintf("Error while executing: %s", err),
+						Color:       common.ColorError,
+					}},
+					Attachments: &[]discord.AttachmentUpdate{},
+				}
+			}
+
+			if current, ok := b.EvalWatches.Get(e.MessageID); !ok || current.Content != e.Message.Content {
+				return
+			}
+			if _, err = e.Client().Rest().UpdateMessage(watch.ChannelID, watch.ResultID, update); err != nil {
+				var restErr *rest.Error
+				if errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound {
+					b.EvalWatches.Remove(e.MessageID)
+					return
+				}
+				b.Logger.Errorf("Failed to update eval result: %s", err)
+			}
+		}()
+	}
+}
+
+// rerunEval runs the code of the edited message. It goes through the eval queue like any other eval, but as there
+// is no interaction to show the queue position in, it waits silently.
+func rerunEval(b *butler.Butler, e *events.MessageUpdate, watch butler.EvalWatch) (discord.MessageUpdate, error) {
+	input, err := commands.EvalInputFromMessage(b, e.Message.Content, watch.Selection)
+	if err != nil {
+		return discord.MessageUpdate{}, err
+	}
+	input.Mode = watch.Mode
+	ticket, err := b.EvalQueue.Enqueue(e.Message.Author.ID, e.ChannelID)
+	if err != nil {
+		return discord.MessageUpdate{}, err
+	}
+	defer ticket.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), butler.EvalQueueTimeout)
+	defer cancel()
+	if err = ticket.Wait(ctx, nil); err != nil {
+		return discord.MessageUpdate{}, err
+	}
+	return commands.EvalMessageUpdate(b.Eval(context.Background(), input), e.MessageID, watch.Selection), nil
+}
+
+// HandleEvalMessageDelete stops rerunning evals when either their message or their result is deleted.
+func HandleEvalMessageDelete(b *butler.Butler) func(e *events.MessageDelete) {
+	return func(e *events.MessageDelete) {
+		b.EvalWatches.Remove(e.MessageID)
+	}
+}
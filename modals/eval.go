@@ -0,0 +1,28 @@
+package modals
+
+import (
+	"strings"
+
+	"github.com/disgoorg/disgo-butler/butler"
+	"github.com/disgoorg/disgo-butler/commands"
+	"github.com/disgoorg/disgo/handler"
+)
+
+func HandleEval(b *butler.Butler) handler.ModalHandler {
+	return func(e *handler.ModalEvent) error {
+		version := e.Variables["version"]
+		if version == commands.EvalLatestVersion {
+			version = ""
+		}
+		input := butler.EvalInput{
+			Language: e.Variables["language"],
+			Version:  version,
+			Files:    []butler.EvalFile{{Content: e.Data.Text("code")}},
+			Stdin:    e.Data.Text("stdin"),
+		}
+		if args := strings.TrimSpace(e.Data.Text("args")); args != "" {
+			input.Args = strings.Split(args, "\n")
+		}
+		return commands.RunEval(b, e.Client(), e.ModalSubmitInteraction, e.Respond, input, e.Variables["mode"] == "update", 0)
+	}
+}
@@ -0,0 +1,62 @@
+package modals
+
+import (
+	"database/sql"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/handler"
+
+	"github.com/disgoorg/disgo-butler/butler"
+	"github.com/disgoorg/disgo-butler/common"
+	"github.com/disgoorg/disgo-butler/db"
+)
+
+// HandleTag creates or edits the tag with the message written in the tag modal.
+func HandleTag(b *butler.Butler) handler.ModalHandler {
+	return func(e *handler.ModalEvent) error {
+		embed, err := butler.ParseTagEmbed(e.Data.Text("title"), e.Data.Text("description"), e.Data.Text("options"))
+		if err != nil {
+			return common.RespondErr(e.Respond, err)
+		}
+		buttons, err := butler.ParseTagButtons(e.Data.Text("buttons"))
+		if err != nil {
+			return common.RespondErr(e.Respond, err)
+		}
+		body := db.TagBody{
+			Content: e.Data.Text("content"),
+			Embed:   embed,
+			Buttons: buttons,
+		}
+		if body.Content == "" && body.Embed == nil {
+			return common.RespondErrMessage(e.Respond, "Tags need content or an embed.")
+		}
+
+		name := e.Variables["name"]
+		if e.Variables["mode"] == "create" {
+			// the tag could have been created while the modal was open
+			if _, err = b.DB.Get(*e.GuildID(), name); err == nil {
+				return common.RespondErrMessage(e.Respond, "Tag already exists.")
+			} else if err != sql.ErrNoRows {
+				return common.RespondMessageErr(e.Respond, "Failed to create tag: %s", err)
+			}
+			if err = b.DB.Create(*e.GuildID(), e.User().ID, name, body); err != nil {
+				return common.RespondMessageErr(e.Respond, "Failed to create tag: %s", err)
+			}
+			return common.Respond(e.Respond, "Tag created!")
+		}
+
+		tag, err := b.DB.Get(*e.GuildID(), name)
+		if err == sql.ErrNoRows {
+			return common.RespondErrMessage(e.Respond, "Tag not found.")
+		} else if err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to edit tag: %s", err)
+		}
+		if e.User().ID != tag.OwnerID && e.Member().Permissions.Missing(discord.PermissionManageServer) {
+			return common.RespondErrMessage(e.Respond, "You do not have permission to edit this tag.")
+		}
+		if err = b.DB.Edit(*e.GuildID(), tag.Name, body, e.User().ID); err != nil {
+			return common.RespondMessageErr(e.Respond, "Failed to edit tag: %s", err)
+		}
+		return common.Respond(e.Respond, "Tag edited.")
+	}
+}
@@ -2,13 +2,14 @@ package routes
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/disgoorg/disgo-butler/butler"
+	"github.com/disgoorg/disgo-butler/common"
 	"github.com/disgoorg/disgo/discord"
 	"github.com/disgoorg/disgo/webhook"
 	"github.com/google/go-github/v44/github"
@@ -40,33 +41,32 @@ func HandleGithubWebhook(b *butler.Butler) http.HandlerFunc {
 		}
 		switch e := event.(type) {
 		case *github.ReleaseEvent:
-			err = processReleaseEvent(b, e)
-		}
-		if err != nil {
-			b.Logger.Errorf("Failed to process webhook: %s", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			if e.GetAction() != "published" {
+				http.Error(w, "only published releases are announced", http.StatusUnprocessableEntity)
+				return
+			}
+			fullName := e.GetRepo().GetFullName()
+			cfg, ok := b.Config.GithubReleases[fullName]
+			if !ok {
+				b.Logger.Errorf("Failed to process release event: no config found for %s", fullName)
+				http.Error(w, "no config found for this repo", http.StatusNotFound)
+				return
+			}
+			webhookClient := b.Webhook(fullName, cfg)
+
+			// github gives up on webhooks after 10 seconds, while comparing the api of a release can take a lot longer
+			go func() {
+				if err := processReleaseEvent(b, e, cfg, webhookClient); err != nil {
+					b.Logger.Errorf("Failed to process release event: %s", err)
+				}
+			}()
 		}
+		w.WriteHeader(http.StatusAccepted)
 	}
 }
 
-func processReleaseEvent(b *butler.Butler, e *github.ReleaseEvent) error {
-	if e.GetAction() != "published" {
-		return nil
-	}
-
-	org, repo, fullName := e.GetRepo().GetOwner().GetLogin(), e.GetRepo().GetName(), e.GetRepo().GetFullName()
-
-	cfg, ok := b.Config.GithubReleases[fullName]
-	if !ok {
-		return errors.New("no config found for this repo")
-	}
-
-	webhookClient, ok := b.Webhooks[fullName]
-	if !ok {
-		webhookClient = webhook.New(cfg.WebhookID, cfg.WebhookToken)
-		b.Webhooks[fullName] = webhookClient
-	}
+func processReleaseEvent(b *butler.Butler, e *github.ReleaseEvent, cfg butler.GithubReleaseConfig, webhookClient webhook.Client) error {
+	org, repo := e.GetRepo().GetOwner().GetLogin(), e.GetRepo().GetName()
 
 	releases, _, err := b.GitHubClient.Repositories.ListReleases(context.TODO(), org, repo, &github.ListOptions{PerPage: 2})
 	if err != nil {
@@ -85,6 +85,23 @@ func processReleaseEvent(b *butler.Butler, e *github.ReleaseEvent) error {
 		return err
 	}
 
+	// messages can only contain 6000 characters in all embeds, so the commit list has to make room for the api changes
+	maxMessageLen := 4068
+	var diffEmbed *discord.Embed
+	if cfg.Module != "" && previousRelease != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if diff, err := b.ModuleSource.Diff(ctx, cfg.Module, previousRelease.GetTagName(), e.GetRelease().GetTagName()); err != nil {
+			b.Logger.Errorf("Failed to compare api of %s: %s", cfg.Module, err)
+		} else {
+			embed := butler.GetAPIDiffEmbed(diff, 1500)
+			diffEmbed = &embed
+			if remaining := 5600 - common.EmbedLength(embed); remaining < maxMessageLen {
+				maxMessageLen = remaining
+			}
+		}
+	}
+
 	message := parseMarkdown(e.GetRelease().GetBody())
 	if len(message) > 1024 {
 		message = substr(message, 0, 1024)
@@ -103,7 +120,7 @@ out:
 				shortId = substr(commit.GetSHA(), 0, 7)
 			}
 			line := fmt.Sprintf("[`%s`](%s) %s\n", shortId, commit.GetHTMLURL(), commitLine)
-			if len(message)+len(line) > 4068 {
+			if len(message)+len(line) > maxMessageLen {
 				message += "…"
 				break out
 			}
@@ -111,9 +128,8 @@ out:
 		}
 	}
 
-	msg, err := webhookClient.CreateMessage(discord.NewWebhookMessageCreateBuilder().
-		SetContent(discord.RoleMention(cfg.PingRole)).
-		SetEmbeds(discord.NewEmbedBuilder().
+	embeds := []discord.Embed{
+		discord.NewEmbedBuilder().
 			SetAuthor(
 				fmt.Sprintf("%s version %s has been released", repo, e.Release.GetTagName()),
 				e.GetRelease().GetHTMLURL(),
@@ -124,7 +140,14 @@ out:
 			SetFooter("Release by "+e.GetRelease().GetAuthor().GetLogin(), e.GetRelease().GetAuthor().GetAvatarURL()).
 			SetTimestamp(e.GetRelease().GetCreatedAt().Time).
 			Build(),
-		).
+	}
+	if diffEmbed != nil {
+		embeds = append(embeds, *diffEmbed)
+	}
+
+	msg, err := webhookClient.CreateMessage(discord.NewWebhookMessageCreateBuilder().
+		SetContent(discord.RoleMention(cfg.PingRole)).
+		SetEmbeds(embeds...).
 		Build(),
 	)
 	if err != nil {